@@ -1,6 +1,9 @@
 package amd
 
 import (
+	"errors"
+	"fmt"
+
 	"gonum.org/v1/gonum/mat"
 )
 
@@ -43,19 +46,46 @@ func AdjacencyList(mat mat.NonZeroDoer) [][]int {
 	return result
 }
 
-func isAdjacencyList(adjList [][]int) bool {
+// ErrInvalidAdjacency is returned when an adjacency list is invalid. Node is the
+// node where the problem was detected
+type ErrInvalidAdjacency struct {
+	Node   int
+	Reason string
+}
+
+func (e ErrInvalidAdjacency) Error() string {
+	return fmt.Sprintf("invalid adjacency list at node %d: %s", e.Node, e.Reason)
+}
+
+// ErrNoActiveNodes is returned when the minimum degree node is requested, but all
+// nodes are eliminated
+var ErrNoActiveNodes = errors.New("no active nodes")
+
+func validateAdjacencyList(adjList [][]int) error {
 	maxNodeNum := 0
-	for _, neighbours := range adjList {
+	for node, neighbours := range adjList {
 		if len(neighbours) == 0 {
-			return false
+			return ErrInvalidAdjacency{Node: node, Reason: "the node has no neighbours"}
 		}
 		for _, neighbour := range neighbours {
+			if neighbour < 0 || neighbour >= len(adjList) {
+				return ErrInvalidAdjacency{Node: node, Reason: fmt.Sprintf("neighbour %d is out of range", neighbour)}
+			}
+
 			if neighbour > maxNodeNum {
 				maxNodeNum = neighbour
 			}
 		}
 	}
-	return maxNodeNum == len(adjList)-1
+
+	if maxNodeNum != len(adjList)-1 {
+		return ErrInvalidAdjacency{Node: len(adjList) - 1, Reason: "the node is not neighbour to any other node"}
+	}
+	return nil
+}
+
+func isAdjacencyList(adjList [][]int) bool {
+	return validateAdjacencyList(adjList) == nil
 }
 
 type AmdCtx struct {
@@ -69,7 +99,20 @@ type AmdCtx struct {
 	Ordering []int
 }
 
+// MinimumActiveDegree returns the node with the lowest degree among the nodes that
+// are not eliminated. The method panics if all nodes are eliminated. Use
+// TryMinimumActiveDegree to get an error instead
 func (ctx *AmdCtx) MinimumActiveDegree() int {
+	node, err := ctx.TryMinimumActiveDegree()
+	if err != nil {
+		panic(err)
+	}
+	return node
+}
+
+// TryMinimumActiveDegree is the same as MinimumActiveDegree, except that it returns
+// ErrNoActiveNodes instead of panicking
+func (ctx *AmdCtx) TryMinimumActiveDegree() (int, error) {
 	isFirst := true
 	minimumDeg := 0
 	minimumNode := 0
@@ -84,9 +127,9 @@ func (ctx *AmdCtx) MinimumActiveDegree() int {
 	}
 
 	if isFirst {
-		panic("No active elements")
+		return 0, ErrNoActiveNodes
 	}
-	return minimumNode
+	return minimumNode, nil
 }
 
 func NewAmdCtx(n int) AmdCtx {
@@ -116,10 +159,26 @@ type NodeDegree interface {
 //
 // The method panics if the adjList is invalid. The adjacancy list is invalid
 // if either some node have zero neighbours or some nodes does not have a
-// neighbour list
+// neighbour list. Use TryApproximateMinimumDegree to get an error instead
+//
+// Deprecated: the degrees are recalculated from the adjacency list after each
+// elimination, which makes the time quadratic in the number of nodes. Use AMD,
+// which gives less fill and scales to large graphs
 func ApproximateMinimumDegree(adjList [][]int, degCalc NodeDegree) []int {
-	if !isAdjacencyList(adjList) {
-		panic("The provided adjecancy list appears to not be an adjacency list. All nodes must have a neighbour list")
+	order, err := TryApproximateMinimumDegree(adjList, degCalc)
+	if err != nil {
+		panic(err)
+	}
+	return order
+}
+
+// TryApproximateMinimumDegree is the same as ApproximateMinimumDegree, except that it
+// returns ErrInvalidAdjacency instead of panicking when the adjacency list is invalid
+//
+// Deprecated: use TryAMD
+func TryApproximateMinimumDegree(adjList [][]int, degCalc NodeDegree) ([]int, error) {
+	if err := validateAdjacencyList(adjList); err != nil {
+		return nil, err
 	}
 	n := len(adjList)
 	if degCalc == nil {
@@ -131,7 +190,10 @@ func ApproximateMinimumDegree(adjList [][]int, degCalc NodeDegree) []int {
 	}
 
 	for i := 0; i < n; i++ {
-		node := ctx.MinimumActiveDegree()
+		node, err := ctx.TryMinimumActiveDegree()
+		if err != nil {
+			return nil, err
+		}
 		ctx.Ordering = append(ctx.Ordering, node)
 		ctx.Eliminated[node] = true
 		degCalc.OnNodeEliminated(node, adjList, &ctx)
@@ -143,5 +205,5 @@ func ApproximateMinimumDegree(adjList [][]int, degCalc NodeDegree) []int {
 			ctx.Degrees[neighbor] = degCalc.Degree(neighbor, adjList, &ctx)
 		}
 	}
-	return ctx.Ordering
+	return ctx.Ordering, nil
 }
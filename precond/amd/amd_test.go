@@ -1,6 +1,7 @@
 package amd
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"slices"
@@ -222,3 +223,56 @@ func TestIsAdjecancyList(t *testing.T) {
 		}
 	}
 }
+
+func TestTryApproximateMinimumDegreeErrors(t *testing.T) {
+	for _, test := range []struct {
+		adjList  [][]int
+		wantNode int
+		desc     string
+	}{
+		{
+			adjList:  [][]int{{1}, {}},
+			wantNode: 1,
+			desc:     "Node 1 has no neighbours",
+		},
+		{
+			adjList:  [][]int{{1}, {3}},
+			wantNode: 1,
+			desc:     "Neighbour out of range",
+		},
+		{
+			adjList:  [][]int{{1}, {0}, {0}},
+			wantNode: 2,
+			desc:     "Node 2 is not neighbour to any node",
+		},
+	} {
+		order, err := TryApproximateMinimumDegree(test.adjList, nil)
+		if order != nil {
+			t.Errorf("Test %s: expected nil order got %v", test.desc, order)
+		}
+
+		var invalid ErrInvalidAdjacency
+		if !errors.As(err, &invalid) {
+			t.Errorf("Test %s: wanted ErrInvalidAdjacency got %v", test.desc, err)
+			continue
+		}
+
+		if invalid.Node != test.wantNode {
+			t.Errorf("Test %s: wanted node %d got %d", test.desc, test.wantNode, invalid.Node)
+		}
+	}
+}
+
+func TestTryMinimumActiveDegree(t *testing.T) {
+	ctx := NewAmdCtx(2)
+	ctx.Degrees = []int{3, 1}
+
+	if node, err := ctx.TryMinimumActiveDegree(); err != nil || node != 1 {
+		t.Errorf("Wanted node 1 and no error, got %d and %v", node, err)
+	}
+
+	ctx.Eliminated = []bool{true, true}
+	if _, err := ctx.TryMinimumActiveDegree(); !errors.Is(err, ErrNoActiveNodes) {
+		t.Errorf("Wanted ErrNoActiveNodes got %v", err)
+	}
+}
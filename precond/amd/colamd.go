@@ -0,0 +1,512 @@
+package amd
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/james-bowman/sparse"
+)
+
+// ColAMDSettings controls the column approximate minimum degree ordering
+type ColAMDSettings struct {
+	// Rows with more than max(16, DenseRow * sqrt(numCols)) entries are ignored during the
+	// ordering. Defaults to 10. A negative value disables the detection of dense rows
+	DenseRow float64
+
+	// Columns with more than max(16, DenseColumn * sqrt(min(numRows, numCols))) entries are
+	// removed before the ordering and placed last. Defaults to 10. A negative value disables
+	// the detection of dense columns
+	DenseColumn float64
+
+	// If true, rows are only absorbed when the pivot column is one of their columns, and not
+	// when all their columns are part of the new pivot row
+	NoAggressiveAbsorption bool
+}
+
+func (s *ColAMDSettings) withDefaults() ColAMDSettings {
+	result := ColAMDSettings{DenseRow: 10.0, DenseColumn: 10.0}
+	if s == nil {
+		return result
+	}
+
+	if s.DenseRow != 0.0 {
+		result.DenseRow = s.DenseRow
+	}
+	if s.DenseColumn != 0.0 {
+		result.DenseColumn = s.DenseColumn
+	}
+	result.NoAggressiveAbsorption = s.NoAggressiveAbsorption
+	return result
+}
+
+// CSRRows returns the column indices of each row of A. The lists share memory with A
+func CSRRows(A *sparse.CSR) [][]int {
+	raw := A.RawMatrix()
+	rows := make([][]int, raw.I)
+	for i := range rows {
+		start, end := raw.Indptr[i], raw.Indptr[i+1]
+		rows[i] = raw.Ind[start:end:end]
+	}
+	return rows
+}
+
+// columnGraph holds the bipartite graph of rows and columns during the column elimination.
+// Each row is a clique in the graph of A^T A, thus the rows play the role of the elements
+// in the quotient graph of AMD. When a column is eliminated, all rows containing it are
+// merged into a new pivot row, which is appended to rows
+type columnGraph struct {
+	rows [][]int
+
+	// Weighted number of columns in each row. It is -1 for dead rows
+	rowDegree []int
+
+	// Rows containing each column. Dead rows are removed lazily
+	cols [][]int
+
+	// Number of columns represented by a principal column. It is zero for dense, empty,
+	// eliminated and non-principal columns
+	thickness []int
+
+	// Approximate degree of the columns in the graph of A^T A
+	score []int
+
+	// Doubly linked score lists
+	head []int
+	next []int
+	last []int
+
+	// Hash buckets used to detect supercolumns
+	hash  []int
+	hhead []int
+	hnext []int
+
+	// Columns represented by each principal column, in the order they are eliminated
+	memberNext []int
+	memberTail []int
+
+	// colMark[j] equals stamp for the columns of the pivot row being constructed, and
+	// rowSeen[r] equals stamp for the rows of the column being compared
+	colMark []int
+	rowSeen []int
+	stamp   int
+
+	// Work array for the set differences |r \ Lk|. rowMark[r] < mark for all rows before
+	// each elimination step, which holds when mark is increased by more than the largest
+	// row degree after each step
+	rowMark      []int
+	mark         int
+	maxRowDegree int
+
+	// Total thickness of the columns that are not yet eliminated
+	remaining int
+
+	// Buffer for the construction of the pivot row
+	buffer []int
+
+	order []int
+}
+
+// newColumnGraph removes duplicates, dense rows and dense columns from the pattern, and
+// places empty and dense columns last in the ordering
+func newColumnGraph(rows [][]int, numCols int, denseRow, denseCol int) *columnGraph {
+	numRows := len(rows)
+	g := &columnGraph{
+		rows:       make([][]int, numRows, numRows+numCols),
+		rowDegree:  make([]int, numRows, numRows+numCols),
+		rowMark:    make([]int, numRows, numRows+numCols),
+		rowSeen:    make([]int, numRows, numRows+numCols),
+		cols:       make([][]int, numCols),
+		thickness:  make([]int, numCols),
+		score:      make([]int, numCols),
+		head:       make([]int, numCols+1),
+		next:       make([]int, numCols),
+		last:       make([]int, numCols),
+		hash:       make([]int, numCols),
+		hhead:      make([]int, numCols),
+		hnext:      make([]int, numCols),
+		memberNext: make([]int, numCols),
+		memberTail: make([]int, numCols),
+		colMark:    make([]int, numCols),
+		order:      make([]int, 0, numCols),
+		mark:       1,
+	}
+	for j := 0; j < numCols; j++ {
+		g.colMark[j] = -1
+		g.hhead[j] = -1
+		g.memberNext[j] = -1
+		g.memberTail[j] = j
+	}
+	for d := range g.head {
+		g.head[d] = -1
+	}
+
+	// Remove duplicates and count the entries of each column
+	count := make([]int, numCols)
+	nnz := 0
+	for _, row := range rows {
+		nnz += len(row)
+	}
+	storage := make([]int, 0, nnz)
+	for r, row := range rows {
+		start := len(storage)
+		for _, j := range row {
+			if g.colMark[j] != r {
+				g.colMark[j] = r
+				storage = append(storage, j)
+				count[j]++
+			}
+		}
+		g.rows[r] = storage[start:len(storage):len(storage)]
+	}
+
+	dense := make([]bool, numCols)
+	for j, c := range count {
+		dense[j] = c > denseCol
+	}
+
+	// Remove the dense columns from the rows and ignore the dense and empty rows
+	for j := range count {
+		count[j] = 0
+	}
+	for r, row := range g.rows {
+		kept := row[:0]
+		for _, j := range row {
+			if !dense[j] {
+				kept = append(kept, j)
+			}
+		}
+
+		if len(kept) == 0 || len(kept) > denseRow {
+			g.rowDegree[r] = -1
+			g.rows[r] = nil
+			continue
+		}
+
+		g.rows[r] = kept
+		g.rowDegree[r] = len(kept)
+		g.maxRowDegree = max(g.maxRowDegree, len(kept))
+		for _, j := range kept {
+			count[j]++
+		}
+	}
+
+	colStorage := make([]int, 0, nnz)
+	for j, c := range count {
+		g.cols[j] = colStorage[len(colStorage) : len(colStorage) : len(colStorage)+c]
+		colStorage = colStorage[:len(colStorage)+c]
+	}
+	for r, row := range g.rows {
+		for _, j := range row {
+			g.cols[j] = append(g.cols[j], r)
+		}
+	}
+
+	// The thickness of empty and dense columns is zero, such that they are skipped
+	var empty, denseCols []int
+	for j := 0; j < numCols; j++ {
+		switch {
+		case dense[j]:
+			denseCols = append(denseCols, j)
+		case count[j] == 0:
+			empty = append(empty, j)
+		default:
+			g.thickness[j] = 1
+			g.remaining++
+		}
+	}
+
+	for j := 0; j < numCols; j++ {
+		if g.thickness[j] == 0 {
+			continue
+		}
+
+		// Initial approximation of the degree of j in A^T A
+		score := 0
+		for _, r := range g.cols[j] {
+			score += g.rowDegree[r] - 1
+		}
+		g.score[j] = min(score, g.remaining-1)
+		g.addToScoreList(j)
+	}
+
+	for j := range g.colMark {
+		g.colMark[j] = 0
+	}
+
+	// The empty and dense columns are stored at the end of order, and the eliminated
+	// columns are appended in front of them
+	g.order = g.order[:numCols]
+	tail := numCols - len(empty) - len(denseCols)
+	copy(g.order[tail:], empty)
+	copy(g.order[tail+len(empty):], denseCols)
+	g.order = g.order[:0]
+	return g
+}
+
+func (g *columnGraph) removeFromScoreList(j int) {
+	if g.next[j] != -1 {
+		g.last[g.next[j]] = g.last[j]
+	}
+	if g.last[j] != -1 {
+		g.next[g.last[j]] = g.next[j]
+	} else {
+		g.head[g.score[j]] = g.next[j]
+	}
+}
+
+func (g *columnGraph) addToScoreList(j int) {
+	s := g.score[j]
+	if g.head[s] != -1 {
+		g.last[g.head[s]] = j
+	}
+	g.next[j] = g.head[s]
+	g.last[j] = -1
+	g.head[s] = j
+}
+
+// absorb places the columns represented by j after the columns of i
+func (g *columnGraph) absorb(i, j int) {
+	g.memberNext[g.memberTail[i]] = j
+	g.memberTail[i] = g.memberTail[j]
+	g.thickness[i] += g.thickness[j]
+	g.thickness[j] = 0
+	g.cols[j] = nil
+}
+
+// killRow marks row r as dead
+func (g *columnGraph) killRow(r int) {
+	g.rowDegree[r] = -1
+	g.rows[r] = nil
+}
+
+// eliminate eliminates the principal column p and returns the smallest score of the
+// columns in the new pivot row
+func (g *columnGraph) eliminate(p int, aggressive bool) int {
+	numCols := len(g.cols)
+	g.stamp++
+	g.colMark[p] = g.stamp
+
+	// The pivot row Lk is the union of the rows containing p. These rows are absorbed
+	// into Lk
+	lk := g.buffer[:0]
+	degree := 0
+	for _, r := range g.cols[p] {
+		if g.rowDegree[r] < 0 {
+			continue
+		}
+
+		for _, j := range g.rows[r] {
+			if g.thickness[j] > 0 && g.colMark[j] != g.stamp {
+				g.colMark[j] = g.stamp
+				lk = append(lk, j)
+				degree += g.thickness[j]
+				g.removeFromScoreList(j)
+			}
+		}
+		g.killRow(r)
+	}
+	g.cols[p] = nil
+	g.remaining -= g.thickness[p]
+
+	mindeg := numCols
+	if len(lk) == 0 {
+		g.emit(p)
+		return mindeg
+	}
+
+	// Find the set differences |r \ Lk| for all rows containing a column in Lk
+	for _, c := range lk {
+		for _, r := range g.cols[c] {
+			if g.rowDegree[r] < 0 {
+				continue
+			}
+			if g.rowMark[r] < g.mark {
+				g.rowMark[r] = g.mark + g.rowDegree[r]
+			}
+			g.rowMark[r] -= g.thickness[c]
+		}
+	}
+
+	// The buffer is reused, thus Lk is copied to a slice of its own
+	g.buffer = lk
+	lk = slices.Clone(lk)
+	k := len(g.rows)
+	g.rows = append(g.rows, lk)
+	g.rowDegree = append(g.rowDegree, 0)
+	g.rowMark = append(g.rowMark, 0)
+	g.rowSeen = append(g.rowSeen, 0)
+
+	// Remove the dead rows from the columns of Lk, add the pivot row and sum the external
+	// degrees of the remaining rows
+	for _, c := range lk {
+		partial, h := 0, 0
+		kept := g.cols[c][:0]
+		for _, r := range g.cols[c] {
+			if g.rowDegree[r] < 0 {
+				continue
+			}
+
+			external := g.rowMark[r] - g.mark
+			if external == 0 && aggressive {
+				// Aggressive absorption, all columns of r are in Lk
+				g.killRow(r)
+				continue
+			}
+			partial += external
+			h += r
+			kept = append(kept, r)
+		}
+
+		if len(kept) == 0 {
+			// Mass elimination, c is only part of Lk and is eliminated together with p
+			g.remaining -= g.thickness[c]
+			degree -= g.thickness[c]
+			g.absorb(p, c)
+			continue
+		}
+
+		// The column was part of at least one absorbed row, thus there is room for k
+		g.cols[c] = append(kept, k)
+		g.score[c] = partial
+		g.hash[c] = (h + k) % numCols
+	}
+	g.rowDegree[k] = degree
+	g.maxRowDegree = max(g.maxRowDegree, degree)
+	g.mark += g.maxRowDegree + 1
+
+	g.detectSupercolumns(lk)
+
+	// Place the remaining columns of Lk back in the score lists
+	kept := lk[:0]
+	for _, c := range lk {
+		if g.thickness[c] <= 0 {
+			continue
+		}
+		kept = append(kept, c)
+		score := g.score[c] + degree - g.thickness[c]
+		g.score[c] = max(0, min(score, g.remaining-g.thickness[c]))
+		g.addToScoreList(c)
+		mindeg = min(mindeg, g.score[c])
+	}
+	g.rows[k] = kept
+	if len(kept) == 0 {
+		g.killRow(k)
+	}
+
+	g.emit(p)
+	return mindeg
+}
+
+// detectSupercolumns merges the columns of Lk that are part of the same rows
+func (g *columnGraph) detectSupercolumns(lk []int) {
+	for _, c := range lk {
+		if g.thickness[c] > 0 {
+			g.hnext[c] = g.hhead[g.hash[c]]
+			g.hhead[g.hash[c]] = c
+		}
+	}
+
+	for _, c := range lk {
+		if g.thickness[c] <= 0 || g.hhead[g.hash[c]] == -1 {
+			continue
+		}
+
+		h := g.hash[c]
+		i := g.hhead[h]
+		g.hhead[h] = -1
+		for ; i != -1; i = g.hnext[i] {
+			g.stamp++
+			for _, r := range g.cols[i] {
+				g.rowSeen[r] = g.stamp
+			}
+
+			prev := i
+			for j := g.hnext[i]; j != -1; j = g.hnext[j] {
+				same := len(g.cols[j]) == len(g.cols[i])
+				for _, r := range g.cols[j] {
+					if !same {
+						break
+					}
+					same = g.rowSeen[r] == g.stamp
+				}
+
+				if same {
+					g.absorb(i, j)
+					g.hnext[prev] = g.hnext[j]
+				} else {
+					prev = j
+				}
+			}
+		}
+	}
+}
+
+// emit appends the columns represented by the eliminated column p to the ordering
+func (g *columnGraph) emit(p int) {
+	g.thickness[p] = 0
+	for j := p; j != -1; j = g.memberNext[j] {
+		g.order = append(g.order, j)
+	}
+}
+
+// columnOrder eliminates the columns in order of their approximate degree
+func (g *columnGraph) columnOrder(aggressive bool) []int {
+	numCols := len(g.cols)
+	mindeg := 0
+	for g.remaining > 0 {
+		p := -1
+		for ; mindeg < numCols; mindeg++ {
+			if p = g.head[mindeg]; p != -1 {
+				break
+			}
+		}
+		g.removeFromScoreList(p)
+		mindeg = min(mindeg, g.eliminate(p, aggressive))
+	}
+	return g.order[:numCols]
+}
+
+// COLAMD calculates a column ordering of the matrix with the sparsity pattern given by
+// rows, which reduces the fill in the Cholesky factor of A^T A, and thereby in the LU
+// factors of A with partial pivoting and in the R factor of the QR factorization of A.
+// rows[i] lists the columns of the non-zeros in row i, and the matrix can be rectangular.
+// Use CSRRows to obtain the rows of a CSR matrix. Unlike AMD on the symmetrized pattern
+// A + A^T, the ordering is based on the pattern of A^T A, but the product is never formed.
+//
+// The method follows the column approximate minimum degree algorithm of Davis, Gilbert,
+// Larimore and Ng. The rows act as elements in a quotient graph of the columns. When a
+// column is eliminated, the rows containing it are merged into a pivot row. The degrees
+// of the columns are approximated from the sizes of their rows, columns that are part of
+// the same rows are merged into supercolumns and rows contained in the pivot row are
+// absorbed. Dense rows are ignored, and dense and empty columns are placed last. The
+// element order[k] is the column placed at position k, thus A P^T with P = precond.Pivot{
+// Pivots: order} is the reordered matrix. If settings is nil, the default settings are used.
+// The method panics if a column is out of range. Use TryCOLAMD to get an error instead
+func COLAMD(rows [][]int, numCols int, settings *ColAMDSettings) []int {
+	order, err := TryCOLAMD(rows, numCols, settings)
+	if err != nil {
+		panic(err)
+	}
+	return order
+}
+
+// TryCOLAMD is the same as COLAMD, except that it returns ErrInvalidAdjacency instead of
+// panicking when a column is out of range. The Node field is the row of the column
+func TryCOLAMD(rows [][]int, numCols int, settings *ColAMDSettings) ([]int, error) {
+	for r, row := range rows {
+		for _, j := range row {
+			if j < 0 || j >= numCols {
+				return nil, ErrInvalidAdjacency{Node: r, Reason: fmt.Sprintf("column %d is out of range", j)}
+			}
+		}
+	}
+	if numCols <= 0 {
+		return []int{}, nil
+	}
+
+	opts := settings.withDefaults()
+	denseRow := denseThreshold(numCols, opts.DenseRow)
+	denseCol := denseThreshold(min(len(rows), numCols), opts.DenseColumn)
+	g := newColumnGraph(rows, numCols, denseRow, denseCol)
+	return g.columnOrder(!opts.NoAggressiveAbsorption), nil
+}
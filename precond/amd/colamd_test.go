@@ -0,0 +1,214 @@
+package amd
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/davidkleiven/goprecond/precond"
+	"github.com/james-bowman/sparse"
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/mat"
+	"pgregory.net/rapid"
+)
+
+// normalEquations returns the adjacency list of the pattern of A^T A, where rows
+// lists the columns of each row of A
+func normalEquations(rows [][]int, numCols int) [][]int {
+	adjList := make([][]int, numCols)
+	for _, row := range rows {
+		for _, i := range row {
+			for _, j := range row {
+				if i != j && !slices.Contains(adjList[i], j) {
+					adjList[i] = append(adjList[i], j)
+				}
+			}
+		}
+	}
+	return adjList
+}
+
+// gridRows returns the rows of the 5-point stencil on a n x n grid
+func gridRows(n int) [][]int {
+	rows := grid2D(n)
+	for i := range rows {
+		rows[i] = append(rows[i], i)
+	}
+	return rows
+}
+
+// randomRows returns a random pattern where each row has between one and maxPerRow columns
+func randomRows(numRows, numCols, maxPerRow int, seed uint64) [][]int {
+	rnd := rand.New(rand.NewSource(seed))
+	rows := make([][]int, numRows)
+	for r := range rows {
+		for k := 0; k < 1+rnd.Intn(maxPerRow); k++ {
+			rows[r] = append(rows[r], rnd.Intn(numCols))
+		}
+	}
+	return rows
+}
+
+func TestCOLAMDReducesFillOfNormalEquations(t *testing.T) {
+	for _, test := range []struct {
+		rows    [][]int
+		numCols int
+		desc    string
+	}{
+		{rows: gridRows(20), numCols: 400, desc: "grid"},
+		{rows: randomRows(600, 400, 3, 1), numCols: 400, desc: "overdetermined"},
+		{rows: randomRows(300, 400, 4, 2), numCols: 400, desc: "underdetermined"},
+	} {
+		order := COLAMD(test.rows, test.numCols, nil)
+		if !isPermutation(order, test.numCols) {
+			t.Fatalf("%s: the ordering is not a permutation", test.desc)
+		}
+
+		normal := normalEquations(test.rows, test.numCols)
+		natural := choleskyNonZeros(normal, slices.Sorted(slices.Values(order)))
+		fill := choleskyNonZeros(normal, order)
+		if 3*fill > 2*natural {
+			t.Errorf("%s: expected fill to be reduced by at least a third. Natural %d COLAMD %d", test.desc, natural, fill)
+		}
+
+		// AMD on the explicitly formed product is the reference
+		reference := choleskyNonZeros(normal, AMD(normal, nil))
+		if 4*fill > 5*reference {
+			t.Errorf("%s: expected fill close to AMD on A^T A. AMD %d COLAMD %d", test.desc, reference, fill)
+		}
+	}
+}
+
+func TestCOLAMDProperties(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		numRows := rapid.IntRange(0, 40).Draw(t, "rows")
+		numCols := rapid.IntRange(1, 40).Draw(t, "cols")
+		rows := make([][]int, numRows)
+		for r := range rows {
+			rows[r] = rapid.SliceOfN(rapid.IntRange(0, numCols-1), 0, 6).Draw(t, "row")
+		}
+		settings := &ColAMDSettings{
+			DenseRow:               rapid.SampledFrom([]float64{-1.0, 0.0, 0.5}).Draw(t, "dense-row"),
+			DenseColumn:            rapid.SampledFrom([]float64{-1.0, 0.0, 0.5}).Draw(t, "dense-col"),
+			NoAggressiveAbsorption: rapid.Bool().Draw(t, "no-aggressive"),
+		}
+
+		order := COLAMD(rows, numCols, settings)
+		if !isPermutation(order, numCols) {
+			t.Fatalf("The ordering is not a permutation: %v", order)
+		}
+	})
+}
+
+func TestCOLAMDDenseAndEmptyColumnsLast(t *testing.T) {
+	// Grid where column 400 is part of all rows, and column 401 is empty
+	rows := gridRows(20)
+	for i := range rows {
+		rows[i] = append(rows[i], 400)
+	}
+
+	order := COLAMD(rows, 402, nil)
+	if !isPermutation(order, 402) {
+		t.Fatalf("The ordering is not a permutation")
+	}
+
+	if !slices.Equal(order[400:], []int{401, 400}) {
+		t.Errorf("Expected the empty and dense column last. Got %v", order[400:])
+	}
+}
+
+func TestCOLAMDIgnoresDenseRows(t *testing.T) {
+	// A^T A is full with a dense row, but the ordering of the remaining rows is kept
+	n := 20
+	rows := gridRows(n)
+	want := COLAMD(rows, n*n, nil)
+
+	full := make([]int, n*n)
+	for i := range full {
+		full[i] = i
+	}
+	rows = append(rows, full)
+	if got := COLAMD(rows, n*n, nil); !slices.Equal(got, want) {
+		t.Errorf("Expected the dense row to be ignored")
+	}
+}
+
+func TestCOLAMDIdenticalColumnsAreAdjacent(t *testing.T) {
+	// Columns 1 and 4 appear in the same rows and form a supercolumn
+	rows := [][]int{{0, 1, 4}, {1, 2, 4}, {2, 3}, {3, 5}, {5, 0}, {1, 4, 5}}
+	order := COLAMD(rows, 6, nil)
+	if !isPermutation(order, 6) {
+		t.Fatalf("The ordering is not a permutation: %v", order)
+	}
+
+	pos1, pos4 := slices.Index(order, 1), slices.Index(order, 4)
+	if pos1-pos4 != 1 && pos4-pos1 != 1 {
+		t.Errorf("Expected columns 1 and 4 to be adjacent. Got %v", order)
+	}
+}
+
+func TestCOLAMDIsDeterministic(t *testing.T) {
+	rows := randomRows(900, 600, 4, 3)
+	want := COLAMD(rows, 600, nil)
+	for i := 0; i < 3; i++ {
+		if got := COLAMD(rows, 600, nil); !slices.Equal(got, want) {
+			t.Fatalf("The ordering changed between runs")
+		}
+	}
+}
+
+func TestCOLAMDOfCSR(t *testing.T) {
+	// Rectangular matrix with more rows than columns
+	dok := sparse.NewDOK(4, 3)
+	dok.Set(0, 0, 1.0)
+	dok.Set(1, 0, 2.0)
+	dok.Set(1, 2, 3.0)
+	dok.Set(2, 1, 4.0)
+	dok.Set(3, 2, 5.0)
+	A := dok.ToCSR()
+
+	rows := CSRRows(A)
+	want := [][]int{{0}, {0, 2}, {1}, {2}}
+	for i := range want {
+		if !slices.Equal(slices.Sorted(slices.Values(rows[i])), want[i]) {
+			t.Errorf("Row %d: wanted %v got %v", i, want[i], rows[i])
+		}
+	}
+
+	order := COLAMD(rows, 3, nil)
+	pivot := precond.Pivot{Pivots: order}
+	var permuted mat.Dense
+	permuted.Mul(A, pivot.T())
+	for k, j := range order {
+		for i := 0; i < 4; i++ {
+			if permuted.At(i, k) != A.At(i, j) {
+				t.Errorf("Expected column %d of the permuted matrix to be column %d", k, j)
+			}
+		}
+	}
+}
+
+func TestTryCOLAMDErrors(t *testing.T) {
+	order, err := TryCOLAMD([][]int{{0}, {1, 3}}, 3, nil)
+	if order != nil {
+		t.Errorf("Expected nil order got %v", order)
+	}
+
+	var invalid ErrInvalidAdjacency
+	if !errors.As(err, &invalid) || invalid.Node != 1 {
+		t.Errorf("Wanted ErrInvalidAdjacency at row 1 got %v", err)
+	}
+
+	if order := COLAMD(nil, 0, nil); len(order) != 0 {
+		t.Errorf("Expected an empty ordering got %v", order)
+	}
+}
+
+func BenchmarkCOLAMD(b *testing.B) {
+	// Grid with one million columns
+	rows := gridRows(1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		COLAMD(rows, len(rows), nil)
+	}
+}
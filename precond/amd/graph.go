@@ -0,0 +1,86 @@
+package amd
+
+import "fmt"
+
+// graph is a symmetric sparsity pattern without self loops stored in compressed row format
+type graph struct {
+	indptr []int
+	ind    []int
+}
+
+func (g *graph) numNodes() int {
+	return len(g.indptr) - 1
+}
+
+func (g *graph) neighbours(i int) []int {
+	return g.ind[g.indptr[i]:g.indptr[i+1]]
+}
+
+func (g *graph) degree(i int) int {
+	return g.indptr[i+1] - g.indptr[i]
+}
+
+// symmetricGraph symmetrizes the pattern given by adjList, and removes self loops and
+// duplicates. The neighbours are listed in the order they first appear
+func symmetricGraph(adjList [][]int) graph {
+	n := len(adjList)
+	g := graph{indptr: make([]int, n+1)}
+	for i, neighbours := range adjList {
+		for _, j := range neighbours {
+			if j != i {
+				g.indptr[i+1]++
+				g.indptr[j+1]++
+			}
+		}
+	}
+	for i := 0; i < n; i++ {
+		g.indptr[i+1] += g.indptr[i]
+	}
+
+	g.ind = make([]int, g.indptr[n])
+	next := make([]int, n)
+	copy(next, g.indptr[:n])
+	for i, neighbours := range adjList {
+		for _, j := range neighbours {
+			if j != i {
+				g.ind[next[i]] = j
+				next[i]++
+				g.ind[next[j]] = i
+				next[j]++
+			}
+		}
+	}
+
+	// Remove duplicates and compact the lists
+	mark := next
+	for i := range mark {
+		mark[i] = -1
+	}
+	nnz := 0
+	for i := 0; i < n; i++ {
+		start, end := g.indptr[i], g.indptr[i+1]
+		g.indptr[i] = nnz
+		for _, j := range g.ind[start:end] {
+			if mark[j] != i {
+				mark[j] = i
+				g.ind[nnz] = j
+				nnz++
+			}
+		}
+	}
+	g.indptr[n] = nnz
+	g.ind = g.ind[:nnz]
+	return g
+}
+
+// checkNeighbours returns ErrInvalidAdjacency if a neighbour is out of range
+func checkNeighbours(adjList [][]int) error {
+	for node, neighbours := range adjList {
+		for _, neighbour := range neighbours {
+			if neighbour < 0 || neighbour >= len(adjList) {
+				return ErrInvalidAdjacency{Node: node, Reason: fmt.Sprintf("neighbour %d is out of range", neighbour)}
+			}
+		}
+	}
+	return nil
+}
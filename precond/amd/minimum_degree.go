@@ -0,0 +1,556 @@
+package amd
+
+import (
+	"math"
+	"slices"
+
+	"github.com/james-bowman/sparse"
+)
+
+// Settings controls the approximate minimum degree ordering
+type Settings struct {
+	// Nodes with more than max(16, Dense * sqrt(n)) neighbours are considered dense.
+	// Dense nodes are removed from the graph before the ordering and placed last.
+	// Defaults to 10. A negative value disables the detection of dense nodes
+	Dense float64
+
+	// If true, elements are only absorbed when all their nodes are part of the new
+	// element, and not when their external degree is zero
+	NoAggressiveAbsorption bool
+}
+
+func (s *Settings) withDefaults() Settings {
+	result := Settings{Dense: 10.0}
+	if s == nil {
+		return result
+	}
+
+	if s.Dense != 0.0 {
+		result.Dense = s.Dense
+	}
+	result.NoAggressiveAbsorption = s.NoAggressiveAbsorption
+	return result
+}
+
+// CSRAdjacencyList returns the adjacency list of the symmetrized pattern of A. Unlike
+// AdjacencyList, there is one list for each row of A, and nodes without neighbours
+// get an empty list. The neighbours are sorted and the time is proportional to the
+// number of non-zeros, thus it is suitable for large matrices
+func CSRAdjacencyList(A *sparse.CSR) [][]int {
+	raw := A.RawMatrix()
+	n := max(raw.I, raw.J)
+
+	count := make([]int, n)
+	for i := 0; i < raw.I; i++ {
+		for k := raw.Indptr[i]; k < raw.Indptr[i+1]; k++ {
+			if j := raw.Ind[k]; j != i {
+				count[i]++
+				count[j]++
+			}
+		}
+	}
+
+	// Storage for all lists, such that the lists are not allocated one by one
+	storage := make([]int, 0, 2*raw.Indptr[raw.I])
+	adjList := make([][]int, n)
+	for i, c := range count {
+		adjList[i] = storage[len(storage) : len(storage) : len(storage)+c]
+		storage = storage[:len(storage)+c]
+	}
+
+	colStart := make([]int, n+1)
+	for i := 0; i < raw.I; i++ {
+		for k := raw.Indptr[i]; k < raw.Indptr[i+1]; k++ {
+			colStart[raw.Ind[k]+1]++
+		}
+	}
+	for j := 0; j < n; j++ {
+		colStart[j+1] += colStart[j]
+	}
+	rows := make([]int, raw.Indptr[raw.I])
+	next := make([]int, n)
+	copy(next, colStart[:n])
+	for i := 0; i < raw.I; i++ {
+		for k := raw.Indptr[i]; k < raw.Indptr[i+1]; k++ {
+			rows[next[raw.Ind[k]]] = i
+			next[raw.Ind[k]]++
+		}
+	}
+
+	// Node i is adjacent to the columns of row i and the rows of column i
+	mark := make([]int, n)
+	for i := range mark {
+		mark[i] = -1
+	}
+	for i := 0; i < n; i++ {
+		var row []int
+		if i < raw.I {
+			row = raw.Ind[raw.Indptr[i]:raw.Indptr[i+1]]
+		}
+		col := rows[colStart[i]:colStart[i+1]]
+
+		for _, j := range row {
+			if j != i && mark[j] != i {
+				mark[j] = i
+				adjList[i] = append(adjList[i], j)
+			}
+		}
+		for _, j := range col {
+			if j != i && mark[j] != i {
+				mark[j] = i
+				adjList[i] = append(adjList[i], j)
+			}
+		}
+		slices.Sort(adjList[i])
+	}
+	return adjList
+}
+
+// AMD calculates an approximate minimum degree ordering of the graph given by adjList,
+// following the algorithm of Amestoy, Davis and Duff. adjList[i] lists the neighbours
+// of node i, and the number of nodes is len(adjList). Nodes without neighbours are allowed,
+// self loops and duplicates are ignored and the pattern is symmetrized. The element order[k]
+// is the node that is eliminated in step k, thus the result can be used as a precond.Pivot.
+//
+// The graph is held as a quotient graph, where each eliminated node becomes an element that
+// represents the clique formed by its neighbours. Nodes are kept in lists bucketed by an
+// upper bound of their external degree, nodes with identical adjacency are merged into
+// supervariables and eliminated together (mass elimination), and elements are absorbed into
+// new elements when they become redundant. The time and memory usage are close to linear in
+// the number of edges, thus it is suitable for graphs with millions of nodes. If settings is
+// nil, the default settings are used. The method panics if a neighbour is out of range. Use
+// TryAMD to get an error instead
+func AMD(adjList [][]int, settings *Settings) []int {
+	order, err := TryAMD(adjList, settings)
+	if err != nil {
+		panic(err)
+	}
+	return order
+}
+
+// TryAMD is the same as AMD, except that it returns ErrInvalidAdjacency instead of
+// panicking when a neighbour is out of range
+func TryAMD(adjList [][]int, settings *Settings) ([]int, error) {
+	if err := checkNeighbours(adjList); err != nil {
+		return nil, err
+	}
+
+	n := len(adjList)
+	opts := settings.withDefaults()
+	g := newQuotientGraph(adjList, denseThreshold(n, opts.Dense))
+	return g.minimumDegreeOrder(!opts.NoAggressiveAbsorption), nil
+}
+
+// denseThreshold returns the degree above which nodes are considered dense
+func denseThreshold(n int, dense float64) int {
+	if dense < 0.0 {
+		return n
+	}
+	return min(n, max(16, int(dense*math.Sqrt(float64(n)))))
+}
+
+// quotientGraph holds the nodes (variables) and elements during the elimination. When
+// node p is eliminated, it becomes element p, whose nodes are the nodes adjacent to p in
+// the elimination graph. The elements adjacent to a node are cliques in the elimination
+// graph, thus the graph is represented by the elements and the remaining original edges
+type quotientGraph struct {
+	// Nodes of each element. Dead elements have nil lists
+	elements [][]int
+
+	// Weighted number of nodes in each element. It is -1 for nodes that have not
+	// become elements and for dead elements
+	elementSize []int
+
+	// The elements adjacent to each node followed by the adjacent nodes. The first
+	// numElements[i] entries of adj[i] are elements. Dead entries are removed lazily
+	adj         [][]int
+	numElements []int
+
+	// Number of nodes represented by a supervariable. It is zero for dense, eliminated
+	// and non-principal nodes
+	weight []int
+
+	// Approximate external degree of the nodes
+	degree []int
+
+	// Doubly linked degree lists
+	head []int
+	next []int
+	last []int
+
+	// Hash buckets used to detect supervariables
+	hash  []int
+	hhead []int
+	hnext []int
+
+	// Nodes represented by each principal node, in the order they were absorbed
+	memberNext []int
+	memberTail []int
+
+	// nodeMark[j] equals stamp for the nodes of the element being constructed, and for
+	// the nodes adjacent to the node being compared. elemSeen[e] equals stamp for the
+	// elements adjacent to the node being compared
+	nodeMark []int
+	elemSeen []int
+	stamp    int
+
+	// Work array for the set differences |Le \ Lp|. elemMark[e] < mark for all elements
+	// before each elimination step, which holds when mark is increased by more than the
+	// largest element size after each step
+	elemMark       []int
+	mark           int
+	maxElementSize int
+
+	// Total weight of the nodes that are not yet eliminated
+	remaining int
+
+	// Buffer for the construction of the new element
+	buffer []int
+
+	order []int
+}
+
+// newQuotientGraph creates a graph where all nodes are variables. Nodes with more
+// than dense neighbours are left out of the graph and placed last in the ordering
+func newQuotientGraph(adjList [][]int, dense int) *quotientGraph {
+	n := len(adjList)
+	g := &quotientGraph{
+		elements:    make([][]int, n),
+		elementSize: make([]int, n),
+		adj:         make([][]int, n),
+		numElements: make([]int, n),
+		weight:      make([]int, n),
+		degree:      make([]int, n),
+		head:        make([]int, n+1),
+		next:        make([]int, n),
+		last:        make([]int, n),
+		hash:        make([]int, n),
+		hhead:       make([]int, n),
+		hnext:       make([]int, n),
+		memberNext:  make([]int, n),
+		memberTail:  make([]int, n),
+		nodeMark:    make([]int, n),
+		elemSeen:    make([]int, n),
+		elemMark:    make([]int, n),
+		mark:        1,
+		order:       make([]int, 0, n),
+	}
+	for i := 0; i < n; i++ {
+		g.elementSize[i] = -1
+		g.hhead[i] = -1
+		g.memberNext[i] = -1
+		g.memberTail[i] = i
+	}
+	for d := range g.head {
+		g.head[d] = -1
+	}
+
+	pattern := symmetricGraph(adjList)
+	var denseNodes []int
+	for i := 0; i < n; i++ {
+		start, end := pattern.indptr[i], pattern.indptr[i+1]
+		g.adj[i] = pattern.ind[start:end:end]
+		if end-start > dense {
+			denseNodes = append(denseNodes, i)
+			continue
+		}
+		g.weight[i] = 1
+		g.remaining++
+	}
+
+	// The dense nodes do not contribute to the degrees, since they are eliminated last
+	for i := 0; i < n; i++ {
+		if g.weight[i] == 0 {
+			g.adj[i] = nil
+			continue
+		}
+
+		for _, j := range g.adj[i] {
+			g.degree[i] += g.weight[j]
+		}
+		g.addToDegreeList(i)
+	}
+
+	// The dense nodes are stored at the end of order, and the eliminated nodes are
+	// appended in front of them
+	g.order = g.order[:n]
+	copy(g.order[n-len(denseNodes):], denseNodes)
+	g.order = g.order[:0]
+	return g
+}
+
+func (g *quotientGraph) removeFromDegreeList(i int) {
+	if g.next[i] != -1 {
+		g.last[g.next[i]] = g.last[i]
+	}
+	if g.last[i] != -1 {
+		g.next[g.last[i]] = g.next[i]
+	} else {
+		g.head[g.degree[i]] = g.next[i]
+	}
+}
+
+func (g *quotientGraph) addToDegreeList(i int) {
+	d := g.degree[i]
+	if g.head[d] != -1 {
+		g.last[g.head[d]] = i
+	}
+	g.next[i] = g.head[d]
+	g.last[i] = -1
+	g.head[d] = i
+}
+
+// absorb makes node j part of the supervariable i
+func (g *quotientGraph) absorb(i, j int) {
+	g.memberNext[g.memberTail[i]] = j
+	g.memberTail[i] = g.memberTail[j]
+	g.weight[i] += g.weight[j]
+	g.weight[j] = 0
+	g.adj[j] = nil
+	g.numElements[j] = 0
+}
+
+// elementsOf returns the elements adjacent to node i
+func (g *quotientGraph) elementsOf(i int) []int {
+	return g.adj[i][:g.numElements[i]]
+}
+
+// nodesOf returns the nodes adjacent to node i
+func (g *quotientGraph) nodesOf(i int) []int {
+	return g.adj[i][g.numElements[i]:]
+}
+
+// killElement marks element e as dead. It happens when e is absorbed into a new element
+func (g *quotientGraph) killElement(e int) {
+	g.elementSize[e] = -1
+	g.elements[e] = nil
+}
+
+// eliminate eliminates the principal node p and returns the smallest degree of the
+// nodes in the new element
+func (g *quotientGraph) eliminate(p int, aggressive bool) int {
+	n := len(g.weight)
+	g.stamp++
+	g.nodeMark[p] = g.stamp
+
+	// The new element Lp is the union of the nodes adjacent to p and the nodes of the
+	// elements adjacent to p. These elements are absorbed into Lp
+	lp := g.buffer[:0]
+	size := 0
+	add := func(j int) {
+		if g.weight[j] > 0 && g.nodeMark[j] != g.stamp {
+			g.nodeMark[j] = g.stamp
+			lp = append(lp, j)
+			size += g.weight[j]
+			g.removeFromDegreeList(j)
+		}
+	}
+	for _, e := range g.elementsOf(p) {
+		if g.elementSize[e] < 0 {
+			continue
+		}
+		for _, j := range g.elements[e] {
+			add(j)
+		}
+		g.killElement(e)
+	}
+	for _, j := range g.nodesOf(p) {
+		add(j)
+	}
+	storage := g.adj[p][:0]
+	g.adj[p] = nil
+	g.numElements[p] = 0
+	g.remaining -= g.weight[p]
+
+	mindeg := n
+	if len(lp) == 0 {
+		g.emit(p)
+		return mindeg
+	}
+
+	// Find the set differences |Le \ Lp| for all elements adjacent to a node in Lp
+	for _, i := range lp {
+		for _, e := range g.elementsOf(i) {
+			if g.elementSize[e] < 0 {
+				continue
+			}
+			if g.elemMark[e] < g.mark {
+				g.elemMark[e] = g.mark + g.elementSize[e]
+			}
+			g.elemMark[e] -= g.weight[i]
+		}
+	}
+
+	// The buffer is reused, thus Lp is copied to a slice of its own. The list of p is
+	// no longer needed, and is used if it is large enough
+	g.buffer = lp
+	if cap(storage) >= len(lp) {
+		lp = append(storage, lp...)
+	} else {
+		lp = slices.Clone(lp)
+	}
+
+	// Remove the dead entries from the lists of the nodes in Lp, and the nodes that are
+	// now covered by element p. The external degree of a node is bounded by the sum of
+	// the external parts of its elements and the weights of its remaining neighbours
+	for _, i := range lp {
+		external, h := 0, 0
+		kept := g.adj[i][:0]
+		for _, e := range g.elementsOf(i) {
+			if g.elementSize[e] < 0 {
+				continue
+			}
+
+			outside := g.elemMark[e] - g.mark
+			if outside == 0 && aggressive {
+				// Aggressive absorption, all nodes of e are in Lp
+				g.killElement(e)
+				continue
+			}
+			external += outside
+			h += e
+			kept = append(kept, e)
+		}
+		numElements := len(kept)
+
+		for _, j := range g.nodesOf(i) {
+			if g.weight[j] > 0 && g.nodeMark[j] != g.stamp {
+				external += g.weight[j]
+				h += j
+				kept = append(kept, j)
+			}
+		}
+
+		if external == 0 {
+			// Mass elimination, i is only adjacent to Lp and is eliminated together with p
+			g.remaining -= g.weight[i]
+			size -= g.weight[i]
+			g.absorb(p, i)
+			continue
+		}
+
+		// Either p or an element absorbed into p was removed from the list, thus there
+		// is room for p after the other elements
+		kept = kept[:len(kept)+1]
+		kept[len(kept)-1] = kept[numElements]
+		kept[numElements] = p
+		g.adj[i] = kept
+		g.numElements[i] = numElements + 1
+		g.degree[i] = min(g.degree[i], external)
+		g.hash[i] = (h + p) % n
+	}
+	g.elements[p] = lp
+	g.elementSize[p] = size
+	g.maxElementSize = max(g.maxElementSize, size)
+	g.mark += g.maxElementSize + 1
+
+	g.detectSupervariables(lp)
+
+	// Place the remaining nodes of Lp back in the degree lists. The external degree of
+	// node i is at most the previous bound plus the nodes of Lp other than i
+	kept := lp[:0]
+	for _, i := range lp {
+		if g.weight[i] <= 0 {
+			continue
+		}
+		kept = append(kept, i)
+		degree := g.degree[i] + size - g.weight[i]
+		g.degree[i] = max(0, min(degree, g.remaining-g.weight[i]))
+		g.addToDegreeList(i)
+		mindeg = min(mindeg, g.degree[i])
+	}
+	g.elements[p] = kept
+	if len(kept) == 0 {
+		g.killElement(p)
+	}
+
+	g.emit(p)
+	return mindeg
+}
+
+// detectSupervariables merges the nodes of Lp that are adjacent to the same elements
+// and nodes. Such nodes are indistinguishable and are eliminated together
+func (g *quotientGraph) detectSupervariables(lp []int) {
+	for _, i := range lp {
+		if g.weight[i] > 0 {
+			g.hnext[i] = g.hhead[g.hash[i]]
+			g.hhead[g.hash[i]] = i
+		}
+	}
+
+	for _, i := range lp {
+		if g.weight[i] <= 0 || g.hhead[g.hash[i]] == -1 {
+			continue
+		}
+
+		h := g.hash[i]
+		first := g.hhead[h]
+		g.hhead[h] = -1
+		for ; first != -1; first = g.hnext[first] {
+			g.stamp++
+			for _, e := range g.elementsOf(first) {
+				g.elemSeen[e] = g.stamp
+			}
+			for _, j := range g.nodesOf(first) {
+				g.nodeMark[j] = g.stamp
+			}
+
+			prev := first
+			for j := g.hnext[first]; j != -1; j = g.hnext[j] {
+				if g.indistinguishable(first, j) {
+					g.absorb(first, j)
+					g.hnext[prev] = g.hnext[j]
+				} else {
+					prev = j
+				}
+			}
+		}
+	}
+}
+
+// indistinguishable returns true if node j has the same adjacent elements and nodes
+// as the node whose lists are marked with the current stamp
+func (g *quotientGraph) indistinguishable(i, j int) bool {
+	if len(g.adj[j]) != len(g.adj[i]) || g.numElements[j] != g.numElements[i] {
+		return false
+	}
+
+	for _, e := range g.elementsOf(j) {
+		if g.elemSeen[e] != g.stamp {
+			return false
+		}
+	}
+	for _, k := range g.nodesOf(j) {
+		if g.nodeMark[k] != g.stamp {
+			return false
+		}
+	}
+	return true
+}
+
+// emit appends the nodes represented by the eliminated node p to the ordering. The
+// nodes of a supervariable are eliminated together, and p is placed last among them
+func (g *quotientGraph) emit(p int) {
+	g.weight[p] = 0
+	for j := g.memberNext[p]; j != -1; j = g.memberNext[j] {
+		g.order = append(g.order, j)
+	}
+	g.order = append(g.order, p)
+}
+
+// minimumDegreeOrder eliminates the nodes in order of their approximate degree
+func (g *quotientGraph) minimumDegreeOrder(aggressive bool) []int {
+	n := len(g.weight)
+	mindeg := 0
+	for g.remaining > 0 {
+		p := -1
+		for ; mindeg < n; mindeg++ {
+			if p = g.head[mindeg]; p != -1 {
+				break
+			}
+		}
+		g.removeFromDegreeList(p)
+		mindeg = min(mindeg, g.eliminate(p, aggressive))
+	}
+	return g.order[:n]
+}
@@ -0,0 +1,309 @@
+package amd
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/davidkleiven/goprecond/precond"
+	"github.com/davidkleiven/goprecond/precond/precondtest"
+	"github.com/davidkleiven/goprecond/precond/property"
+	"github.com/james-bowman/sparse"
+	"pgregory.net/rapid"
+)
+
+// grid2D returns the adjacency list of the 5-point stencil on a n x n grid
+func grid2D(n int) [][]int {
+	adjList := make([][]int, n*n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			node := i*n + j
+			if i > 0 {
+				adjList[node] = append(adjList[node], node-n)
+			}
+			if j > 0 {
+				adjList[node] = append(adjList[node], node-1)
+			}
+			if j < n-1 {
+				adjList[node] = append(adjList[node], node+1)
+			}
+			if i < n-1 {
+				adjList[node] = append(adjList[node], node+n)
+			}
+		}
+	}
+	return adjList
+}
+
+// choleskyNonZeros returns the number of non-zeros in the Cholesky factor of the
+// matrix with the symmetric pattern given by adjList after the symmetric permutation
+// by order. The diagonal is included
+func choleskyNonZeros(adjList [][]int, order []int) int {
+	n := len(adjList)
+	inv := make([]int, n)
+	for k, i := range order {
+		inv[i] = k
+	}
+
+	// Elimination tree
+	parent := make([]int, n)
+	ancestor := make([]int, n)
+	for k := 0; k < n; k++ {
+		parent[k] = -1
+		ancestor[k] = -1
+		for _, neighbour := range adjList[order[k]] {
+			for j := inv[neighbour]; j != -1 && j < k; {
+				next := ancestor[j]
+				ancestor[j] = k
+				if next == -1 {
+					parent[j] = k
+				}
+				j = next
+			}
+		}
+	}
+
+	// The non-zeros of row k are the nodes in the row subtree
+	mark := make([]int, n)
+	for i := range mark {
+		mark[i] = -1
+	}
+	count := n
+	for k := 0; k < n; k++ {
+		mark[k] = k
+		for _, neighbour := range adjList[order[k]] {
+			for j := inv[neighbour]; j != -1 && j < k && mark[j] != k; j = parent[j] {
+				mark[j] = k
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func isPermutation(order []int, n int) bool {
+	if len(order) != n {
+		return false
+	}
+
+	seen := make([]bool, n)
+	for _, i := range order {
+		if i < 0 || i >= n || seen[i] {
+			return false
+		}
+		seen[i] = true
+	}
+	return true
+}
+
+func TestAMDReducesFillOnGrids(t *testing.T) {
+	for _, n := range []int{10, 30, 100} {
+		adjList := grid2D(n)
+		order := AMD(adjList, nil)
+		if !isPermutation(order, n*n) {
+			t.Fatalf("Size %d: the ordering is not a permutation", n)
+		}
+
+		natural := choleskyNonZeros(adjList, slices.Sorted(slices.Values(order)))
+		fill := choleskyNonZeros(adjList, order)
+		if 3*fill > 2*natural {
+			t.Errorf("Size %d: expected fill to be reduced by at least a third. Natural %d AMD %d", n, natural, fill)
+		}
+
+		if n <= 30 {
+			// The simplified minimum degree ordering is quadratic in the number of nodes
+			simplified := choleskyNonZeros(adjList, ApproximateMinimumDegree(adjList, nil))
+			if fill > simplified {
+				t.Errorf("Size %d: expected less fill than the simplified ordering. Simplified %d AMD %d", n, simplified, fill)
+			}
+		}
+	}
+}
+
+func TestAMDProperties(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		matrix := property.SparseSymmetricMatrix(t, 1, 50)
+		adjList := property.SparseMatToAdjList(matrix)
+		settings := &Settings{
+			Dense:                  rapid.SampledFrom([]float64{-1.0, 0.0, 0.5}).Draw(t, "dense"),
+			NoAggressiveAbsorption: rapid.Bool().Draw(t, "no-aggressive"),
+		}
+
+		order := AMD(adjList, settings)
+		if !isPermutation(order, len(adjList)) {
+			t.Fatalf("The ordering is not a permutation: %v", order)
+		}
+
+		natural := choleskyNonZeros(adjList, slices.Sorted(slices.Values(order)))
+		if fill := choleskyNonZeros(adjList, order); fill > natural {
+			t.Fatalf("Fill-in should be reduced when applying AMD. nnz before: %d nnz after %d\n", natural, fill)
+		}
+	})
+}
+
+func TestAMDEliminatesLeavesFirst(t *testing.T) {
+	// Arrow shaped graph where node 0 is connected to all other nodes
+	n := 10
+	adjList := make([][]int, n)
+	for i := 1; i < n; i++ {
+		adjList[0] = append(adjList[0], i)
+	}
+
+	order := AMD(adjList, &Settings{Dense: -1.0})
+	if order[n-1] != 0 {
+		t.Errorf("Expected the center to be eliminated last. Got %v", order)
+	}
+
+	if fill := choleskyNonZeros(adjList, order); fill != 2*n-1 {
+		t.Errorf("Expected no fill. Got %d non-zeros", fill)
+	}
+}
+
+func TestAMDDenseNodesLast(t *testing.T) {
+	// Grid with an additional node connected to all nodes
+	n := 20
+	adjList := grid2D(n)
+	dense := n * n
+	adjList = append(adjList, make([]int, 0, n*n))
+	for i := 0; i < n*n; i++ {
+		adjList[dense] = append(adjList[dense], i)
+	}
+
+	order := AMD(adjList, nil)
+	if !isPermutation(order, n*n+1) {
+		t.Fatalf("The ordering is not a permutation")
+	}
+
+	if order[n*n] != dense {
+		t.Errorf("Expected the dense node to be last, got %d", order[n*n])
+	}
+}
+
+func TestAMDDisconnectedAndIsolatedNodes(t *testing.T) {
+	// Two chains, an isolated node, self loops and duplicated and one-sided edges
+	adjList := [][]int{{1, 1}, {2}, {}, {3}, {5}, {}, {}}
+	order := AMD(adjList, nil)
+	if !isPermutation(order, len(adjList)) {
+		t.Fatalf("The ordering is not a permutation: %v", order)
+	}
+
+	symmetric := [][]int{{1}, {0, 2}, {1}, {}, {5}, {4}, {}}
+	if fill := choleskyNonZeros(symmetric, order); fill != 10 {
+		t.Errorf("Expected no fill. Got %d non-zeros with order %v", fill, order)
+	}
+
+	if order := AMD(nil, nil); len(order) != 0 {
+		t.Errorf("Expected an empty ordering got %v", order)
+	}
+}
+
+func TestAMDIsDeterministic(t *testing.T) {
+	adjList := grid2D(40)
+	want := AMD(adjList, nil)
+	for i := 0; i < 3; i++ {
+		if got := AMD(adjList, nil); !slices.Equal(got, want) {
+			t.Fatalf("The ordering changed between runs")
+		}
+	}
+}
+
+func TestTryAMDErrors(t *testing.T) {
+	order, err := TryAMD([][]int{{1}, {2}}, nil)
+	if order != nil {
+		t.Errorf("Expected nil order got %v", order)
+	}
+
+	var invalid ErrInvalidAdjacency
+	if !errors.As(err, &invalid) || invalid.Node != 1 {
+		t.Errorf("Wanted ErrInvalidAdjacency at node 1 got %v", err)
+	}
+}
+
+func TestCSRAdjacencyList(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		matrix := property.DenseSquareMatrix(t, 2, 30)
+		n, _ := matrix.Dims()
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				if rapid.IntRange(0, 3).Draw(t, "zero") != 0 {
+					matrix.Set(i, j, 0.0)
+				}
+			}
+		}
+
+		doer := &precondtest.DenseNonZeroDoer{Dense: matrix}
+		dok := sparse.NewDOK(n, n)
+		doer.DoNonZero(func(i, j int, v float64) {
+			dok.Set(i, j, v)
+		})
+		got := CSRAdjacencyList(dok.ToCSR())
+		want := AdjacencyList(doer)
+		if len(got) != n {
+			t.Fatalf("Expected %d lists got %d", n, len(got))
+		}
+
+		for i := range got {
+			var expect []int
+			if i < len(want) {
+				expect = slices.Sorted(slices.Values(want[i]))
+			}
+
+			if !slices.Equal(got[i], expect) && len(got[i])+len(expect) > 0 {
+				t.Fatalf("Node %d: wanted %v got %v", i, expect, got[i])
+			}
+		}
+	})
+}
+
+func BenchmarkAMD(b *testing.B) {
+	// Grid with one million nodes
+	adjList := grid2D(1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		AMD(adjList, nil)
+	}
+}
+
+// gridCSR returns the 5-point Laplacian on a n x n grid
+func gridCSR(n int) *sparse.CSR {
+	adjList := grid2D(n)
+	indptr := make([]int, 1, n*n+1)
+	ind := make([]int, 0, 5*n*n)
+	data := make([]float64, 0, 5*n*n)
+	for i, neighbours := range adjList {
+		ind = append(ind, i)
+		data = append(data, 4.0)
+		for _, j := range neighbours {
+			ind = append(ind, j)
+			data = append(data, -1.0)
+		}
+		indptr = append(indptr, len(ind))
+	}
+	return sparse.NewCSR(n*n, n*n, indptr, ind, data)
+}
+
+func TestAMDAppliedToCSR(t *testing.T) {
+	n := 100
+	A := gridCSR(n)
+	pivot := precond.Pivot{Pivots: AMD(CSRAdjacencyList(A), nil)}
+	permuted := pivot.PermuteSymmetric(A)
+	if permuted.NNZ() != A.NNZ() {
+		t.Fatalf("Expected %d non-zeros got %d", A.NNZ(), permuted.NNZ())
+	}
+
+	permuted.DoNonZero(func(i, j int, v float64) {
+		if want := A.At(pivot.Pivots[i], pivot.Pivots[j]); v != want {
+			t.Fatalf("Entry (%d, %d): wanted %f got %f", i, j, want, v)
+		}
+	})
+}
+
+func BenchmarkPermuteSymmetric(b *testing.B) {
+	// Grid with one million nodes
+	A := gridCSR(1000)
+	pivot := precond.Pivot{Pivots: AMD(CSRAdjacencyList(A), nil)}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pivot.PermuteSymmetric(A)
+	}
+}
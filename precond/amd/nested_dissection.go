@@ -0,0 +1,673 @@
+package amd
+
+import "golang.org/x/exp/rand"
+
+// NestedDissectionSettings controls the nested dissection ordering
+type NestedDissectionSettings struct {
+	// Subgraphs with at most LeafSize nodes are ordered with AMD instead of being
+	// dissected further. Defaults to 100
+	LeafSize int
+
+	// Graphs are coarsened until they have at most CoarsestSize nodes, before the
+	// initial bisection is calculated. Defaults to 60
+	CoarsestSize int
+
+	// Allowed imbalance between the two halves of a bisection. The weight of each half
+	// is at most (1 + Imbalance) / 2 times the total weight. Defaults to 0.1
+	Imbalance float64
+
+	// Maximum number of Fiduccia-Mattheyses passes on each level. Defaults to 8
+	RefinementPasses int
+}
+
+func (s *NestedDissectionSettings) withDefaults() NestedDissectionSettings {
+	result := NestedDissectionSettings{
+		LeafSize:         100,
+		CoarsestSize:     60,
+		Imbalance:        0.1,
+		RefinementPasses: 8,
+	}
+
+	if s == nil {
+		return result
+	}
+
+	if s.LeafSize > 0 {
+		result.LeafSize = s.LeafSize
+	}
+	if s.CoarsestSize > 0 {
+		result.CoarsestSize = s.CoarsestSize
+	}
+	if s.Imbalance > 0.0 {
+		result.Imbalance = s.Imbalance
+	}
+	if s.RefinementPasses > 0 {
+		result.RefinementPasses = s.RefinementPasses
+	}
+	return result
+}
+
+// weightedGraph is a symmetric graph with weights on nodes and edges. The weight of a
+// coarse node is the number of fine nodes it represents, and the weight of a coarse edge
+// is the number of fine edges it represents
+type weightedGraph struct {
+	graph
+	edgeWeights []int
+	nodeWeights []int
+}
+
+// unitWeights returns g with unit weights on all nodes and edges
+func unitWeights(g graph) weightedGraph {
+	wg := weightedGraph{
+		graph:       g,
+		edgeWeights: make([]int, len(g.ind)),
+		nodeWeights: make([]int, g.numNodes()),
+	}
+	for i := range wg.edgeWeights {
+		wg.edgeWeights[i] = 1
+	}
+	for i := range wg.nodeWeights {
+		wg.nodeWeights[i] = 1
+	}
+	return wg
+}
+
+func (g *weightedGraph) totalWeight() int {
+	total := 0
+	for _, w := range g.nodeWeights {
+		total += w
+	}
+	return total
+}
+
+// coarsen matches each node with the unmatched neighbour connected by the heaviest edge.
+// Nodes are visited in random order. Matched pairs are merged into one coarse node, and
+// the coarse graph together with the coarse node of each fine node are returned
+func (g *weightedGraph) coarsen(rnd *rand.Rand) (weightedGraph, []int) {
+	n := g.numNodes()
+	match := make([]int, n)
+	for i := range match {
+		match[i] = -1
+	}
+
+	coarseOf := make([]int, n)
+	first := make([]int, 0, n)
+	for _, i := range rnd.Perm(n) {
+		if match[i] != -1 {
+			continue
+		}
+
+		best, bestWeight := i, 0
+		for k := g.indptr[i]; k < g.indptr[i+1]; k++ {
+			if j := g.ind[k]; match[j] == -1 && g.edgeWeights[k] > bestWeight {
+				best, bestWeight = j, g.edgeWeights[k]
+			}
+		}
+
+		match[i], match[best] = best, i
+		coarseOf[i], coarseOf[best] = len(first), len(first)
+		first = append(first, i)
+	}
+
+	nc := len(first)
+	coarse := weightedGraph{
+		graph:       graph{indptr: make([]int, 1, nc+1), ind: make([]int, 0, len(g.ind))},
+		edgeWeights: make([]int, 0, len(g.ind)),
+		nodeWeights: make([]int, nc),
+	}
+
+	// Position of each coarse neighbour in the current row
+	mark := make([]int, nc)
+	pos := make([]int, nc)
+	for i := range mark {
+		mark[i] = -1
+	}
+
+	for c, i := range first {
+		for m := i; ; m = match[i] {
+			coarse.nodeWeights[c] += g.nodeWeights[m]
+			for k := g.indptr[m]; k < g.indptr[m+1]; k++ {
+				cj := coarseOf[g.ind[k]]
+				if cj == c {
+					continue
+				}
+
+				if mark[cj] != c {
+					mark[cj] = c
+					pos[cj] = len(coarse.ind)
+					coarse.ind = append(coarse.ind, cj)
+					coarse.edgeWeights = append(coarse.edgeWeights, g.edgeWeights[k])
+				} else {
+					coarse.edgeWeights[pos[cj]] += g.edgeWeights[k]
+				}
+			}
+
+			// The second member is the matched neighbour, if there is one
+			if m != i || match[i] == i {
+				break
+			}
+		}
+		coarse.indptr = append(coarse.indptr, len(coarse.ind))
+	}
+	return coarse, coarseOf
+}
+
+// growBisection assigns nodes to part 0 by a breadth first search from start, until part 0
+// holds half of the total weight. If the search runs out of nodes, it continues from the
+// next node that is not yet assigned
+func (g *weightedGraph) growBisection(start int) []int {
+	n := g.numNodes()
+	part := make([]int, n)
+	for i := range part {
+		part[i] = 1
+	}
+
+	half := g.totalWeight() / 2
+	weight := 0
+	queue := make([]int, 0, n)
+	next := 0
+	for weight < half {
+		if len(queue) == 0 {
+			if part[start] == 0 {
+				for part[next] == 0 {
+					next++
+				}
+				start = next
+			}
+			part[start] = 0
+			weight += g.nodeWeights[start]
+			queue = append(queue, start)
+			continue
+		}
+
+		i := queue[0]
+		queue = queue[1:]
+		for _, j := range g.neighbours(i) {
+			if part[j] == 1 && weight < half {
+				part[j] = 0
+				weight += g.nodeWeights[j]
+				queue = append(queue, j)
+			}
+		}
+	}
+	return part
+}
+
+// gainItem is an entry in the priority queue of the Fiduccia-Mattheyses refinement
+type gainItem struct {
+	node int
+	gain int
+}
+
+// gainHeap is a max-heap of gains. Ties are broken by the lowest node index such that
+// the refinement is deterministic. The heap is implemented directly on the slice, since
+// container/heap allocates when the items are converted to interfaces
+type gainHeap []gainItem
+
+func (h gainHeap) less(i, j int) bool {
+	if h[i].gain != h[j].gain {
+		return h[i].gain > h[j].gain
+	}
+	return h[i].node < h[j].node
+}
+
+func (h gainHeap) down(i int) {
+	for {
+		child := 2*i + 1
+		if child >= len(h) {
+			return
+		}
+		if child+1 < len(h) && h.less(child+1, child) {
+			child++
+		}
+		if !h.less(child, i) {
+			return
+		}
+		h[i], h[child] = h[child], h[i]
+		i = child
+	}
+}
+
+func (h gainHeap) init() {
+	for i := len(h)/2 - 1; i >= 0; i-- {
+		h.down(i)
+	}
+}
+
+func (h *gainHeap) push(item gainItem) {
+	*h = append(*h, item)
+	q := *h
+	for i := len(q) - 1; i > 0; {
+		parent := (i - 1) / 2
+		if !q.less(i, parent) {
+			break
+		}
+		q[i], q[parent] = q[parent], q[i]
+		i = parent
+	}
+}
+
+func (h *gainHeap) pop() gainItem {
+	q := *h
+	item := q[0]
+	last := len(q) - 1
+	q[0] = q[last]
+	*h = q[:last]
+	h.down(0)
+	return item
+}
+
+// bisection is a partition of a weighted graph into part 0 and 1
+type bisection struct {
+	g    *weightedGraph
+	part []int
+
+	// Total node weight in each part
+	weights [2]int
+
+	// Reduction of the cut if the node is moved to the other part
+	gain []int
+	cut  int
+}
+
+func newBisection(g *weightedGraph, part []int) *bisection {
+	b := &bisection{g: g, part: part, gain: make([]int, g.numNodes())}
+	for i, p := range part {
+		b.weights[p] += g.nodeWeights[i]
+		for k := g.indptr[i]; k < g.indptr[i+1]; k++ {
+			if part[g.ind[k]] == p {
+				b.gain[i] -= g.edgeWeights[k]
+			} else {
+				b.gain[i] += g.edgeWeights[k]
+				b.cut += g.edgeWeights[k]
+			}
+		}
+	}
+
+	// All cut edges are counted from both sides
+	b.cut /= 2
+	return b
+}
+
+// move moves node i to the other part and updates the gains of its neighbours
+func (b *bisection) move(i int) {
+	g := b.g
+	from := b.part[i]
+	to := 1 - from
+	b.part[i] = to
+	b.weights[from] -= g.nodeWeights[i]
+	b.weights[to] += g.nodeWeights[i]
+	b.cut -= b.gain[i]
+	b.gain[i] = -b.gain[i]
+
+	for k := g.indptr[i]; k < g.indptr[i+1]; k++ {
+		j := g.ind[k]
+		if b.part[j] == to {
+			b.gain[j] -= 2 * g.edgeWeights[k]
+		} else {
+			b.gain[j] += 2 * g.edgeWeights[k]
+		}
+	}
+}
+
+func (b *bisection) imbalance() int {
+	return max(b.weights[0]-b.weights[1], b.weights[1]-b.weights[0])
+}
+
+// refine improves the cut with Fiduccia-Mattheyses passes. In each pass nodes are moved
+// one at a time in order of decreasing gain, as long as the part they move to does not
+// exceed maxWeight. Each node is moved at most once per pass, and the pass is rolled back
+// to the point with the smallest cut. The passes stop when the cut is not improved
+func (b *bisection) refine(maxWeight, passes int) {
+	g := b.g
+	n := g.numNodes()
+	locked := make([]int, n)
+	for i := range locked {
+		locked[i] = -1
+	}
+
+	// Number of moves without improvement before the pass is stopped
+	maxFutile := max(50, n/100)
+
+	moves := make([]int, 0)
+	h := make(gainHeap, 0)
+	for pass := 0; pass < passes; pass++ {
+		h = h[:0]
+		for i := 0; i < n; i++ {
+			if b.isBoundary(i) {
+				h = append(h, gainItem{node: i, gain: b.gain[i]})
+			}
+		}
+		h.init()
+
+		moves = moves[:0]
+		bestCut, bestImbalance, bestMoves := b.cut, b.imbalance(), 0
+		for len(h) > 0 && len(moves)-bestMoves < maxFutile {
+			item := h.pop()
+			i := item.node
+			if locked[i] == pass || item.gain != b.gain[i] {
+				// Outdated entry
+				continue
+			}
+
+			to := 1 - b.part[i]
+			if b.weights[to]+g.nodeWeights[i] > maxWeight {
+				continue
+			}
+
+			b.move(i)
+			locked[i] = pass
+			moves = append(moves, i)
+			for _, j := range g.neighbours(i) {
+				if locked[j] != pass {
+					h.push(gainItem{node: j, gain: b.gain[j]})
+				}
+			}
+
+			if b.cut < bestCut || (b.cut == bestCut && b.imbalance() < bestImbalance) {
+				bestCut, bestImbalance, bestMoves = b.cut, b.imbalance(), len(moves)
+			}
+		}
+
+		for k := len(moves) - 1; k >= bestMoves; k-- {
+			b.move(moves[k])
+		}
+
+		if bestMoves == 0 {
+			break
+		}
+	}
+}
+
+func (b *bisection) isBoundary(i int) bool {
+	for _, j := range b.g.neighbours(i) {
+		if b.part[j] != b.part[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// maxPartWeight returns the largest allowed weight of a part. The heaviest node is
+// added to the limit, such that a balanced bisection always exists on coarse levels
+func maxPartWeight(g *weightedGraph, imbalance float64) int {
+	heaviest := 0
+	for _, w := range g.nodeWeights {
+		heaviest = max(heaviest, w)
+	}
+	total := g.totalWeight()
+	return max(int((1.0+imbalance)*float64(total)/2.0), (total+1)/2+heaviest)
+}
+
+// multilevelBisection coarsens the graph, bisects the coarsest graph by growing a part
+// from several start nodes and refines the bisection while projecting it back to the
+// finer levels
+func multilevelBisection(g *weightedGraph, opts NestedDissectionSettings, rnd *rand.Rand) []int {
+	levels := []*weightedGraph{g}
+	coarseOf := make([][]int, 0)
+	for {
+		fine := levels[len(levels)-1]
+		if fine.numNodes() <= opts.CoarsestSize {
+			break
+		}
+
+		coarse, mapping := fine.coarsen(rnd)
+		if 10*coarse.numNodes() > 9*fine.numNodes() {
+			// The coarsening stagnated
+			break
+		}
+		levels = append(levels, &coarse)
+		coarseOf = append(coarseOf, mapping)
+	}
+
+	coarsest := levels[len(levels)-1]
+	maxWeight := maxPartWeight(coarsest, opts.Imbalance)
+	var best *bisection
+	for trial := 0; trial < 4; trial++ {
+		b := newBisection(coarsest, coarsest.growBisection(rnd.Intn(coarsest.numNodes())))
+		b.refine(maxWeight, opts.RefinementPasses)
+		if best == nil || b.cut < best.cut || (b.cut == best.cut && b.imbalance() < best.imbalance()) {
+			best = b
+		}
+	}
+
+	part := best.part
+	for l := len(levels) - 2; l >= 0; l-- {
+		fine := levels[l]
+		finePart := make([]int, fine.numNodes())
+		for i, c := range coarseOf[l] {
+			finePart[i] = part[c]
+		}
+
+		b := newBisection(fine, finePart)
+		b.refine(maxPartWeight(fine, opts.Imbalance), opts.RefinementPasses)
+		part = b.part
+	}
+	return part
+}
+
+// vertexSeparator converts the edge separator given by part to a vertex separator. The
+// cut edges form a bipartite graph between the boundary nodes of the two parts, and the
+// separator is a minimum vertex cover of this graph found from a maximum matching by
+// the theorem of König. The part of the separator nodes is set to 2
+func vertexSeparator(g *graph, part []int) {
+	n := g.numNodes()
+	matchOf := make([]int, n)
+	for i := range matchOf {
+		matchOf[i] = -1
+	}
+
+	left := make([]int, 0)
+	for i := 0; i < n; i++ {
+		if part[i] != 0 {
+			continue
+		}
+		for _, j := range g.neighbours(i) {
+			if part[j] == 1 {
+				left = append(left, i)
+				break
+			}
+		}
+	}
+
+	// Greedy initial matching followed by augmenting paths
+	for _, u := range left {
+		for _, v := range g.neighbours(u) {
+			if part[v] == 1 && matchOf[v] == -1 {
+				matchOf[u], matchOf[v] = v, u
+				break
+			}
+		}
+	}
+
+	visited := make([]int, n)
+	for i := range visited {
+		visited[i] = -1
+	}
+
+	var augment func(u, stamp int) bool
+	augment = func(u, stamp int) bool {
+		for _, v := range g.neighbours(u) {
+			if part[v] != 1 || visited[v] == stamp {
+				continue
+			}
+			visited[v] = stamp
+			if matchOf[v] == -1 || augment(matchOf[v], stamp) {
+				matchOf[u], matchOf[v] = v, u
+				return true
+			}
+		}
+		return false
+	}
+
+	for stamp, u := range left {
+		if matchOf[u] == -1 {
+			augment(u, stamp)
+		}
+	}
+
+	// Alternating search from the unmatched left nodes. The cover consists of the
+	// left nodes that are not reached and the right nodes that are reached
+	reached := make([]bool, n)
+	queue := make([]int, 0)
+	for _, u := range left {
+		if matchOf[u] == -1 {
+			reached[u] = true
+			queue = append(queue, u)
+		}
+	}
+
+	for len(queue) > 0 {
+		u := queue[0]
+		queue = queue[1:]
+		for _, v := range g.neighbours(u) {
+			if part[v] != 1 || reached[v] {
+				continue
+			}
+			reached[v] = true
+			if w := matchOf[v]; w != -1 && !reached[w] {
+				reached[w] = true
+				queue = append(queue, w)
+			}
+		}
+	}
+
+	for _, u := range left {
+		if !reached[u] {
+			part[u] = 2
+		}
+	}
+	for i := 0; i < n; i++ {
+		if part[i] == 1 && reached[i] {
+			part[i] = 2
+		}
+	}
+}
+
+// subgraph returns the graph induced by nodes. local must have length g.numNodes()
+// and be -1 for all nodes. It is restored before returning
+func (g *graph) subgraph(nodes []int, local []int) graph {
+	for k, i := range nodes {
+		local[i] = k
+	}
+
+	sub := graph{indptr: make([]int, 1, len(nodes)+1)}
+	for _, i := range nodes {
+		for _, j := range g.neighbours(i) {
+			if local[j] != -1 {
+				sub.ind = append(sub.ind, local[j])
+			}
+		}
+		sub.indptr = append(sub.indptr, len(sub.ind))
+	}
+
+	for _, i := range nodes {
+		local[i] = -1
+	}
+	return sub
+}
+
+// dissect orders the graph g, where labels holds the original node number of each
+// node, and appends the ordering to order
+func dissect(g graph, labels []int, opts NestedDissectionSettings, rnd *rand.Rand, order []int) []int {
+	n := g.numNodes()
+	if n <= opts.LeafSize {
+		return appendAMD(g, labels, order)
+	}
+
+	wg := unitWeights(g)
+	part := multilevelBisection(&wg, opts, rnd)
+	vertexSeparator(&g, part)
+
+	var nodes [3][]int
+	for i, p := range part {
+		nodes[p] = append(nodes[p], i)
+	}
+
+	if len(nodes[0]) == n || len(nodes[1]) == n {
+		// The graph could not be divided
+		return appendAMD(g, labels, order)
+	}
+
+	local := make([]int, n)
+	for i := range local {
+		local[i] = -1
+	}
+
+	for p := 0; p < 2; p++ {
+		if len(nodes[p]) == 0 {
+			continue
+		}
+
+		subLabels := make([]int, len(nodes[p]))
+		for k, i := range nodes[p] {
+			subLabels[k] = labels[i]
+		}
+		order = dissect(g.subgraph(nodes[p], local), subLabels, opts, rnd, order)
+	}
+
+	// The separator is eliminated last
+	for _, i := range nodes[2] {
+		order = append(order, labels[i])
+	}
+	return order
+}
+
+// appendAMD orders g with the approximate minimum degree algorithm
+func appendAMD(g graph, labels []int, order []int) []int {
+	n := g.numNodes()
+	adjList := make([][]int, n)
+	for i := 0; i < n; i++ {
+		adjList[i] = g.neighbours(i)
+	}
+
+	opts := (*Settings)(nil).withDefaults()
+	qg := newQuotientGraph(adjList, denseThreshold(n, opts.Dense))
+	for _, i := range qg.minimumDegreeOrder(!opts.NoAggressiveAbsorption) {
+		order = append(order, labels[i])
+	}
+	return order
+}
+
+// NestedDissection calculates a fill-reducing ordering of the graph given by adjList by
+// nested dissection. The graph is divided into two parts by a small vertex separator, the
+// two parts are ordered recursively and the separator is placed last. The separators are
+// found with a multilevel scheme: the graph is coarsened by heavy edge matching, the coarsest
+// graph is bisected by growing a part from a start node, and the bisection is refined with
+// Fiduccia-Mattheyses passes while it is projected back to the original graph. The edge
+// separator is turned into a vertex separator with a minimum vertex cover. Small subgraphs
+// are ordered with AMD. For large 2D and 3D meshes the fill grows more slowly with the size of
+// the mesh than with minimum degree orderings alone, and the separators give a well balanced
+// elimination tree.
+//
+// adjList[i] lists the neighbours of node i, as produced by AdjacencyList, and the number of
+// nodes is len(adjList). The pattern is symmetrized. The element order[k] is the node that is
+// eliminated in step k, thus the result can be used as a precond.Pivot. A fixed seed is used
+// for the random choices, such that the ordering is reproducible. If settings is nil, the
+// default settings are used. The method panics if a neighbour is out of range. Use
+// TryNestedDissection to get an error instead
+func NestedDissection(adjList [][]int, settings *NestedDissectionSettings) []int {
+	order, err := TryNestedDissection(adjList, settings)
+	if err != nil {
+		panic(err)
+	}
+	return order
+}
+
+// TryNestedDissection is the same as NestedDissection, except that it returns
+// ErrInvalidAdjacency instead of panicking when a neighbour is out of range
+func TryNestedDissection(adjList [][]int, settings *NestedDissectionSettings) ([]int, error) {
+	if err := checkNeighbours(adjList); err != nil {
+		return nil, err
+	}
+
+	opts := settings.withDefaults()
+	n := len(adjList)
+	labels := make([]int, n)
+	for i := range labels {
+		labels[i] = i
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	return dissect(symmetricGraph(adjList), labels, opts, rnd, make([]int, 0, n)), nil
+}
@@ -0,0 +1,196 @@
+package amd
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/davidkleiven/goprecond/precond/property"
+	"golang.org/x/exp/rand"
+	"pgregory.net/rapid"
+)
+
+// grid3D returns the adjacency list of the 7-point stencil on a n x n x n grid
+func grid3D(n int) [][]int {
+	adjList := make([][]int, n*n*n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			for k := 0; k < n; k++ {
+				node := (i*n+j)*n + k
+				if i > 0 {
+					adjList[node] = append(adjList[node], node-n*n)
+				}
+				if j > 0 {
+					adjList[node] = append(adjList[node], node-n)
+				}
+				if k > 0 {
+					adjList[node] = append(adjList[node], node-1)
+				}
+				if k < n-1 {
+					adjList[node] = append(adjList[node], node+1)
+				}
+				if j < n-1 {
+					adjList[node] = append(adjList[node], node+n)
+				}
+				if i < n-1 {
+					adjList[node] = append(adjList[node], node+n*n)
+				}
+			}
+		}
+	}
+	return adjList
+}
+
+func TestNestedDissectionReducesFillOnMeshes(t *testing.T) {
+	for _, test := range []struct {
+		adjList [][]int
+		desc    string
+	}{
+		{adjList: grid2D(60), desc: "2D grid"},
+		{adjList: grid3D(12), desc: "3D grid"},
+	} {
+		n := len(test.adjList)
+		order := NestedDissection(test.adjList, nil)
+		if !isPermutation(order, n) {
+			t.Fatalf("%s: the ordering is not a permutation", test.desc)
+		}
+
+		natural := choleskyNonZeros(test.adjList, slices.Sorted(slices.Values(order)))
+		fill := choleskyNonZeros(test.adjList, order)
+		if 2*fill > natural {
+			t.Errorf("%s: expected fill to be at least halved. Natural %d nested dissection %d", test.desc, natural, fill)
+		}
+
+		// On meshes of this size minimum degree and nested dissection give fill of the
+		// same magnitude
+		amd := choleskyNonZeros(test.adjList, AMD(test.adjList, nil))
+		if 2*fill > 3*amd {
+			t.Errorf("%s: expected fill comparable to AMD. AMD %d nested dissection %d", test.desc, amd, fill)
+		}
+	}
+}
+
+func TestGridSeparatorIsOneLine(t *testing.T) {
+	n := 31
+	g := symmetricGraph(grid2D(n))
+	wg := unitWeights(g)
+	opts := (*NestedDissectionSettings)(nil).withDefaults()
+	part := multilevelBisection(&wg, opts, rand.New(rand.NewSource(1)))
+	vertexSeparator(&g, part)
+
+	var sizes [3]int
+	for _, p := range part {
+		sizes[p]++
+	}
+
+	// The smallest separator of the grid is a single row or column
+	if sizes[2] != n {
+		t.Errorf("Expected a separator with %d nodes got %d", n, sizes[2])
+	}
+
+	if maxWeight := maxPartWeight(&wg, opts.Imbalance); sizes[0] > maxWeight || sizes[1] > maxWeight {
+		t.Errorf("Expected a balanced bisection. Got parts of size %v", sizes)
+	}
+}
+
+func TestVertexSeparator(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		matrix := property.SparseSymmetricMatrix(t, 2, 80)
+		g := symmetricGraph(property.SparseMatToAdjList(matrix))
+		wg := unitWeights(g)
+		part := multilevelBisection(&wg, (*NestedDissectionSettings)(nil).withDefaults(), rand.New(rand.NewSource(1)))
+
+		cut := newBisection(&wg, slices.Clone(part)).cut
+		vertexSeparator(&g, part)
+
+		separator := 0
+		for i, p := range part {
+			if p == 2 {
+				separator++
+				continue
+			}
+			for _, j := range g.neighbours(i) {
+				if part[j] != 2 && part[j] != p {
+					t.Fatalf("Nodes %d and %d are in different parts and not separated", i, j)
+				}
+			}
+		}
+
+		// The separator is a minimum vertex cover of the cut edges
+		if separator > cut {
+			t.Fatalf("The separator has %d nodes but only %d edges are cut", separator, cut)
+		}
+	})
+}
+
+func TestNestedDissectionProperties(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		matrix := property.SparseSymmetricMatrix(t, 1, 80)
+		adjList := property.SparseMatToAdjList(matrix)
+		settings := &NestedDissectionSettings{
+			LeafSize:     rapid.IntRange(1, 20).Draw(t, "leaf"),
+			CoarsestSize: rapid.IntRange(1, 20).Draw(t, "coarsest"),
+		}
+
+		if order := NestedDissection(adjList, settings); !isPermutation(order, len(adjList)) {
+			t.Fatalf("The ordering is not a permutation: %v", order)
+		}
+	})
+}
+
+func TestNestedDissectionDisconnectedAndIsolatedNodes(t *testing.T) {
+	// Two grids without connections between them and isolated nodes
+	n := 20
+	adjList := grid2D(n)
+	for _, neighbours := range grid2D(n) {
+		shifted := make([]int, len(neighbours))
+		for k, j := range neighbours {
+			shifted[k] = j + n*n
+		}
+		adjList = append(adjList, shifted)
+	}
+	adjList = append(adjList, make([][]int, 5)...)
+
+	order := NestedDissection(adjList, &NestedDissectionSettings{LeafSize: 10})
+	if !isPermutation(order, len(adjList)) {
+		t.Fatalf("The ordering is not a permutation")
+	}
+
+	if order := NestedDissection(make([][]int, 500), nil); !isPermutation(order, 500) {
+		t.Errorf("Expected a permutation of a graph without edges")
+	}
+
+	if order := NestedDissection(nil, nil); len(order) != 0 {
+		t.Errorf("Expected an empty ordering got %v", order)
+	}
+}
+
+func TestNestedDissectionIsDeterministic(t *testing.T) {
+	adjList := grid2D(40)
+	want := NestedDissection(adjList, nil)
+	for i := 0; i < 3; i++ {
+		if got := NestedDissection(adjList, nil); !slices.Equal(got, want) {
+			t.Fatalf("The ordering changed between runs")
+		}
+	}
+}
+
+func TestTryNestedDissectionErrors(t *testing.T) {
+	order, err := TryNestedDissection([][]int{{1}, {0, 5}}, nil)
+	if order != nil {
+		t.Errorf("Expected nil order got %v", order)
+	}
+
+	var invalid ErrInvalidAdjacency
+	if !errors.As(err, &invalid) || invalid.Node != 1 {
+		t.Errorf("Wanted ErrInvalidAdjacency at node 1 got %v", err)
+	}
+}
+
+func BenchmarkNestedDissection(b *testing.B) {
+	adjList := grid2D(300)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NestedDissection(adjList, nil)
+	}
+}
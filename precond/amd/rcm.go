@@ -0,0 +1,145 @@
+package amd
+
+import "slices"
+
+// levelStructure is the result of a breadth first search from a root node. The nodes
+// in level l are nodes[start[l]:start[l+1]]
+type levelStructure struct {
+	nodes []int
+	start []int
+}
+
+func (ls *levelStructure) numLevels() int {
+	return len(ls.start) - 1
+}
+
+func (ls *levelStructure) lastLevel() []int {
+	l := ls.numLevels() - 1
+	return ls.nodes[ls.start[l]:ls.start[l+1]]
+}
+
+// rcmWorkspace holds the work arrays used when ordering the components of a graph
+type rcmWorkspace struct {
+	g graph
+
+	// mark[i] equals stamp for nodes visited in the current breadth first search
+	mark  []int
+	stamp int
+
+	// Nodes that are already part of the ordering
+	ordered []bool
+}
+
+// levels returns the level structure rooted at root. Only nodes that are not yet
+// ordered are visited
+func (ws *rcmWorkspace) levels(root int, ls *levelStructure) {
+	ws.stamp++
+	ls.nodes = append(ls.nodes[:0], root)
+	ls.start = ls.start[:0]
+	ws.mark[root] = ws.stamp
+
+	for begin := 0; begin < len(ls.nodes); {
+		end := len(ls.nodes)
+		ls.start = append(ls.start, begin)
+		for _, i := range ls.nodes[begin:end] {
+			for _, j := range ws.g.neighbours(i) {
+				if ws.mark[j] != ws.stamp && !ws.ordered[j] {
+					ws.mark[j] = ws.stamp
+					ls.nodes = append(ls.nodes, j)
+				}
+			}
+		}
+		begin = end
+	}
+	ls.start = append(ls.start, len(ls.nodes))
+}
+
+// pseudoPeripheralNode finds a node with large eccentricity in the component of start
+// with the algorithm of George and Liu. A node of minimum degree in the last level of
+// the level structure is used as the next root, as long as the number of levels increases
+func (ws *rcmWorkspace) pseudoPeripheralNode(start int) int {
+	var current, candidate levelStructure
+	root := start
+	ws.levels(root, &current)
+	for {
+		last := current.lastLevel()
+		next := last[0]
+		for _, i := range last[1:] {
+			if ws.g.degree(i) < ws.g.degree(next) {
+				next = i
+			}
+		}
+
+		ws.levels(next, &candidate)
+		if candidate.numLevels() <= current.numLevels() {
+			return root
+		}
+		root = next
+		current, candidate = candidate, current
+	}
+}
+
+// ReverseCuthillMcKee calculates the reverse Cuthill-McKee ordering of the graph given by
+// adjList, which reduces the bandwidth and profile of the matrix. adjList[i] lists the
+// neighbours of node i, and the number of nodes is len(adjList). Nodes without neighbours
+// are allowed and the pattern is symmetrized. Each connected component is ordered by a
+// breadth first search starting at a pseudo-peripheral node, where the neighbours of a node
+// are visited in order of increasing degree. The element order[k] is the node placed at
+// position k, thus the result can be used as a precond.Pivot. The method panics if a
+// neighbour is out of range. Use TryReverseCuthillMcKee to get an error instead
+func ReverseCuthillMcKee(adjList [][]int) []int {
+	order, err := TryReverseCuthillMcKee(adjList)
+	if err != nil {
+		panic(err)
+	}
+	return order
+}
+
+// TryReverseCuthillMcKee is the same as ReverseCuthillMcKee, except that it returns
+// ErrInvalidAdjacency instead of panicking when a neighbour is out of range
+func TryReverseCuthillMcKee(adjList [][]int) ([]int, error) {
+	if err := checkNeighbours(adjList); err != nil {
+		return nil, err
+	}
+
+	n := len(adjList)
+	ws := rcmWorkspace{
+		g:       symmetricGraph(adjList),
+		mark:    make([]int, n),
+		ordered: make([]bool, n),
+	}
+
+	order := make([]int, 0, n)
+	candidates := make([]int, 0)
+	for start := 0; start < n; start++ {
+		if ws.ordered[start] {
+			continue
+		}
+
+		root := ws.pseudoPeripheralNode(start)
+		ws.ordered[root] = true
+		first := len(order)
+		order = append(order, root)
+		for head := first; head < len(order); head++ {
+			candidates = candidates[:0]
+			for _, j := range ws.g.neighbours(order[head]) {
+				if !ws.ordered[j] {
+					ws.ordered[j] = true
+					candidates = append(candidates, j)
+				}
+			}
+
+			// Visit the neighbours with the lowest degree first. Ties are broken
+			// by the node number such that the ordering is deterministic
+			slices.SortFunc(candidates, func(a, b int) int {
+				if da, db := ws.g.degree(a), ws.g.degree(b); da != db {
+					return da - db
+				}
+				return a - b
+			})
+			order = append(order, candidates...)
+		}
+	}
+	slices.Reverse(order)
+	return order, nil
+}
@@ -0,0 +1,156 @@
+package amd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/davidkleiven/goprecond/precond"
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/mat"
+)
+
+// bandwidth returns the largest distance from the diagonal of an edge in the graph
+// after the nodes are reordered
+func bandwidth(adjList [][]int, order []int) int {
+	inv := make([]int, len(order))
+	for k, i := range order {
+		inv[i] = k
+	}
+
+	result := 0
+	for i, neighbours := range adjList {
+		for _, j := range neighbours {
+			result = max(result, inv[i]-inv[j], inv[j]-inv[i])
+		}
+	}
+	return result
+}
+
+// shuffled renumbers the nodes in the graph randomly
+func shuffled(adjList [][]int, seed uint64) [][]int {
+	rnd := rand.New(rand.NewSource(seed))
+	perm := rnd.Perm(len(adjList))
+	result := make([][]int, len(adjList))
+	for i, neighbours := range adjList {
+		for _, j := range neighbours {
+			result[perm[i]] = append(result[perm[i]], perm[j])
+		}
+	}
+	return result
+}
+
+func TestRCMRecoversChain(t *testing.T) {
+	n := 50
+	chain := make([][]int, n)
+	for i := 0; i+1 < n; i++ {
+		chain[i] = append(chain[i], i+1)
+	}
+	adjList := shuffled(chain, 1)
+
+	order := ReverseCuthillMcKee(adjList)
+	if !isPermutation(order, n) {
+		t.Fatalf("The ordering is not a permutation")
+	}
+
+	if b := bandwidth(adjList, order); b != 1 {
+		t.Errorf("Expected bandwidth 1 got %d", b)
+	}
+}
+
+func TestRCMReducesBandwidthOfGrid(t *testing.T) {
+	n := 30
+	adjList := shuffled(grid2D(n), 2)
+	order := ReverseCuthillMcKee(adjList)
+	if !isPermutation(order, n*n) {
+		t.Fatalf("The ordering is not a permutation")
+	}
+
+	// The bandwidth of the grid in natural ordering is n. A level structure from a
+	// corner has levels of at most n nodes, thus the bandwidth is less than 2n
+	if b := bandwidth(adjList, order); b >= 2*n {
+		t.Errorf("Expected bandwidth less than %d got %d", 2*n, b)
+	}
+}
+
+func TestPseudoPeripheralNode(t *testing.T) {
+	n := 10
+	adjList := grid2D(n)
+	ws := rcmWorkspace{
+		g:       symmetricGraph(adjList),
+		mark:    make([]int, n*n),
+		ordered: make([]bool, n*n),
+	}
+
+	// Starting in the middle should end in one of the corners
+	corners := map[int]bool{0: true, n - 1: true, n * (n - 1): true, n*n - 1: true}
+	if node := ws.pseudoPeripheralNode(n*n/2 + n/2); !corners[node] {
+		t.Errorf("Expected a corner got %d", node)
+	}
+}
+
+func TestRCMDisconnectedComponents(t *testing.T) {
+	// Two chains 0-2-4 and 1-3, and an isolated node 5
+	adjList := [][]int{{2}, {3}, {4}, {}, {}, {}}
+	order := ReverseCuthillMcKee(adjList)
+	if !isPermutation(order, 6) {
+		t.Fatalf("The ordering is not a permutation: %v", order)
+	}
+
+	// Each component is contiguous in the ordering
+	component := []int{0, 1, 0, 1, 0, 2}
+	changes := 0
+	for k := 1; k < len(order); k++ {
+		if component[order[k]] != component[order[k-1]] {
+			changes++
+		}
+	}
+	if changes != 2 {
+		t.Errorf("Expected contiguous components got %v", order)
+	}
+
+	if b := bandwidth(adjList, order); b != 1 {
+		t.Errorf("Expected bandwidth 1 got %d", b)
+	}
+}
+
+func TestRCMAsPivot(t *testing.T) {
+	n := 8
+	adjList := shuffled(grid2D(n), 3)
+	matrix := mat.NewDense(n*n, n*n, nil)
+	for i, neighbours := range adjList {
+		matrix.Set(i, i, 4.0)
+		for _, j := range neighbours {
+			matrix.Set(i, j, -1.0)
+			matrix.Set(j, i, -1.0)
+		}
+	}
+
+	pivot := precond.Pivot{Pivots: ReverseCuthillMcKee(adjList)}
+	var permuted mat.Dense
+	permuted.Product(&pivot, matrix, pivot.T())
+
+	want := bandwidth(adjList, pivot.Pivots)
+	got := 0
+	permuted.Apply(func(i, j int, v float64) float64 {
+		if v != 0.0 {
+			got = max(got, i-j, j-i)
+		}
+		return v
+	}, &permuted)
+
+	if got != want || got >= 2*n {
+		t.Errorf("Expected bandwidth %d of the permuted matrix got %d", want, got)
+	}
+}
+
+func TestTryRCMErrors(t *testing.T) {
+	order, err := TryReverseCuthillMcKee([][]int{{-1}})
+	if order != nil {
+		t.Errorf("Expected nil order got %v", order)
+	}
+
+	var invalid ErrInvalidAdjacency
+	if !errors.As(err, &invalid) || invalid.Node != 0 {
+		t.Errorf("Wanted ErrInvalidAdjacency at node 0 got %v", err)
+	}
+}
@@ -0,0 +1,441 @@
+// Package amg implements algebraic multigrid preconditioners. The preconditioners
+// build a hierarchy of successively coarser operators from the matrix alone, and
+// apply one multigrid cycle in each call to SolveVecTo
+package amg
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/davidkleiven/goprecond/precond"
+	"github.com/james-bowman/sparse"
+	"gonum.org/v1/gonum/mat"
+)
+
+// Cycle is the type of multigrid cycle
+type Cycle int
+
+const (
+	// VCycle visits each coarse level once per cycle
+	VCycle Cycle = iota
+
+	// WCycle visits each coarse level twice per cycle
+	WCycle
+)
+
+// Smoother is the relaxation method used on each level
+type Smoother int
+
+const (
+	// GaussSeidel uses a forward sweep for pre-smoothing and a backward
+	// sweep for post-smoothing, such that the cycle is symmetric
+	GaussSeidel Smoother = iota
+
+	// Jacobi uses weighted Jacobi relaxation
+	Jacobi
+)
+
+// ErrSingularCoarseMatrix is returned if the operator on the coarsest level is singular
+var ErrSingularCoarseMatrix = errors.New("the coarsest operator is singular")
+
+// Settings controls the setup of the hierarchy and the multigrid cycle
+type Settings struct {
+	// Threshold used to determine strong connections. Defaults to 0.25
+	StrengthThreshold float64
+
+	// Maximum number of levels in the hierarchy. Defaults to 25
+	MaxLevels int
+
+	// Coarsening stops when the number of unknowns is less than or equal to
+	// CoarsestSize. The coarsest level is solved with a dense LU decomposition. If the
+	// coarsening stops at a larger level, because MaxLevels is reached or the coarsening
+	// stagnates, the coarsest level is only smoothed. Defaults to 50
+	CoarsestSize int
+
+	Cycle    Cycle
+	Smoother Smoother
+
+	// Number of smoothing steps before and after the coarse grid correction.
+	// Both defaults to 1
+	PreSmoothingSteps  int
+	PostSmoothingSteps int
+
+	// Relaxation weight used by the Jacobi smoother. Defaults to 2/3
+	JacobiWeight float64
+
+	// Threshold used to determine strong connections between nodes in smoothed
+	// aggregation. Defaults to 0.08
+	AggregationThreshold float64
+
+	// Number of unknowns per node on the finest level. The unknowns of a node are
+	// always placed in the same aggregate in smoothed aggregation. For example, a
+	// 2D elasticity problem has two unknowns per node. Defaults to 1
+	BlockSize int
+}
+
+func (s *Settings) withDefaults() Settings {
+	result := Settings{
+		StrengthThreshold:    0.25,
+		MaxLevels:            25,
+		CoarsestSize:         50,
+		PreSmoothingSteps:    1,
+		PostSmoothingSteps:   1,
+		JacobiWeight:         2.0 / 3.0,
+		AggregationThreshold: 0.08,
+		BlockSize:            1,
+	}
+
+	if s == nil {
+		return result
+	}
+
+	if s.StrengthThreshold > 0.0 {
+		result.StrengthThreshold = s.StrengthThreshold
+	}
+	if s.MaxLevels > 0 {
+		result.MaxLevels = s.MaxLevels
+	}
+	if s.CoarsestSize > 0 {
+		result.CoarsestSize = s.CoarsestSize
+	}
+	if s.PreSmoothingSteps > 0 {
+		result.PreSmoothingSteps = s.PreSmoothingSteps
+	}
+	if s.PostSmoothingSteps > 0 {
+		result.PostSmoothingSteps = s.PostSmoothingSteps
+	}
+	if s.JacobiWeight > 0.0 {
+		result.JacobiWeight = s.JacobiWeight
+	}
+	if s.AggregationThreshold > 0.0 {
+		result.AggregationThreshold = s.AggregationThreshold
+	}
+	if s.BlockSize > 0 {
+		result.BlockSize = s.BlockSize
+	}
+	result.Cycle = s.Cycle
+	result.Smoother = s.Smoother
+	return result
+}
+
+// level is one level in the multigrid hierarchy
+type level struct {
+	a  *sparse.CSR
+	aT *sparse.CSR
+
+	// Prolongation from the next coarser level to this level, and the restriction
+	// from this level to the next coarser level. Both are nil on the coarsest level
+	p *sparse.CSR
+	r *sparse.CSR
+
+	diag []float64
+
+	// Work vectors
+	x   []float64
+	b   []float64
+	res []float64
+}
+
+// Preconditioner is an algebraic multigrid preconditioner. Each call to SolveVecTo
+// applies one multigrid cycle with a zero initial guess. The work vectors of the levels
+// are shared by copies of a preconditioner, thus it is not safe for concurrent use. Use
+// Clone to get a preconditioner for each goroutine
+type Preconditioner struct {
+	levels   []level
+	coarse   mat.LU
+	settings Settings
+
+	// The coarsest level is smoothed instead of solved if it is larger than CoarsestSize
+	smoothCoarsest bool
+
+	// Work vectors for the coarse solve
+	coarseRhs *mat.VecDense
+	coarseSol *mat.VecDense
+}
+
+// prolongator calculates the prolongation from the next coarser level to a level with
+// the operator a. A nil prolongator stops the coarsening
+type prolongator func(a *sparse.CSR) (*sparse.CSR, error)
+
+// newPreconditioner builds the hierarchy by repeatedly coarsening A with the
+// Galerkin product R A P, where R = P^T
+func newPreconditioner(A *sparse.CSR, opts Settings, coarsen prolongator) (Preconditioner, error) {
+	if err := checkMatrix(A); err != nil {
+		return Preconditioner{}, err
+	}
+
+	amg := Preconditioner{settings: opts}
+	a := A
+	for {
+		n, _ := a.Dims()
+		lvl := level{
+			a:    a,
+			diag: diagonal(a),
+			x:    make([]float64, n),
+			b:    make([]float64, n),
+			res:  make([]float64, n),
+		}
+
+		for i, d := range lvl.diag {
+			if d == 0.0 {
+				return Preconditioner{}, fmt.Errorf("level %d: %w", len(amg.levels), precond.ErrZeroPivot{Row: i})
+			}
+		}
+
+		if len(amg.levels)+1 >= opts.MaxLevels || n <= opts.CoarsestSize {
+			amg.levels = append(amg.levels, lvl)
+			break
+		}
+
+		p, err := coarsen(a)
+		if err != nil {
+			return Preconditioner{}, err
+		}
+
+		if p == nil || coarseDim(p) == 0 || coarseDim(p) >= n {
+			// The coarsening stagnated
+			amg.levels = append(amg.levels, lvl)
+			break
+		}
+
+		lvl.p = p
+		lvl.r = transpose(p)
+		amg.levels = append(amg.levels, lvl)
+		a = galerkin(lvl.r, a, p)
+	}
+
+	coarsest := amg.levels[len(amg.levels)-1].a
+	n, _ := coarsest.Dims()
+	if n > opts.CoarsestSize {
+		// A dense factorization of a large level is too expensive
+		amg.smoothCoarsest = true
+		return amg, nil
+	}
+
+	amg.coarse.Factorize(mat.DenseCopyOf(coarsest))
+	if amg.coarse.Cond() > mat.ConditionTolerance {
+		return Preconditioner{}, ErrSingularCoarseMatrix
+	}
+	amg.coarseRhs = mat.NewVecDense(n, nil)
+	amg.coarseSol = mat.NewVecDense(n, nil)
+	return amg, nil
+}
+
+// checkMatrix returns precond.ErrNotSquare if A is not square and precond.ErrInvalidArgument
+// if A is empty
+func checkMatrix(A *sparse.CSR) error {
+	r, c := A.Dims()
+	if r != c {
+		return precond.ErrNotSquare{Rows: r, Cols: c}
+	}
+	if r == 0 {
+		return fmt.Errorf("%w: the matrix is empty", precond.ErrInvalidArgument)
+	}
+	return nil
+}
+
+// coarseDim returns the number of unknowns on the coarse level of a prolongator
+func coarseDim(p *sparse.CSR) int {
+	_, nc := p.Dims()
+	return nc
+}
+
+// NumLevels returns the number of levels in the hierarchy
+func (amg *Preconditioner) NumLevels() int {
+	return len(amg.levels)
+}
+
+// Stats describes the multigrid hierarchy
+type Stats struct {
+	// Number of levels in the hierarchy
+	Levels int
+
+	// Number of rows and non-zero entries of the operator on each level
+	Rows     []int
+	NonZeros []int
+
+	// Total number of non-zero entries in all operators divided by the number
+	// of non-zero entries in the finest operator
+	OperatorComplexity float64
+
+	// Total number of unknowns on all levels divided by the number of unknowns
+	// on the finest level
+	GridComplexity float64
+}
+
+// Stats returns statistics of the multigrid hierarchy
+func (amg *Preconditioner) Stats() Stats {
+	stats := Stats{
+		Levels:   len(amg.levels),
+		Rows:     make([]int, len(amg.levels)),
+		NonZeros: make([]int, len(amg.levels)),
+	}
+
+	totalRows, totalNonZeros := 0, 0
+	for i, lvl := range amg.levels {
+		stats.Rows[i], _ = lvl.a.Dims()
+		stats.NonZeros[i] = lvl.a.NNZ()
+		totalRows += stats.Rows[i]
+		totalNonZeros += stats.NonZeros[i]
+	}
+
+	if stats.Rows[0] > 0 {
+		stats.GridComplexity = float64(totalRows) / float64(stats.Rows[0])
+	}
+	if stats.NonZeros[0] > 0 {
+		stats.OperatorComplexity = float64(totalNonZeros) / float64(stats.NonZeros[0])
+	}
+	return stats
+}
+
+func (amg *Preconditioner) initT() {
+	for i := range amg.levels {
+		if amg.levels[i].aT == nil {
+			amg.levels[i].aT = transpose(amg.levels[i].a)
+		}
+	}
+}
+
+// Clone returns a preconditioner that shares the hierarchy with the receiver, but has its
+// own work vectors, such that the clones can solve concurrently. Clone modifies the
+// receiver by creating the transposed operators, thus it must not be called concurrently
+// with SolveVecTo
+func (amg *Preconditioner) Clone() Preconditioner {
+	amg.initT()
+	clone := *amg
+	clone.levels = make([]level, len(amg.levels))
+	for i, lvl := range amg.levels {
+		n := len(lvl.x)
+		lvl.x = make([]float64, n)
+		lvl.b = make([]float64, n)
+		lvl.res = make([]float64, n)
+		clone.levels[i] = lvl
+	}
+
+	if amg.coarseRhs != nil {
+		n := amg.coarseRhs.Len()
+		clone.coarseRhs = mat.NewVecDense(n, nil)
+		clone.coarseSol = mat.NewVecDense(n, nil)
+	}
+	return clone
+}
+
+// SolveVecTo applies one multigrid cycle to the system Ax = b starting from x = 0.
+// When trans is true, the cycle is applied to A^T using the same prolongation and
+// restriction operators, since the coarse operators of A^T are the transposes of the
+// coarse operators of A
+func (amg *Preconditioner) SolveVecTo(dst *mat.VecDense, trans bool, rhs mat.Vector) error {
+	finest := &amg.levels[0]
+	n := len(finest.x)
+	dstDim, _ := dst.Dims()
+	rhsDim, _ := rhs.Dims()
+	if dstDim != n || rhsDim != n {
+		return fmt.Errorf("expected lengths to be %d, got dst: %d and rhs: %d", n, dstDim, rhsDim)
+	}
+
+	if trans {
+		amg.initT()
+	}
+
+	for i := range finest.b {
+		finest.b[i] = rhs.AtVec(i)
+	}
+	clear(finest.x)
+
+	if err := amg.cycle(0, trans); err != nil {
+		return err
+	}
+
+	for i, v := range finest.x {
+		dst.SetVec(i, v)
+	}
+	return nil
+}
+
+// cycle improves the solution stored in level l
+func (amg *Preconditioner) cycle(l int, trans bool) error {
+	lvl := &amg.levels[l]
+	a := lvl.a
+	if trans {
+		a = lvl.aT
+	}
+
+	if l == len(amg.levels)-1 && amg.smoothCoarsest {
+		for i := 0; i < amg.settings.PreSmoothingSteps; i++ {
+			amg.smooth(lvl, a, true)
+		}
+		for i := 0; i < amg.settings.PostSmoothingSteps; i++ {
+			amg.smooth(lvl, a, false)
+		}
+		return nil
+	}
+
+	if l == len(amg.levels)-1 {
+		copy(amg.coarseRhs.RawVector().Data, lvl.b)
+		if err := amg.coarse.SolveVecTo(amg.coarseSol, trans, amg.coarseRhs); err != nil {
+			return err
+		}
+		copy(lvl.x, amg.coarseSol.RawVector().Data)
+		return nil
+	}
+
+	for i := 0; i < amg.settings.PreSmoothingSteps; i++ {
+		amg.smooth(lvl, a, true)
+	}
+
+	residual(lvl.res, a, lvl.x, lvl.b)
+	next := &amg.levels[l+1]
+	mulVec(next.b, lvl.r, lvl.res)
+	clear(next.x)
+
+	visits := 1
+	if amg.settings.Cycle == WCycle {
+		visits = 2
+	}
+
+	for i := 0; i < visits; i++ {
+		if err := amg.cycle(l+1, trans); err != nil {
+			return err
+		}
+	}
+	mulVecAdd(lvl.x, lvl.p, next.x)
+
+	for i := 0; i < amg.settings.PostSmoothingSteps; i++ {
+		amg.smooth(lvl, a, false)
+	}
+	return nil
+}
+
+// smooth performs one relaxation step on the given level. Gauss-Seidel sweeps
+// forward for pre-smoothing and backward for post-smoothing
+func (amg *Preconditioner) smooth(lvl *level, a *sparse.CSR, pre bool) {
+	if amg.settings.Smoother == Jacobi {
+		residual(lvl.res, a, lvl.x, lvl.b)
+		w := amg.settings.JacobiWeight
+		for i, d := range lvl.diag {
+			lvl.x[i] += w * lvl.res[i] / d
+		}
+		return
+	}
+
+	raw := a.RawMatrix()
+	relax := func(i int) {
+		sum := lvl.b[i]
+		for k := raw.Indptr[i]; k < raw.Indptr[i+1]; k++ {
+			if j := raw.Ind[k]; j != i {
+				sum -= raw.Data[k] * lvl.x[j]
+			}
+		}
+		lvl.x[i] = sum / lvl.diag[i]
+	}
+
+	if pre {
+		for i := 0; i < raw.I; i++ {
+			relax(i)
+		}
+	} else {
+		for i := raw.I - 1; i >= 0; i-- {
+			relax(i)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package amg
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/davidkleiven/goprecond/precond"
+	"github.com/james-bowman/sparse"
+	"gonum.org/v1/exp/linsolve"
+	"gonum.org/v1/gonum/mat"
+)
+
+// poisson2D returns the 5-point finite difference discretization of the Poisson
+// equation on a n x n grid with Dirichlet boundary conditions
+func poisson2D(n int) *sparse.CSR {
+	dok := sparse.NewDOK(n*n, n*n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			row := i*n + j
+			dok.Set(row, row, 4.0)
+			if i > 0 {
+				dok.Set(row, row-n, -1.0)
+			}
+			if i < n-1 {
+				dok.Set(row, row+n, -1.0)
+			}
+			if j > 0 {
+				dok.Set(row, row-1, -1.0)
+			}
+			if j < n-1 {
+				dok.Set(row, row+1, -1.0)
+			}
+		}
+	}
+	return dok.ToCSR()
+}
+
+func ones(n int) *mat.VecDense {
+	v := mat.NewVecDense(n, nil)
+	for i := 0; i < n; i++ {
+		v.SetVec(i, 1.0)
+	}
+	return v
+}
+
+// convergenceFactor uses the preconditioner as a stationary iteration and
+// returns the average residual reduction per iteration
+func convergenceFactor(t *testing.T, A *sparse.CSR, amg *Preconditioner, trans bool, iterations int) float64 {
+	n, _ := A.Dims()
+	matrix := A
+	if trans {
+		matrix = transpose(A)
+	}
+
+	rhs := ones(n)
+	x := mat.NewVecDense(n, nil)
+	res := mat.NewVecDense(n, nil)
+	correction := mat.NewVecDense(n, nil)
+
+	res.CopyVec(rhs)
+	initial := mat.Norm(res, 2)
+	for it := 0; it < iterations; it++ {
+		if err := amg.SolveVecTo(correction, trans, res); err != nil {
+			t.Fatalf("%v", err)
+		}
+		x.AddVec(x, correction)
+		residual(res.RawVector().Data, matrix, x.RawVector().Data, rhs.RawVector().Data)
+	}
+	return math.Pow(mat.Norm(res, 2)/initial, 1.0/float64(iterations))
+}
+
+func TestMultiply(t *testing.T) {
+	a := mat.NewDense(2, 3, []float64{1.0, 0.0, 2.0, 0.0, 3.0, 0.0})
+	b := mat.NewDense(3, 2, []float64{0.0, 1.0, 4.0, 0.0, 5.0, 6.0})
+
+	var want mat.Dense
+	want.Mul(a, b)
+	got := multiply(toCSR(a), toCSR(b))
+	if !mat.EqualApprox(got, &want, 1e-12) {
+		t.Errorf("Wanted\n%v\ngot\n%v\n", mat.Formatted(&want), mat.Formatted(got))
+	}
+
+	if !mat.EqualApprox(transpose(toCSR(a)), a.T(), 1e-12) {
+		t.Errorf("Wanted\n%v\ngot\n%v\n", mat.Formatted(a.T()), mat.Formatted(transpose(toCSR(a))))
+	}
+}
+
+func toCSR(m mat.Matrix) *sparse.CSR {
+	r, c := m.Dims()
+	dok := sparse.NewDOK(r, c)
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			if v := m.At(i, j); v != 0.0 {
+				dok.Set(i, j, v)
+			}
+		}
+	}
+	return dok.ToCSR()
+}
+
+func TestRugeStubenSplitting(t *testing.T) {
+	A := poisson2D(10)
+	s := classicalStrength(A, 0.25)
+	state := rugeStubenSplitting(s)
+
+	numCoarse := 0
+	for i, st := range state {
+		if st == coarsePoint {
+			numCoarse++
+			continue
+		}
+
+		// Each fine point must have a strongly connected coarse point
+		hasCoarse := false
+		for _, j := range s.neighbours(i) {
+			hasCoarse = hasCoarse || state[j] == coarsePoint
+		}
+		if !hasCoarse {
+			t.Errorf("Fine point %d has no strongly connected coarse point", i)
+		}
+	}
+
+	if numCoarse == 0 || numCoarse >= 100 {
+		t.Errorf("Expected coarsening, got %d coarse points", numCoarse)
+	}
+
+	// The interpolation preserves constants for rows with zero row sum
+	p := classicalInterpolation(A, s, state)
+	_, nc := p.Dims()
+	interpolated := make([]float64, 100)
+	mulVec(interpolated, p, ones(nc).RawVector().Data)
+	for i := 11; i < 89; i++ {
+		if row, col := i/10, i%10; row == 0 || row == 9 || col == 0 || col == 9 {
+			continue
+		}
+		if math.Abs(interpolated[i]-1.0) > 1e-12 {
+			t.Errorf("Interior point %d: wanted 1 got %v", i, interpolated[i])
+		}
+	}
+}
+
+func TestRugeStubenMeshIndependentConvergence(t *testing.T) {
+	for _, test := range []struct {
+		settings *Settings
+		desc     string
+	}{
+		{settings: nil, desc: "V-cycle"},
+		{settings: &Settings{Cycle: WCycle}, desc: "W-cycle"},
+		{settings: &Settings{Smoother: Jacobi, PreSmoothingSteps: 2, PostSmoothingSteps: 2}, desc: "Jacobi"},
+	} {
+		for _, n := range []int{16, 32, 64} {
+			t.Run(fmt.Sprintf("%s size %d", test.desc, n), func(t *testing.T) {
+				A := poisson2D(n)
+				amg := RugeStuben(A, test.settings)
+				if amg.NumLevels() < 2 {
+					t.Errorf("Expected more than one level, got %d", amg.NumLevels())
+				}
+
+				if factor := convergenceFactor(t, A, &amg, false, 10); factor > 0.5 {
+					t.Errorf("Expected a convergence factor below 0.5, got %v", factor)
+				}
+			})
+		}
+	}
+}
+
+func TestRugeStubenSingleLevelIsExact(t *testing.T) {
+	A := poisson2D(5)
+	amg := RugeStuben(A, &Settings{CoarsestSize: 25})
+	if amg.NumLevels() != 1 {
+		t.Errorf("Expected one level, got %d", amg.NumLevels())
+	}
+
+	rhs := ones(25)
+	for _, trans := range []bool{true, false} {
+		x := mat.NewVecDense(25, nil)
+		if err := amg.SolveVecTo(x, trans, rhs); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+
+		got := mat.NewVecDense(25, nil)
+		got.MulVec(A, x)
+		if !mat.EqualApprox(got, rhs, 1e-10) {
+			t.Errorf("Wanted\n%v\ngot\n%v\n", mat.Formatted(rhs), mat.Formatted(got))
+		}
+	}
+}
+
+func TestLargeCoarsestLevelIsSmoothed(t *testing.T) {
+	// The nodes of a diagonal matrix are isolated, thus the coarsening stagnates on the
+	// first level. A dense factorization of this size would take minutes
+	n := 20000
+	indptr := make([]int, n+1)
+	ind := make([]int, n)
+	data := make([]float64, n)
+	for i := 0; i < n; i++ {
+		indptr[i+1] = i + 1
+		ind[i] = i
+		data[i] = float64(i%5 + 1)
+	}
+	diag := sparse.NewCSR(n, n, indptr, ind, data)
+
+	amg, err := NewRugeStuben(diag, nil)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if amg.NumLevels() != 1 {
+		t.Fatalf("Expected one level, got %d", amg.NumLevels())
+	}
+
+	// A Gauss-Seidel sweep solves a diagonal system exactly
+	x := mat.NewVecDense(n, nil)
+	if err := amg.SolveVecTo(x, false, ones(n)); err != nil {
+		t.Fatalf("%v", err)
+	}
+	for i := 0; i < n; i++ {
+		if want := 1.0 / data[i]; math.Abs(x.AtVec(i)-want) > 1e-12 {
+			t.Fatalf("Row %d: wanted %v got %v", i, want, x.AtVec(i))
+		}
+	}
+
+	// The coarsest level is also smoothed when the number of levels is limited
+	A := poisson2D(16)
+	limited := RugeStuben(A, &Settings{MaxLevels: 2})
+	for _, trans := range []bool{false, true} {
+		if factor := convergenceFactor(t, A, &limited, trans, 10); factor > 0.9 {
+			t.Errorf("trans %v: expected a convergence factor below 0.9, got %v", trans, factor)
+		}
+	}
+}
+
+func TestRugeStubenTranspose(t *testing.T) {
+	// Add a convection term such that the matrix is not symmetric
+	n := 20
+	dok := sparse.NewDOK(n*n, n*n)
+	poisson2D(n).DoNonZero(func(i, j int, v float64) {
+		if j == i-1 {
+			v -= 0.5
+		}
+		dok.Set(i, j, v)
+	})
+	for i := 0; i < n*n; i++ {
+		dok.Set(i, i, dok.At(i, i)+0.5)
+	}
+	A := dok.ToCSR()
+
+	amg := RugeStuben(A, nil)
+	if amg.NumLevels() < 2 {
+		t.Fatalf("Expected more than one level")
+	}
+
+	for _, trans := range []bool{true, false} {
+		if factor := convergenceFactor(t, A, &amg, trans, 10); factor > 0.5 {
+			t.Errorf("trans %v: expected a convergence factor below 0.5, got %v", trans, factor)
+		}
+	}
+}
+
+func TestCloneSolvesConcurrently(t *testing.T) {
+	A := poisson2D(16)
+	n, _ := A.Dims()
+	amg := RugeStuben(A, nil)
+	if amg.NumLevels() < 2 {
+		t.Fatalf("Expected several levels, got %d", amg.NumLevels())
+	}
+
+	rhs := func(w int) *mat.VecDense {
+		v := mat.NewVecDense(n, nil)
+		for i := 0; i < n; i++ {
+			v.SetVec(i, float64((i+w)%7))
+		}
+		return v
+	}
+
+	const numWorkers = 4
+	results := make([]*mat.VecDense, numWorkers)
+	errs := make([]error, numWorkers)
+	var wg sync.WaitGroup
+	for w := 0; w < numWorkers; w++ {
+		clone := amg.Clone()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b := rhs(w)
+			results[w] = mat.NewVecDense(n, nil)
+			for k := 0; k < 20; k++ {
+				if err := clone.SolveVecTo(results[w], k%2 == 1, b); err != nil {
+					errs[w] = err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for w := 0; w < numWorkers; w++ {
+		if errs[w] != nil {
+			t.Fatalf("%v", errs[w])
+		}
+		want := mat.NewVecDense(n, nil)
+		if err := amg.SolveVecTo(want, true, rhs(w)); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if !mat.Equal(results[w], want) {
+			t.Errorf("Worker %d: the solution differs from the serial solve", w)
+		}
+	}
+}
+
+func TestRugeStubenErrors(t *testing.T) {
+	nonSquare := sparse.NewCSR(2, 3, []int{0, 0, 0}, nil, nil)
+	if _, err := NewRugeStuben(nonSquare, nil); !errors.Is(err, precond.ErrNotSquare{Rows: 2, Cols: 3}) {
+		t.Errorf("Wanted %v got %v", precond.ErrNotSquare{Rows: 2, Cols: 3}, err)
+	}
+
+	zeroDiag := toCSR(mat.NewDense(2, 2, []float64{1.0, 1.0, 1.0, 0.0}))
+	if _, err := NewRugeStuben(zeroDiag, nil); !errors.Is(err, precond.ErrZeroPivot{Row: 1}) {
+		t.Errorf("Wanted %v got %v", precond.ErrZeroPivot{Row: 1}, err)
+	}
+
+	empty := sparse.NewCSR(0, 0, []int{0}, nil, nil)
+	if _, err := NewRugeStuben(empty, nil); !errors.Is(err, precond.ErrInvalidArgument) {
+		t.Errorf("Wanted %v got %v", precond.ErrInvalidArgument, err)
+	}
+
+	singular := toCSR(mat.NewDense(2, 2, []float64{1.0, 1.0, 1.0, 1.0}))
+	if _, err := NewRugeStuben(singular, nil); !errors.Is(err, ErrSingularCoarseMatrix) {
+		t.Errorf("Wanted %v got %v", ErrSingularCoarseMatrix, err)
+	}
+}
+
+func TestRugeStubenWithLinsolve(t *testing.T) {
+	for _, n := range []int{16, 32} {
+		A := poisson2D(n)
+		amg := RugeStuben(A, nil)
+		matrix := precond.NewCSRMulVecToer(A)
+
+		settings := &linsolve.Settings{PreconSolve: amg.SolveVecTo}
+		result, err := linsolve.Iterative(&matrix, ones(n*n), &linsolve.CG{}, settings)
+		if err != nil {
+			t.Errorf("%v", err)
+			continue
+		}
+
+		if result.Stats.Iterations > 15 {
+			t.Errorf("Size %d: expected fast convergence got %d iterations", n, result.Stats.Iterations)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package amg
+
+import (
+	"container/heap"
+	"slices"
+
+	"github.com/james-bowman/sparse"
+)
+
+// graph is a sparsity pattern stored in compressed row format
+type graph struct {
+	indptr []int
+	ind    []int
+}
+
+func (g *graph) neighbours(i int) []int {
+	return g.ind[g.indptr[i]:g.indptr[i+1]]
+}
+
+// transposeGraph returns the transpose of a square sparsity pattern
+func transposeGraph(g graph) graph {
+	n := len(g.indptr) - 1
+	t := graph{indptr: make([]int, n+1), ind: make([]int, len(g.ind))}
+	for _, j := range g.ind {
+		t.indptr[j+1]++
+	}
+	for i := 0; i < n; i++ {
+		t.indptr[i+1] += t.indptr[i]
+	}
+
+	next := make([]int, n)
+	copy(next, t.indptr[:n])
+	for i := 0; i < n; i++ {
+		for _, j := range g.neighbours(i) {
+			t.ind[next[j]] = i
+			next[j]++
+		}
+	}
+	return t
+}
+
+// classicalStrength returns the strong connections of A. Node i depends strongly
+// on j if -a_ij >= theta * max_{k != i} (-a_ik)
+func classicalStrength(a *sparse.CSR, theta float64) graph {
+	raw := a.RawMatrix()
+	s := graph{indptr: make([]int, 1, raw.I+1)}
+	for i := 0; i < raw.I; i++ {
+		maxOffDiag := 0.0
+		for k := raw.Indptr[i]; k < raw.Indptr[i+1]; k++ {
+			if raw.Ind[k] != i {
+				maxOffDiag = max(maxOffDiag, -raw.Data[k])
+			}
+		}
+
+		if maxOffDiag > 0.0 {
+			for k := raw.Indptr[i]; k < raw.Indptr[i+1]; k++ {
+				if j := raw.Ind[k]; j != i && -raw.Data[k] >= theta*maxOffDiag {
+					s.ind = append(s.ind, j)
+				}
+			}
+		}
+		s.indptr = append(s.indptr, len(s.ind))
+	}
+	return s
+}
+
+const (
+	undecided = iota
+	coarsePoint
+	finePoint
+)
+
+// measureItem is an entry in the priority queue used in the first pass of the
+// coarse/fine splitting
+type measureItem struct {
+	node    int
+	measure int
+}
+
+// measureHeap is a max-heap of measures. Ties are broken by the lowest node
+// index such that the splitting is deterministic
+type measureHeap []measureItem
+
+func (h measureHeap) Len() int { return len(h) }
+func (h measureHeap) Less(i, j int) bool {
+	if h[i].measure != h[j].measure {
+		return h[i].measure > h[j].measure
+	}
+	return h[i].node < h[j].node
+}
+func (h measureHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *measureHeap) Push(x any)   { *h = append(*h, x.(measureItem)) }
+func (h *measureHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// rugeStubenSplitting divides the nodes into coarse and fine points. The first pass
+// selects coarse points greedily by the number of nodes depending strongly on them.
+// The second pass ensures that two strongly connected fine points share a common
+// coarse point
+func rugeStubenSplitting(s graph) []int {
+	n := len(s.indptr) - 1
+	sT := transposeGraph(s)
+	state := make([]int, n)
+	measure := make([]int, n)
+
+	h := make(measureHeap, 0, n)
+	for i := 0; i < n; i++ {
+		measure[i] = len(sT.neighbours(i))
+		if measure[i] == 0 && len(s.neighbours(i)) == 0 {
+			// Isolated nodes are handled by the smoother
+			state[i] = finePoint
+			continue
+		}
+		h = append(h, measureItem{node: i, measure: measure[i]})
+	}
+	heap.Init(&h)
+
+	for h.Len() > 0 {
+		item := heap.Pop(&h).(measureItem)
+		i := item.node
+		if state[i] != undecided || item.measure != measure[i] {
+			// Outdated entry
+			continue
+		}
+
+		if measure[i] == 0 && len(s.neighbours(i)) == 0 {
+			state[i] = finePoint
+			continue
+		}
+
+		state[i] = coarsePoint
+		for _, j := range sT.neighbours(i) {
+			if state[j] != undecided {
+				continue
+			}
+			state[j] = finePoint
+
+			// Nodes that new fine points depend on become more attractive as coarse points
+			for _, k := range s.neighbours(j) {
+				if state[k] == undecided {
+					measure[k]++
+					heap.Push(&h, measureItem{node: k, measure: measure[k]})
+				}
+			}
+		}
+
+		for _, k := range s.neighbours(i) {
+			if state[k] == undecided && measure[k] > 0 {
+				measure[k]--
+				heap.Push(&h, measureItem{node: k, measure: measure[k]})
+			}
+		}
+	}
+
+	// Second pass
+	mark := make([]int, n)
+	for i := range mark {
+		mark[i] = -1
+	}
+
+	for i := 0; i < n; i++ {
+		if state[i] != finePoint {
+			continue
+		}
+
+		for _, k := range s.neighbours(i) {
+			if state[k] == coarsePoint {
+				mark[k] = i
+			}
+		}
+
+		for _, j := range s.neighbours(i) {
+			if state[j] != finePoint {
+				continue
+			}
+
+			shared := false
+			for _, k := range s.neighbours(j) {
+				if state[k] == coarsePoint && mark[k] == i {
+					shared = true
+					break
+				}
+			}
+
+			if !shared {
+				state[j] = coarsePoint
+				mark[j] = i
+			}
+		}
+	}
+	return state
+}
+
+// classicalInterpolation calculates the Ruge-Stüben interpolation from the coarse
+// points to all points. Coarse points are injected, and fine points are interpolated
+// from their strongly connected coarse points. The contribution from strongly
+// connected fine points is distributed to the coarse points they share with the
+// interpolated point, and weak connections are lumped into the diagonal
+func classicalInterpolation(a *sparse.CSR, s graph, state []int) *sparse.CSR {
+	raw := a.RawMatrix()
+	n := raw.I
+
+	coarseIndex := make([]int, n)
+	nc := 0
+	for i, st := range state {
+		coarseIndex[i] = -1
+		if st == coarsePoint {
+			coarseIndex[i] = nc
+			nc++
+		}
+	}
+
+	// Markers valid for the current row
+	strong := make([]int, n)
+	interp := make([]int, n)
+	for i := range strong {
+		strong[i] = -1
+		interp[i] = -1
+	}
+	weights := make([]float64, n)
+
+	indptr := make([]int, 1, n+1)
+	ind := make([]int, 0, n)
+	data := make([]float64, 0, n)
+	for i := 0; i < n; i++ {
+		if state[i] == coarsePoint {
+			ind = append(ind, coarseIndex[i])
+			data = append(data, 1.0)
+			indptr = append(indptr, len(ind))
+			continue
+		}
+
+		// Interpolatory points are the strongly connected coarse points
+		points := make([]int, 0)
+		for _, j := range s.neighbours(i) {
+			strong[j] = i
+			if state[j] == coarsePoint {
+				interp[j] = i
+				weights[j] = 0.0
+				points = append(points, j)
+			}
+		}
+
+		diag := 0.0
+		for k := raw.Indptr[i]; k < raw.Indptr[i+1]; k++ {
+			j, v := raw.Ind[k], raw.Data[k]
+			switch {
+			case j == i:
+				diag += v
+			case interp[j] == i:
+				weights[j] += v
+			case strong[j] == i && state[j] == finePoint:
+				denom := 0.0
+				for kj := raw.Indptr[j]; kj < raw.Indptr[j+1]; kj++ {
+					if interp[raw.Ind[kj]] == i {
+						denom += raw.Data[kj]
+					}
+				}
+
+				if denom == 0.0 {
+					diag += v
+					continue
+				}
+
+				for kj := raw.Indptr[j]; kj < raw.Indptr[j+1]; kj++ {
+					if m := raw.Ind[kj]; interp[m] == i {
+						weights[m] += v * raw.Data[kj] / denom
+					}
+				}
+			default:
+				diag += v
+			}
+		}
+
+		if diag != 0.0 {
+			// The coarse indices are increasing with the fine indices
+			slices.Sort(points)
+			for _, j := range points {
+				ind = append(ind, coarseIndex[j])
+				data = append(data, -weights[j]/diag)
+			}
+		}
+		indptr = append(indptr, len(ind))
+	}
+	return sparse.NewCSR(n, nc, indptr, ind, data)
+}
+
+// RugeStuben builds a classical algebraic multigrid preconditioner with Ruge-Stüben
+// coarsening and classical interpolation. The coarse operators are the Galerkin
+// products R A P, with R = P^T. The method is designed for M-matrices such as
+// discretizations of Poisson type problems. If settings is nil, the default settings
+// are used. The method panics if A is not square or empty, the diagonal of an operator
+// contains zeros or the coarsest operator is singular. Use NewRugeStuben to get an error instead
+func RugeStuben(A *sparse.CSR, settings *Settings) Preconditioner {
+	amg, err := NewRugeStuben(A, settings)
+	if err != nil {
+		panic(err)
+	}
+	return amg
+}
+
+// NewRugeStuben is the same as RugeStuben, except that it returns an error instead of
+// panicking. precond.ErrNotSquare is returned if A is not square, precond.ErrInvalidArgument
+// if A is empty, precond.ErrZeroPivot if the diagonal of an operator contains zeros and
+// ErrSingularCoarseMatrix if the coarsest operator is singular
+func NewRugeStuben(A *sparse.CSR, settings *Settings) (Preconditioner, error) {
+	opts := settings.withDefaults()
+	return newPreconditioner(A, opts, func(a *sparse.CSR) (*sparse.CSR, error) {
+		s := classicalStrength(a, opts.StrengthThreshold)
+		state := rugeStubenSplitting(s)
+		return classicalInterpolation(a, s, state), nil
+	})
+}
@@ -0,0 +1,346 @@
+package amg
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/davidkleiven/goprecond/precond"
+	"github.com/james-bowman/sparse"
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/mat"
+)
+
+// nodeStrength returns the strong connections between the nodes of A, where nodeOf
+// maps each unknown to its node. Node I is strongly connected to J if
+// ||A_IJ|| >= theta * sqrt(||A_II|| ||A_JJ||), where ||.|| is the Frobenius norm
+func nodeStrength(a *sparse.CSR, nodeOf []int, numNodes int, theta float64) graph {
+	raw := a.RawMatrix()
+
+	// Unknowns belonging to each node
+	nodeIndptr := make([]int, numNodes+1)
+	for _, node := range nodeOf {
+		nodeIndptr[node+1]++
+	}
+	for i := 0; i < numNodes; i++ {
+		nodeIndptr[i+1] += nodeIndptr[i]
+	}
+	unknowns := make([]int, len(nodeOf))
+	next := make([]int, numNodes)
+	copy(next, nodeIndptr[:numNodes])
+	for i, node := range nodeOf {
+		unknowns[next[node]] = i
+		next[node]++
+	}
+
+	// Squared Frobenius norm of the blocks in the current block row
+	norms := make([]float64, numNodes)
+	used := make([]bool, numNodes)
+	diag := make([]float64, numNodes)
+	rows := make([][]int, numNodes)
+	values := make([][]float64, numNodes)
+	for node := 0; node < numNodes; node++ {
+		cols := make([]int, 0)
+		for _, i := range unknowns[nodeIndptr[node]:nodeIndptr[node+1]] {
+			for k := raw.Indptr[i]; k < raw.Indptr[i+1]; k++ {
+				j := nodeOf[raw.Ind[k]]
+				if !used[j] {
+					used[j] = true
+					cols = append(cols, j)
+				}
+				norms[j] += raw.Data[k] * raw.Data[k]
+			}
+		}
+
+		values[node] = make([]float64, len(cols))
+		for q, j := range cols {
+			values[node][q] = math.Sqrt(norms[j])
+			if j == node {
+				diag[node] = values[node][q]
+			}
+			norms[j] = 0.0
+			used[j] = false
+		}
+		rows[node] = cols
+	}
+
+	s := graph{indptr: make([]int, 1, numNodes+1)}
+	for node, cols := range rows {
+		for q, j := range cols {
+			if j != node && values[node][q] >= theta*math.Sqrt(diag[node]*diag[j]) {
+				s.ind = append(s.ind, j)
+			}
+		}
+		s.indptr = append(s.indptr, len(s.ind))
+	}
+	return s
+}
+
+// aggregate groups the nodes into aggregates using the strong connections. In the first
+// pass, each node whose strong neighbours are all unaggregated forms an aggregate together
+// with its neighbours. In the second pass, the remaining nodes join an aggregate of a strong
+// neighbour, and in the last pass the nodes that are still left form new aggregates with
+// their unaggregated neighbours. The aggregate of each node and the number of aggregates
+// are returned
+func aggregate(s graph) ([]int, int) {
+	n := len(s.indptr) - 1
+	agg := make([]int, n)
+	for i := range agg {
+		agg[i] = -1
+	}
+
+	numAgg := 0
+	for i := 0; i < n; i++ {
+		if agg[i] != -1 {
+			continue
+		}
+
+		free := true
+		for _, j := range s.neighbours(i) {
+			if agg[j] != -1 {
+				free = false
+				break
+			}
+		}
+
+		if free {
+			agg[i] = numAgg
+			for _, j := range s.neighbours(i) {
+				agg[j] = numAgg
+			}
+			numAgg++
+		}
+	}
+
+	// Nodes are only assigned to aggregates from the first pass
+	firstPass := make([]int, n)
+	copy(firstPass, agg)
+	for i := 0; i < n; i++ {
+		if agg[i] != -1 {
+			continue
+		}
+
+		for _, j := range s.neighbours(i) {
+			if firstPass[j] != -1 {
+				agg[i] = firstPass[j]
+				break
+			}
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		if agg[i] != -1 {
+			continue
+		}
+
+		agg[i] = numAgg
+		for _, j := range s.neighbours(i) {
+			if agg[j] == -1 {
+				agg[j] = numAgg
+			}
+		}
+		numAgg++
+	}
+	return agg, numAgg
+}
+
+// tentativeProlongator fits the near-nullspace vectors locally on each aggregate. The rows
+// of the nullspace belonging to an aggregate are orthonormalized with modified Gram-Schmidt,
+// B_agg = Q R, and Q becomes the block of the prolongator. Columns that are linearly dependent
+// on the previous columns are dropped. The coarse nullspace consists of the rows of R, and the
+// node of each coarse unknown is its aggregate
+func tentativeProlongator(nodeOf []int, agg []int, numAgg int, nullspace *mat.Dense) (*sparse.CSR, *mat.Dense, []int) {
+	n, k := nullspace.Dims()
+
+	members := make([][]int, numAgg)
+	for i, node := range nodeOf {
+		a := agg[node]
+		members[a] = append(members[a], i)
+	}
+
+	// Columns of Q for each aggregate and the rows of the coarse nullspace
+	type column struct {
+		values []float64
+		r      []float64
+	}
+
+	rowCols := make([][]int, n)
+	rowValues := make([][]float64, n)
+	coarseNodeOf := make([]int, 0, numAgg*k)
+	coarseRows := make([][]float64, 0, numAgg*k)
+	for a, rows := range members {
+		columns := make([]column, 0, k)
+		for c := 0; c < k; c++ {
+			v := make([]float64, len(rows))
+			for q, i := range rows {
+				v[q] = nullspace.At(i, c)
+			}
+			origNorm := norm(v)
+
+			r := make([]float64, k)
+			for _, prev := range columns {
+				dot := 0.0
+				for q := range v {
+					dot += prev.values[q] * v[q]
+				}
+				for q := range v {
+					v[q] -= dot * prev.values[q]
+				}
+
+				// The coefficient of column c in the row of the previous column
+				prev.r[c] = dot
+			}
+
+			vNorm := norm(v)
+			if vNorm <= 1e-10*origNorm || vNorm == 0.0 {
+				// Linearly dependent column
+				continue
+			}
+
+			for q := range v {
+				v[q] /= vNorm
+			}
+			r[c] = vNorm
+			columns = append(columns, column{values: v, r: r})
+		}
+
+		for _, col := range columns {
+			coarse := len(coarseNodeOf)
+			for q, i := range rows {
+				rowCols[i] = append(rowCols[i], coarse)
+				rowValues[i] = append(rowValues[i], col.values[q])
+			}
+			coarseNodeOf = append(coarseNodeOf, a)
+			coarseRows = append(coarseRows, col.r)
+		}
+	}
+
+	indptr := make([]int, 1, n+1)
+	ind := make([]int, 0)
+	data := make([]float64, 0)
+	for i := 0; i < n; i++ {
+		ind = append(ind, rowCols[i]...)
+		data = append(data, rowValues[i]...)
+		indptr = append(indptr, len(ind))
+	}
+
+	// The coarsening stops if there are no coarse unknowns, but mat.Dense can not be empty
+	nc := len(coarseNodeOf)
+	coarseNullspace := mat.NewDense(max(nc, 1), k, nil)
+	for i, row := range coarseRows {
+		coarseNullspace.SetRow(i, row)
+	}
+	return sparse.NewCSR(n, nc, indptr, ind, data), coarseNullspace, coarseNodeOf
+}
+
+func norm(v []float64) float64 {
+	sum := 0.0
+	for _, x := range v {
+		sum += x * x
+	}
+	return math.Sqrt(sum)
+}
+
+// spectralRadius estimates the spectral radius of D^{-1} A with power iterations
+func spectralRadius(a *sparse.CSR, diag []float64, iterations int) float64 {
+	n, _ := a.Dims()
+
+	// Use a fixed seed such that the estimate is reproducible
+	rnd := rand.New(rand.NewSource(1))
+	x := make([]float64, n)
+	for i := range x {
+		x[i] = rnd.Float64()
+	}
+
+	scale := 1.0 / norm(x)
+	for i := range x {
+		x[i] *= scale
+	}
+
+	y := make([]float64, n)
+	rho := 0.0
+	for it := 0; it < iterations; it++ {
+		mulVec(y, a, x)
+		for i := range y {
+			y[i] /= diag[i]
+		}
+
+		// x has unit length, thus the norm of y is the estimate
+		rho = norm(y)
+		if rho == 0.0 {
+			return 0.0
+		}
+
+		for i := range x {
+			x[i] = y[i] / rho
+		}
+	}
+	return rho
+}
+
+// SmoothedAggregation builds a smoothed aggregation algebraic multigrid preconditioner.
+// The nodes are aggregated based on the strength of connection, and the near-nullspace
+// vectors are fitted locally on each aggregate to form a tentative prolongator, which is
+// smoothed with one step of weighted Jacobi. The columns of nullspace are the near-nullspace
+// vectors of A, such as the rigid body modes in linear elasticity. If nullspace is nil, the
+// constant vector is used. If settings is nil, the default settings are used.
+// The method panics if A is not square or empty, the nullspace has the wrong number of rows
+// or no columns, the block size does not divide the number of rows, the diagonal of an
+// operator contains zeros or the coarsest operator is singular. Use NewSmoothedAggregation to get an error instead
+func SmoothedAggregation(A *sparse.CSR, nullspace *mat.Dense, settings *Settings) Preconditioner {
+	amg, err := NewSmoothedAggregation(A, nullspace, settings)
+	if err != nil {
+		panic(err)
+	}
+	return amg
+}
+
+// NewSmoothedAggregation is the same as SmoothedAggregation, except that it returns an error
+// instead of panicking. precond.ErrNotSquare is returned if A is not square,
+// precond.ErrInvalidArgument if A is empty or the nullspace or block size do not match A,
+// precond.ErrZeroPivot if the diagonal of an operator contains zeros and
+// ErrSingularCoarseMatrix if the coarsest operator is singular
+func NewSmoothedAggregation(A *sparse.CSR, nullspace *mat.Dense, settings *Settings) (Preconditioner, error) {
+	if err := checkMatrix(A); err != nil {
+		return Preconditioner{}, err
+	}
+	n, _ := A.Dims()
+	opts := settings.withDefaults()
+
+	if n%opts.BlockSize != 0 {
+		return Preconditioner{}, fmt.Errorf("%w: block size %d does not divide the number of rows %d", precond.ErrInvalidArgument, opts.BlockSize, n)
+	}
+
+	if nullspace == nil {
+		nullspace = mat.NewDense(n, 1, nil)
+		for i := 0; i < n; i++ {
+			nullspace.Set(i, 0, 1.0)
+		}
+	}
+
+	if r, c := nullspace.Dims(); r != n || c < 1 {
+		return Preconditioner{}, fmt.Errorf("%w: the nullspace is %d x %d, expected %d rows and at least one column", precond.ErrInvalidArgument, r, c, n)
+	}
+
+	nodeOf := make([]int, n)
+	for i := range nodeOf {
+		nodeOf[i] = i / opts.BlockSize
+	}
+	numNodes := n / opts.BlockSize
+
+	return newPreconditioner(A, opts, func(a *sparse.CSR) (*sparse.CSR, error) {
+		s := nodeStrength(a, nodeOf, numNodes, opts.AggregationThreshold)
+		agg, numAgg := aggregate(s)
+
+		pTent, coarseNullspace, coarseNodeOf := tentativeProlongator(nodeOf, agg, numAgg, nullspace)
+		nullspace, nodeOf, numNodes = coarseNullspace, coarseNodeOf, numAgg
+
+		// P = (I - omega D^{-1} A) P_tent
+		diag := diagonal(a)
+		omega := 4.0 / (3.0 * spectralRadius(a, diag, 15))
+		scale := make([]float64, len(diag))
+		for i, d := range diag {
+			scale[i] = omega / d
+		}
+		return subtractScaledRows(pTent, multiply(a, pTent), scale), nil
+	})
+}
@@ -0,0 +1,265 @@
+package amg
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/davidkleiven/goprecond/precond"
+	"github.com/james-bowman/sparse"
+	"gonum.org/v1/exp/linsolve"
+	"gonum.org/v1/gonum/mat"
+)
+
+type elasticityProblem struct {
+	matrix *sparse.CSR
+
+	// Rigid body modes: two translations and one rotation
+	rigidBodyModes *mat.Dense
+}
+
+// elasticity2D assembles the plane strain linear elasticity problem on the unit square
+// discretized with n x n bilinear elements. The left edge is clamped, and the unknowns
+// are ordered as (u_x, u_y) for each node
+func elasticity2D(n int) elasticityProblem {
+	youngs, poisson := 1.0, 0.3
+	c := youngs / ((1.0 + poisson) * (1.0 - 2.0*poisson))
+	constitutive := mat.NewDense(3, 3, []float64{
+		c * (1.0 - poisson), c * poisson, 0.0,
+		c * poisson, c * (1.0 - poisson), 0.0,
+		0.0, 0.0, c * (1.0 - 2.0*poisson) / 2.0,
+	})
+
+	// Element stiffness matrix from 2x2 Gauss quadrature
+	h := 1.0 / float64(n)
+	corners := [4][2]float64{{-1.0, -1.0}, {1.0, -1.0}, {1.0, 1.0}, {-1.0, 1.0}}
+	gauss := 1.0 / 1.7320508075688772
+	stiffness := mat.NewDense(8, 8, nil)
+	for _, xi := range []float64{-gauss, gauss} {
+		for _, eta := range []float64{-gauss, gauss} {
+			b := mat.NewDense(3, 8, nil)
+			for a, corner := range corners {
+				dx := 0.25 * corner[0] * (1.0 + corner[1]*eta) * 2.0 / h
+				dy := 0.25 * corner[1] * (1.0 + corner[0]*xi) * 2.0 / h
+				b.Set(0, 2*a, dx)
+				b.Set(1, 2*a+1, dy)
+				b.Set(2, 2*a, dy)
+				b.Set(2, 2*a+1, dx)
+			}
+
+			var contribution mat.Dense
+			contribution.Product(b.T(), constitutive, b)
+			contribution.Scale(h*h/4.0, &contribution)
+			stiffness.Add(stiffness, &contribution)
+		}
+	}
+
+	// Number the unknowns that are not clamped
+	numNodes := (n + 1) * (n + 1)
+	dof := make([]int, 2*numNodes)
+	numDofs := 0
+	for node := 0; node < numNodes; node++ {
+		for d := 0; d < 2; d++ {
+			dof[2*node+d] = -1
+			if node%(n+1) != 0 {
+				dof[2*node+d] = numDofs
+				numDofs++
+			}
+		}
+	}
+
+	dok := sparse.NewDOK(numDofs, numDofs)
+	for ey := 0; ey < n; ey++ {
+		for ex := 0; ex < n; ex++ {
+			nodes := [4]int{ey*(n+1) + ex, ey*(n+1) + ex + 1, (ey+1)*(n+1) + ex + 1, (ey+1)*(n+1) + ex}
+			for a := 0; a < 8; a++ {
+				row := dof[2*nodes[a/2]+a%2]
+				for b := 0; b < 8; b++ {
+					col := dof[2*nodes[b/2]+b%2]
+					if row != -1 && col != -1 {
+						dok.Set(row, col, dok.At(row, col)+stiffness.At(a, b))
+					}
+				}
+			}
+		}
+	}
+
+	modes := mat.NewDense(numDofs, 3, nil)
+	for node := 0; node < numNodes; node++ {
+		x := float64(node%(n+1)) * h
+		y := float64(node/(n+1)) * h
+		if ux := dof[2*node]; ux != -1 {
+			modes.SetRow(ux, []float64{1.0, 0.0, -y})
+			modes.SetRow(dof[2*node+1], []float64{0.0, 1.0, x})
+		}
+	}
+	return elasticityProblem{matrix: dok.ToCSR(), rigidBodyModes: modes}
+}
+
+func TestTentativeProlongatorReproducesNullspace(t *testing.T) {
+	problem := elasticity2D(6)
+	n, _ := problem.matrix.Dims()
+	nodeOf := make([]int, n)
+	for i := range nodeOf {
+		nodeOf[i] = i / 2
+	}
+
+	s := nodeStrength(problem.matrix, nodeOf, n/2, 0.08)
+	agg, numAgg := aggregate(s)
+	for _, a := range agg {
+		if a < 0 || a >= numAgg {
+			t.Fatalf("All nodes must be aggregated. Got aggregate %d", a)
+		}
+	}
+
+	p, coarse, coarseNodeOf := tentativeProlongator(nodeOf, agg, numAgg, problem.rigidBodyModes)
+	if _, nc := p.Dims(); nc != len(coarseNodeOf) {
+		t.Errorf("Expected %d coarse unknowns got %d", len(coarseNodeOf), nc)
+	}
+
+	var reproduced mat.Dense
+	reproduced.Mul(p, coarse)
+	if !mat.EqualApprox(&reproduced, problem.rigidBodyModes, 1e-10) {
+		t.Errorf("The tentative prolongator must reproduce the nullspace")
+	}
+
+	// The columns of the tentative prolongator are orthonormal
+	var gram mat.Dense
+	gram.Mul(p.T(), p)
+	nc := len(coarseNodeOf)
+	for i := 0; i < nc; i++ {
+		for j := 0; j < nc; j++ {
+			want := 0.0
+			if i == j {
+				want = 1.0
+			}
+			if v := gram.At(i, j); v < want-1e-10 || v > want+1e-10 {
+				t.Fatalf("Wanted %v at (%d, %d) got %v", want, i, j, v)
+			}
+		}
+	}
+}
+
+func TestSmoothedAggregationPoisson(t *testing.T) {
+	for _, n := range []int{16, 32, 64} {
+		t.Run(fmt.Sprintf("size %d", n), func(t *testing.T) {
+			A := poisson2D(n)
+			amg := SmoothedAggregation(A, nil, nil)
+			if amg.NumLevels() < 2 {
+				t.Errorf("Expected more than one level, got %d", amg.NumLevels())
+			}
+
+			// Aggregation coarsens faster than Ruge-Stüben, which gives a slower
+			// stationary iteration but a cheaper hierarchy
+			if factor := convergenceFactor(t, A, &amg, false, 10); factor > 0.6 {
+				t.Errorf("Expected a convergence factor below 0.6, got %v", factor)
+			}
+
+			matrix := precond.NewCSRMulVecToer(A)
+			settings := &linsolve.Settings{PreconSolve: amg.SolveVecTo}
+			result, err := linsolve.Iterative(&matrix, ones(n*n), &linsolve.CG{}, settings)
+			if err != nil {
+				t.Errorf("%v", err)
+				return
+			}
+
+			if result.Stats.Iterations > 15 {
+				t.Errorf("Expected fast convergence got %d iterations", result.Stats.Iterations)
+			}
+		})
+	}
+}
+
+func TestSmoothedAggregationElasticity(t *testing.T) {
+	for _, n := range []int{16, 32} {
+		problem := elasticity2D(n)
+		numDofs, _ := problem.matrix.Dims()
+		matrix := precond.NewCSRMulVecToer(problem.matrix)
+
+		iterations := make(map[string]int)
+		for _, test := range []struct {
+			nullspace *mat.Dense
+			desc      string
+		}{
+			{
+				nullspace: problem.rigidBodyModes,
+				desc:      "rigid body modes",
+			},
+			{
+				nullspace: mat.DenseCopyOf(problem.rigidBodyModes.Slice(0, numDofs, 0, 2)),
+				desc:      "translations",
+			},
+		} {
+			amg := SmoothedAggregation(problem.matrix, test.nullspace, &Settings{BlockSize: 2})
+			settings := &linsolve.Settings{PreconSolve: amg.SolveVecTo, MaxIterations: 500}
+			result, err := linsolve.Iterative(&matrix, ones(numDofs), &linsolve.CG{}, settings)
+			if err != nil {
+				t.Errorf("Size %d %s: %v", n, test.desc, err)
+				continue
+			}
+			iterations[test.desc] = result.Stats.Iterations
+		}
+
+		if iterations["rigid body modes"] >= iterations["translations"] {
+			t.Errorf("Size %d: rigid body modes should improve the convergence. Got %v", n, iterations)
+		}
+
+		if iterations["rigid body modes"] > 30 {
+			t.Errorf("Size %d: expected fast convergence. Got %v", n, iterations)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	A := poisson2D(32)
+	for _, amg := range []Preconditioner{SmoothedAggregation(A, nil, nil), RugeStuben(A, nil)} {
+		stats := amg.Stats()
+		if stats.Levels != amg.NumLevels() || stats.Levels < 2 {
+			t.Errorf("Unexpected number of levels %d", stats.Levels)
+		}
+
+		if stats.Rows[0] != 1024 || stats.NonZeros[0] != A.NNZ() {
+			t.Errorf("Wanted 1024 rows and %d non-zeros on the finest level, got %d and %d", A.NNZ(), stats.Rows[0], stats.NonZeros[0])
+		}
+
+		for i := 1; i < stats.Levels; i++ {
+			if stats.Rows[i] >= stats.Rows[i-1] {
+				t.Errorf("The number of rows must decrease. Got %v", stats.Rows)
+			}
+		}
+
+		if stats.GridComplexity <= 1.0 || stats.GridComplexity > 2.0 {
+			t.Errorf("Unexpected grid complexity %v", stats.GridComplexity)
+		}
+
+		if stats.OperatorComplexity <= 1.0 || stats.OperatorComplexity > 3.0 {
+			t.Errorf("Unexpected operator complexity %v", stats.OperatorComplexity)
+		}
+	}
+}
+
+func TestSmoothedAggregationErrors(t *testing.T) {
+	A := poisson2D(3)
+	if _, err := NewSmoothedAggregation(A, mat.NewDense(8, 1, nil), nil); !errors.Is(err, precond.ErrInvalidArgument) {
+		t.Errorf("Wanted %v got %v", precond.ErrInvalidArgument, err)
+	}
+
+	if _, err := NewSmoothedAggregation(A, nil, &Settings{BlockSize: 2}); !errors.Is(err, precond.ErrInvalidArgument) {
+		t.Errorf("Wanted %v got %v", precond.ErrInvalidArgument, err)
+	}
+
+	nonSquare := sparse.NewCSR(2, 3, []int{0, 0, 0}, nil, nil)
+	if _, err := NewSmoothedAggregation(nonSquare, nil, nil); !errors.Is(err, precond.ErrNotSquare{Rows: 2, Cols: 3}) {
+		t.Errorf("Wanted %v got %v", precond.ErrNotSquare{Rows: 2, Cols: 3}, err)
+	}
+
+	empty := sparse.NewCSR(0, 0, []int{0}, nil, nil)
+	if _, err := NewSmoothedAggregation(empty, nil, nil); !errors.Is(err, precond.ErrInvalidArgument) {
+		t.Errorf("Wanted %v got %v", precond.ErrInvalidArgument, err)
+	}
+
+	// The zero value has no columns
+	if _, err := NewSmoothedAggregation(A, &mat.Dense{}, nil); !errors.Is(err, precond.ErrInvalidArgument) {
+		t.Errorf("Wanted %v got %v", precond.ErrInvalidArgument, err)
+	}
+}
@@ -0,0 +1,162 @@
+package amg
+
+import (
+	"slices"
+
+	"github.com/james-bowman/sparse"
+)
+
+// transpose returns the transpose of a CSR matrix. The columns in each
+// row of the result are sorted
+func transpose(a *sparse.CSR) *sparse.CSR {
+	raw := a.RawMatrix()
+	nnz := raw.Indptr[raw.I]
+
+	indptr := make([]int, raw.J+1)
+	for _, j := range raw.Ind[:nnz] {
+		indptr[j+1]++
+	}
+	for j := 0; j < raw.J; j++ {
+		indptr[j+1] += indptr[j]
+	}
+
+	ind := make([]int, nnz)
+	data := make([]float64, nnz)
+	next := slices.Clone(indptr[:raw.J])
+	for i := 0; i < raw.I; i++ {
+		for k := raw.Indptr[i]; k < raw.Indptr[i+1]; k++ {
+			j := raw.Ind[k]
+			ind[next[j]] = i
+			data[next[j]] = raw.Data[k]
+			next[j]++
+		}
+	}
+	return sparse.NewCSR(raw.J, raw.I, indptr, ind, data)
+}
+
+// multiply calculates the product of two CSR matrices using Gustavson's algorithm.
+// The columns in each row of the result are sorted
+func multiply(a, b *sparse.CSR) *sparse.CSR {
+	rawA := a.RawMatrix()
+	rawB := b.RawMatrix()
+
+	// Dense accumulator for the current row of the result
+	acc := make([]float64, rawB.J)
+	used := make([]bool, rawB.J)
+
+	indptr := make([]int, 1, rawA.I+1)
+	ind := make([]int, 0, rawA.Indptr[rawA.I])
+	data := make([]float64, 0, rawA.Indptr[rawA.I])
+	for i := 0; i < rawA.I; i++ {
+		start := len(ind)
+		for ka := rawA.Indptr[i]; ka < rawA.Indptr[i+1]; ka++ {
+			k, va := rawA.Ind[ka], rawA.Data[ka]
+			for kb := rawB.Indptr[k]; kb < rawB.Indptr[k+1]; kb++ {
+				j := rawB.Ind[kb]
+				if !used[j] {
+					used[j] = true
+					ind = append(ind, j)
+				}
+				acc[j] += va * rawB.Data[kb]
+			}
+		}
+
+		slices.Sort(ind[start:])
+		for _, j := range ind[start:] {
+			data = append(data, acc[j])
+			acc[j] = 0.0
+			used[j] = false
+		}
+		indptr = append(indptr, len(ind))
+	}
+	return sparse.NewCSR(rawA.I, rawB.J, indptr, ind, data)
+}
+
+// galerkin calculates the coarse operator R A P
+func galerkin(r, a, p *sparse.CSR) *sparse.CSR {
+	return multiply(r, multiply(a, p))
+}
+
+// mulVec calculates dst = A x
+func mulVec(dst []float64, a *sparse.CSR, x []float64) {
+	raw := a.RawMatrix()
+	for i := 0; i < raw.I; i++ {
+		sum := 0.0
+		for k := raw.Indptr[i]; k < raw.Indptr[i+1]; k++ {
+			sum += raw.Data[k] * x[raw.Ind[k]]
+		}
+		dst[i] = sum
+	}
+}
+
+// mulVecAdd calculates dst = dst + A x
+func mulVecAdd(dst []float64, a *sparse.CSR, x []float64) {
+	raw := a.RawMatrix()
+	for i := 0; i < raw.I; i++ {
+		sum := 0.0
+		for k := raw.Indptr[i]; k < raw.Indptr[i+1]; k++ {
+			sum += raw.Data[k] * x[raw.Ind[k]]
+		}
+		dst[i] += sum
+	}
+}
+
+// residual calculates dst = b - A x
+func residual(dst []float64, a *sparse.CSR, x, b []float64) {
+	raw := a.RawMatrix()
+	for i := 0; i < raw.I; i++ {
+		sum := b[i]
+		for k := raw.Indptr[i]; k < raw.Indptr[i+1]; k++ {
+			sum -= raw.Data[k] * x[raw.Ind[k]]
+		}
+		dst[i] = sum
+	}
+}
+
+// diagonal returns the diagonal of A
+func diagonal(a *sparse.CSR) []float64 {
+	raw := a.RawMatrix()
+	diag := make([]float64, raw.I)
+	for i := 0; i < raw.I; i++ {
+		for k := raw.Indptr[i]; k < raw.Indptr[i+1]; k++ {
+			if raw.Ind[k] == i {
+				diag[i] += raw.Data[k]
+			}
+		}
+	}
+	return diag
+}
+
+// subtractScaledRows calculates a - diag(scale) b. The columns in each row of the
+// result are sorted if they are sorted in a and b
+func subtractScaledRows(a, b *sparse.CSR, scale []float64) *sparse.CSR {
+	rawA := a.RawMatrix()
+	rawB := b.RawMatrix()
+
+	indptr := make([]int, 1, rawA.I+1)
+	ind := make([]int, 0, rawB.Indptr[rawB.I])
+	data := make([]float64, 0, rawB.Indptr[rawB.I])
+	for i := 0; i < rawA.I; i++ {
+		ka, kb := rawA.Indptr[i], rawB.Indptr[i]
+		endA, endB := rawA.Indptr[i+1], rawB.Indptr[i+1]
+		for ka < endA || kb < endB {
+			switch {
+			case kb == endB || (ka < endA && rawA.Ind[ka] < rawB.Ind[kb]):
+				ind = append(ind, rawA.Ind[ka])
+				data = append(data, rawA.Data[ka])
+				ka++
+			case ka == endA || rawB.Ind[kb] < rawA.Ind[ka]:
+				ind = append(ind, rawB.Ind[kb])
+				data = append(data, -scale[i]*rawB.Data[kb])
+				kb++
+			default:
+				ind = append(ind, rawA.Ind[ka])
+				data = append(data, rawA.Data[ka]-scale[i]*rawB.Data[kb])
+				ka++
+				kb++
+			}
+		}
+		indptr = append(indptr, len(ind))
+	}
+	return sparse.NewCSR(rawA.I, rawA.J, indptr, ind, data)
+}
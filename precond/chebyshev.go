@@ -0,0 +1,232 @@
+package precond
+
+import (
+	"fmt"
+	"math"
+
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/mat"
+)
+
+// ChebyshevSettings controls the Chebyshev polynomial preconditioner
+type ChebyshevSettings struct {
+	// Degree of the polynomial. Each application of the preconditioner requires
+	// Degree matrix-vector products. Defaults to 5
+	Degree int
+
+	// Bounds of the spectrum of A. If both are zero, the bounds are estimated
+	// using Lanczos iterations
+	MinEig, MaxEig float64
+
+	// Number of Lanczos iterations used to estimate the bounds. Defaults to 10
+	LanczosIterations int
+}
+
+func (s *ChebyshevSettings) withDefaults() ChebyshevSettings {
+	result := ChebyshevSettings{
+		Degree:            5,
+		LanczosIterations: 10,
+	}
+
+	if s == nil {
+		return result
+	}
+
+	if s.Degree > 0 {
+		result.Degree = s.Degree
+	}
+	if s.LanczosIterations > 0 {
+		result.LanczosIterations = s.LanczosIterations
+	}
+	result.MinEig = s.MinEig
+	result.MaxEig = s.MaxEig
+	return result
+}
+
+// ChebyshevPreconditioner approximates the inverse of A by a Chebyshev polynomial in A.
+// The polynomial is the one that minimizes the maximum residual over the interval
+// [MinEig, MaxEig]. Applying it only requires matrix-vector products, which makes the
+// preconditioner well suited for parallel environments. It is not safe for concurrent use,
+// see Clone
+type ChebyshevPreconditioner struct {
+	matrix *CSRMulVecToer
+	degree int
+	minEig float64
+	maxEig float64
+
+	// Work vectors used by SolveVecTo. They are nil if A is empty
+	residual  *mat.VecDense
+	direction *mat.VecDense
+	product   *mat.VecDense
+}
+
+// Chebyshev creates a Chebyshev polynomial preconditioner for the matrix A. The spectrum
+// of A must be real and positive (e.g. A is symmetric positive definite). If settings is
+// nil, the default settings are used. The method panics if A is not square, the settings
+// are invalid or the estimated spectrum is not positive. Use NewChebyshev to get an error
+// instead
+func Chebyshev(A *CSRMulVecToer, settings *ChebyshevSettings) ChebyshevPreconditioner {
+	cheb, err := NewChebyshev(A, settings)
+	if err != nil {
+		panic(err)
+	}
+	return cheb
+}
+
+// NewChebyshev is the same as Chebyshev, except that it returns an error instead of
+// panicking. ErrNotSquare is returned if A is not square and ErrInvalidArgument if the
+// bounds are invalid or the estimated spectrum is not positive
+func NewChebyshev(A *CSRMulVecToer, settings *ChebyshevSettings) (ChebyshevPreconditioner, error) {
+	if err := checkSquare(A.Matrix); err != nil {
+		return ChebyshevPreconditioner{}, err
+	}
+
+	if settings != nil && settings.Degree < 0 {
+		return ChebyshevPreconditioner{}, fmt.Errorf("%w: degree must be non-negative, got %d", ErrInvalidArgument, settings.Degree)
+	}
+	opts := settings.withDefaults()
+
+	if opts.MinEig == 0.0 && opts.MaxEig == 0.0 {
+		opts.MinEig, opts.MaxEig = estimateEigenvalueBounds(A, opts.LanczosIterations)
+	}
+
+	if !(opts.MinEig > 0.0 && opts.MinEig <= opts.MaxEig) || math.IsInf(opts.MaxEig, 0) {
+		return ChebyshevPreconditioner{}, fmt.Errorf("%w: the eigenvalue bounds must satisfy 0 < min <= max, got [%v, %v]", ErrInvalidArgument, opts.MinEig, opts.MaxEig)
+	}
+
+	cheb := ChebyshevPreconditioner{
+		matrix: A,
+		degree: opts.Degree,
+		minEig: opts.MinEig,
+		maxEig: opts.MaxEig,
+	}
+
+	if n, _ := A.Matrix.Dims(); n > 0 {
+		cheb.residual = mat.NewVecDense(n, nil)
+		cheb.direction = mat.NewVecDense(n, nil)
+		cheb.product = mat.NewVecDense(n, nil)
+	}
+	return cheb, nil
+}
+
+// Bounds returns the bounds of the spectrum used to construct the polynomial
+func (c *ChebyshevPreconditioner) Bounds() (float64, float64) {
+	return c.minEig, c.maxEig
+}
+
+// estimateEigenvalueBounds estimates the smallest and the largest eigenvalue of a
+// symmetric matrix from the Ritz values of a few Lanczos iterations. The Ritz values
+// lie inside the spectrum, thus the largest is increased by 10% to make sure that
+// it is an upper bound
+func estimateEigenvalueBounds(A *CSRMulVecToer, iterations int) (float64, float64) {
+	n, _ := A.Matrix.Dims()
+	if n == 0 {
+		return 1.0, 1.0
+	}
+
+	// Use a fixed seed such that the estimate is reproducible
+	rnd := rand.New(rand.NewSource(1))
+	v := mat.NewVecDense(n, nil)
+	for i := 0; i < n; i++ {
+		v.SetVec(i, rnd.Float64()-0.5)
+	}
+	v.ScaleVec(1.0/mat.Norm(v, 2), v)
+
+	prev := mat.NewVecDense(n, nil)
+	w := mat.NewVecDense(n, nil)
+	alpha := make([]float64, 0, iterations)
+	beta := make([]float64, 0, iterations)
+
+	for k := 0; k < min(iterations, n); k++ {
+		A.MulVecTo(w, false, v)
+		if k > 0 {
+			w.AddScaledVec(w, -beta[k-1], prev)
+		}
+
+		a := mat.Dot(w, v)
+		alpha = append(alpha, a)
+		w.AddScaledVec(w, -a, v)
+
+		b := mat.Norm(w, 2)
+		if b <= 1e-12*math.Abs(a) {
+			// Invariant subspace found. The Ritz values are exact
+			break
+		}
+		beta = append(beta, b)
+
+		prev.CopyVec(v)
+		v.ScaleVec(1.0/b, w)
+	}
+
+	m := len(alpha)
+	tridiag := mat.NewSymDense(m, nil)
+	for i := 0; i < m; i++ {
+		tridiag.SetSym(i, i, alpha[i])
+		if i+1 < m {
+			tridiag.SetSym(i, i+1, beta[i])
+		}
+	}
+
+	var eig mat.EigenSym
+	if !eig.Factorize(tridiag, false) {
+		return math.NaN(), math.NaN()
+	}
+	values := eig.Values(nil)
+	return values[0], 1.1 * values[m-1]
+}
+
+// Clone returns a preconditioner that shares the matrix with the receiver, but has its
+// own work vectors, such that the clones can solve concurrently
+func (c *ChebyshevPreconditioner) Clone() ChebyshevPreconditioner {
+	clone := *c
+	if c.residual != nil {
+		n := c.residual.Len()
+		clone.residual = mat.NewVecDense(n, nil)
+		clone.direction = mat.NewVecDense(n, nil)
+		clone.product = mat.NewVecDense(n, nil)
+	}
+	return clone
+}
+
+// SolveVecTo applies the Chebyshev polynomial to rhs, which corresponds to
+// performing Degree+1 steps of the Chebyshev iteration starting from zero. The
+// work vectors are stored in the preconditioner, so the method must not be called
+// concurrently on the same preconditioner (or copies of it). Use Clone instead
+func (c *ChebyshevPreconditioner) SolveVecTo(dst *mat.VecDense, trans bool, rhs mat.Vector) error {
+	n, _ := c.matrix.Matrix.Dims()
+	if err := checkVecDims(n, dst, rhs); err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return nil
+	}
+
+	theta := 0.5 * (c.maxEig + c.minEig)
+	delta := 0.5 * (c.maxEig - c.minEig)
+
+	residual, direction, product := c.residual, c.direction, c.product
+	residual.CopyVec(rhs)
+	direction.ScaleVec(1.0/theta, residual)
+	dst.CopyVec(direction)
+
+	if delta == 0.0 {
+		// The polynomial is constant
+		return nil
+	}
+
+	// Polynomials in A^T are the transposes of the polynomials in A
+	sigma := theta / delta
+	rho := 1.0 / sigma
+	for k := 0; k < c.degree; k++ {
+		c.matrix.MulVecTo(product, trans, direction)
+		residual.SubVec(residual, product)
+
+		rhoNext := 1.0 / (2.0*sigma - rho)
+		direction.ScaleVec(rhoNext*rho, direction)
+		direction.AddScaledVec(direction, 2.0*rhoNext/delta, residual)
+		dst.AddVec(dst, direction)
+		rho = rhoNext
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package precond
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/james-bowman/sparse"
+	"gonum.org/v1/exp/linsolve"
+	"gonum.org/v1/gonum/mat"
+)
+
+func diagonalCSR(diag []float64) *sparse.CSR {
+	n := len(diag)
+	indptr := make([]int, n+1)
+	ind := make([]int, n)
+	for i := range ind {
+		ind[i] = i
+		indptr[i+1] = i + 1
+	}
+	return sparse.NewCSR(n, n, indptr, ind, diag)
+}
+
+func TestEstimateEigenvalueBounds(t *testing.T) {
+	n := 20
+	diag := make([]float64, n)
+	for i := range diag {
+		diag[i] = 1.0 + float64(i)
+	}
+	matrix := NewCSRMulVecToer(diagonalCSR(diag))
+
+	// The Lanczos method is exact when the number of iterations matches the dimension
+	minEig, maxEig := estimateEigenvalueBounds(&matrix, n)
+	if math.Abs(minEig-1.0) > 1e-8 || math.Abs(maxEig-1.1*20.0) > 1e-8 {
+		t.Errorf("Wanted [1, 22] got [%v, %v]", minEig, maxEig)
+	}
+
+	minEig, maxEig = estimateEigenvalueBounds(&matrix, 5)
+	if minEig < 1.0 || maxEig < 20.0 {
+		t.Errorf("Wanted min >= 1 and max >= 20 got [%v, %v]", minEig, maxEig)
+	}
+}
+
+func TestChebyshevConvergesWithDegree(t *testing.T) {
+	tc := randomSymmetricTestCase(30)
+	matrix := NewCSRMulVecToer(denseToCSR(tc.matrix))
+
+	var eig mat.EigenSym
+	sym := mat.NewSymDense(30, nil)
+	for i := 0; i < 30; i++ {
+		for j := i; j < 30; j++ {
+			sym.SetSym(i, j, tc.matrix.At(i, j))
+		}
+	}
+	if !eig.Factorize(sym, false) {
+		t.Fatalf("Eigenvalue decomposition failed")
+	}
+	values := eig.Values(nil)
+
+	for _, trans := range []bool{true, false} {
+		prevResidual := math.Inf(1)
+		for _, degree := range []int{1, 5, 20} {
+			cheb := Chebyshev(&matrix, &ChebyshevSettings{Degree: degree, MinEig: values[0], MaxEig: values[29]})
+
+			result := mat.NewVecDense(30, nil)
+			if err := cheb.SolveVecTo(result, trans, tc.rhs); err != nil {
+				t.Errorf("%v", err)
+				return
+			}
+
+			residual := mat.NewVecDense(30, nil)
+			residual.MulVec(tc.matrix, result)
+			residual.SubVec(residual, tc.rhs)
+			norm := mat.Norm(residual, 2)
+			if norm >= prevResidual {
+				t.Errorf("trans %v degree %d: residual should decrease with the degree. Got %v (previous %v)", trans, degree, norm, prevResidual)
+			}
+			prevResidual = norm
+		}
+
+		if prevResidual > 1e-6*mat.Norm(tc.rhs, 2) {
+			t.Errorf("trans %v: expected an accurate solution for high degrees. Residual %v", trans, prevResidual)
+		}
+	}
+}
+
+func TestChebyshevIdentity(t *testing.T) {
+	matrix := NewCSRMulVecToer(diagonalCSR([]float64{2.0, 2.0, 2.0}))
+	cheb := Chebyshev(&matrix, nil)
+
+	rhs := mat.NewVecDense(3, []float64{1.0, 2.0, 3.0})
+	want := mat.NewVecDense(3, []float64{0.5, 1.0, 1.5})
+	result := mat.NewVecDense(3, nil)
+	if err := cheb.SolveVecTo(result, false, rhs); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if !mat.EqualApprox(result, want, 1e-8) {
+		t.Errorf("Wanted\n%v\ngot\n%v\n", mat.Formatted(want), mat.Formatted(result))
+	}
+}
+
+func TestChebyshevSolveVecToDoesNotAllocate(t *testing.T) {
+	matrix := NewCSRMulVecToer(gridLaplacian(10))
+	cheb := Chebyshev(&matrix, nil)
+	rhs := mat.NewVecDense(100, nil)
+	for i := 0; i < 100; i++ {
+		rhs.SetVec(i, float64(i))
+	}
+	dst := mat.NewVecDense(100, nil)
+
+	for _, trans := range []bool{false, true} {
+		allocs := testing.AllocsPerRun(10, func() {
+			if err := cheb.SolveVecTo(dst, trans, rhs); err != nil {
+				t.Fatalf("%v", err)
+			}
+		})
+		if allocs != 0 {
+			t.Errorf("trans=%v: expected no allocations, got %v", trans, allocs)
+		}
+	}
+}
+
+func TestChebyshevErrors(t *testing.T) {
+	matrix := NewCSRMulVecToer(diagonalCSR([]float64{1.0, 2.0}))
+	indefinite := NewCSRMulVecToer(diagonalCSR([]float64{-1.0, 2.0}))
+
+	for _, test := range []struct {
+		matrix   *CSRMulVecToer
+		settings *ChebyshevSettings
+		desc     string
+	}{
+		{
+			matrix:   &matrix,
+			settings: &ChebyshevSettings{Degree: -1},
+			desc:     "Negative degree",
+		},
+		{
+			matrix:   &matrix,
+			settings: &ChebyshevSettings{MinEig: 2.0, MaxEig: 1.0},
+			desc:     "Min larger than max",
+		},
+		{
+			matrix:   &matrix,
+			settings: &ChebyshevSettings{MinEig: -1.0, MaxEig: 1.0},
+			desc:     "Negative lower bound",
+		},
+		{
+			matrix: &indefinite,
+			desc:   "Estimated spectrum is not positive",
+		},
+	} {
+		if _, err := NewChebyshev(test.matrix, test.settings); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("Test %s: wanted %v got %v", test.desc, ErrInvalidArgument, err)
+		}
+	}
+}
+
+func TestChebyshevWithLinsolve(t *testing.T) {
+	tc := randomSymmetricTestCase(50)
+	matrix := NewCSRMulVecToer(denseToCSR(tc.matrix))
+	cheb := Chebyshev(&matrix, nil)
+
+	settings := &linsolve.Settings{PreconSolve: cheb.SolveVecTo}
+	if _, err := linsolve.Iterative(&matrix, tc.rhs, &linsolve.CG{}, settings); err != nil {
+		t.Errorf("%v", err)
+	}
+}
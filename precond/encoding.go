@@ -0,0 +1,324 @@
+package precond
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"slices"
+
+	"github.com/james-bowman/sparse"
+)
+
+// Binary encoding of preconditioners. The layout is
+//
+//	magic    [4]byte  "GPRC"
+//	version  uint16
+//	kind     uint16   type of the encoded preconditioner
+//	payload  []byte   type specific
+//	checksum uint32   CRC-32 (Castagnoli) of all preceding bytes
+//
+// All values are little endian. Integers are stored with 64 bits and float64 values by
+// their IEEE 754 bits, such that the encoding is independent of the platform. A slice
+// is stored as its length followed by the elements, and a CSR matrix as its dimensions
+// followed by the index pointers, the indices and the values. The version must be
+// increased when the layout of an existing kind changes, while new preconditioner types
+// only need a new kind.
+//
+// Only ILUPreconditioner (as returned by ILUZero, ILUK, ILUT, IChol and ShiftedIChol) and
+// ReorderedPreconditioner can be encoded. The Jacobi, block Jacobi, SSOR, Chebyshev, SPAI
+// and FSAI preconditioners, and the AMG and Schwarz preconditioners in the subpackages,
+// are not encodable yet
+const (
+	encodingMagic   = "GPRC"
+	encodingVersion = 1
+	headerSize      = len(encodingMagic) + 4
+	checksumSize    = 4
+)
+
+// encodingKind identifies the type of an encoded preconditioner
+type encodingKind uint16
+
+const (
+	kindILU encodingKind = iota + 1
+	kindReordered
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+var errNoFactors = fmt.Errorf("%w: the preconditioner has no factors", ErrInvalidArgument)
+
+// encoder appends values to a buffer
+type encoder struct {
+	buf []byte
+}
+
+func newEncoder(kind encodingKind, capacity int) *encoder {
+	e := &encoder{buf: make([]byte, 0, headerSize+capacity+checksumSize)}
+	e.buf = append(e.buf, encodingMagic...)
+	e.buf = binary.LittleEndian.AppendUint16(e.buf, encodingVersion)
+	e.buf = binary.LittleEndian.AppendUint16(e.buf, uint16(kind))
+	return e
+}
+
+func (e *encoder) integer(v int) {
+	e.buf = binary.LittleEndian.AppendUint64(e.buf, uint64(v))
+}
+
+func (e *encoder) ints(v []int) {
+	e.integer(len(v))
+	for _, x := range v {
+		e.integer(x)
+	}
+}
+
+func (e *encoder) float64s(v []float64) {
+	e.integer(len(v))
+	for _, x := range v {
+		e.buf = binary.LittleEndian.AppendUint64(e.buf, math.Float64bits(x))
+	}
+}
+
+func (e *encoder) csr(m *sparse.CSR) {
+	raw := m.RawMatrix()
+	e.integer(raw.I)
+	e.integer(raw.J)
+	e.ints(raw.Indptr)
+	e.ints(raw.Ind[:raw.Indptr[raw.I]])
+	e.float64s(raw.Data[:raw.Indptr[raw.I]])
+}
+
+// finish appends the checksum and returns the encoded data
+func (e *encoder) finish() []byte {
+	return binary.LittleEndian.AppendUint32(e.buf, crc32.Checksum(e.buf, crcTable))
+}
+
+// csrSize returns the number of bytes needed to encode m
+func csrSize(m *sparse.CSR) int {
+	raw := m.RawMatrix()
+	return 8 * (5 + len(raw.Indptr) + 2*raw.Indptr[raw.I])
+}
+
+// decoder reads values from encoded data. The first error is kept, and all reads after an
+// error return zero values
+type decoder struct {
+	data []byte
+	err  error
+}
+
+// newDecoder verifies the header and the checksum of data, and returns a decoder of the
+// payload. ErrUnsupportedVersion is returned if the data has another version, and
+// ErrInvalidEncoding if the data is corrupt or encodes another kind
+func newDecoder(data []byte, kind encodingKind) (*decoder, error) {
+	if len(data) < headerSize+checksumSize || string(data[:len(encodingMagic)]) != encodingMagic {
+		return nil, fmt.Errorf("%w: missing header", ErrInvalidEncoding)
+	}
+	if version := binary.LittleEndian.Uint16(data[4:]); version != encodingVersion {
+		return nil, ErrUnsupportedVersion{Version: int(version)}
+	}
+
+	body, sum := data[:len(data)-checksumSize], binary.LittleEndian.Uint32(data[len(data)-checksumSize:])
+	if crc32.Checksum(body, crcTable) != sum {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrInvalidEncoding)
+	}
+	if got := encodingKind(binary.LittleEndian.Uint16(data[6:])); got != kind {
+		return nil, fmt.Errorf("%w: expected kind %d, got %d", ErrInvalidEncoding, kind, got)
+	}
+	return &decoder{data: body[headerSize:]}, nil
+}
+
+func (d *decoder) fail(format string, args ...any) {
+	if d.err == nil {
+		d.err = fmt.Errorf("%w: %s", ErrInvalidEncoding, fmt.Sprintf(format, args...))
+	}
+}
+
+func (d *decoder) integer() int {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.data) < 8 {
+		d.fail("unexpected end of data")
+		return 0
+	}
+	v := int(binary.LittleEndian.Uint64(d.data))
+	d.data = d.data[8:]
+	return v
+}
+
+// length reads the length of a slice, and verifies that the elements fit in the data
+func (d *decoder) length() int {
+	n := d.integer()
+	if n < 0 || n > len(d.data)/8 {
+		d.fail("invalid slice length %d", n)
+		return 0
+	}
+	return n
+}
+
+func (d *decoder) ints() []int {
+	v := make([]int, d.length())
+	for k := range v {
+		v[k] = int(binary.LittleEndian.Uint64(d.data[8*k:]))
+	}
+	d.data = d.data[8*len(v):]
+	return v
+}
+
+func (d *decoder) float64s() []float64 {
+	v := make([]float64, d.length())
+	for k := range v {
+		v[k] = math.Float64frombits(binary.LittleEndian.Uint64(d.data[8*k:]))
+	}
+	d.data = d.data[8*len(v):]
+	return v
+}
+
+// csr reads a CSR matrix and verifies that the index arrays are consistent, and that the
+// columns are strictly increasing within each row
+func (d *decoder) csr() *sparse.CSR {
+	rows, cols := d.integer(), d.integer()
+	indptr, ind, data := d.ints(), d.ints(), d.float64s()
+	if d.err != nil {
+		return nil
+	}
+
+	if rows < 0 || cols < 0 || len(indptr) != rows+1 || indptr[0] != 0 || indptr[rows] != len(ind) || len(ind) != len(data) {
+		d.fail("inconsistent dimensions of a %dx%d matrix", rows, cols)
+		return nil
+	}
+	for i := 0; i < rows; i++ {
+		if indptr[i+1] < indptr[i] {
+			d.fail("decreasing index pointers in row %d", i)
+			return nil
+		}
+	}
+	for i := 0; i < rows; i++ {
+		for k := indptr[i]; k < indptr[i+1]; k++ {
+			if j := ind[k]; j < 0 || j >= cols {
+				d.fail("column index %d out of range", j)
+				return nil
+			}
+			if k > indptr[i] && ind[k] <= ind[k-1] {
+				d.fail("the columns of row %d are not strictly increasing", i)
+				return nil
+			}
+		}
+	}
+	return sparse.NewCSR(rows, cols, indptr, ind, data)
+}
+
+// close returns the first error, or ErrInvalidEncoding if not all data was read
+func (d *decoder) close() error {
+	if d.err == nil && len(d.data) > 0 {
+		d.fail("%d trailing bytes", len(d.data))
+	}
+	return d.err
+}
+
+func (ilu *ILUPreconditioner) encode(e *encoder) {
+	e.csr(ilu.lower)
+	e.csr(ilu.upper)
+}
+
+func (ilu *ILUPreconditioner) decode(d *decoder) {
+	lower, upper := d.csr(), d.csr()
+	if d.err != nil {
+		return
+	}
+
+	n, c := lower.Dims()
+	if r, c2 := upper.Dims(); n != c || r != n || c2 != n {
+		d.fail("the factors must be square matrices of equal size")
+		return
+	}
+	restored := newILUPreconditioner(lower, upper)
+	if slices.Contains(restored.lowerDiag, -1) || slices.Contains(restored.upperDiag, -1) {
+		d.fail("the diagonal of the factors must be stored")
+		return
+	}
+
+	// The columns are sorted, thus the diagonal is the last entry of each row of L and
+	// the first entry of each row of U
+	lowerRaw, upperRaw := lower.RawMatrix(), upper.RawMatrix()
+	for i := 0; i < n; i++ {
+		if restored.lowerDiag[i] != lowerRaw.Indptr[i+1]-1 || restored.upperDiag[i] != upperRaw.Indptr[i] {
+			d.fail("the factors are not triangular in row %d", i)
+			return
+		}
+		if isZeroPivot(lowerRaw.Data[restored.lowerDiag[i]]) || isZeroPivot(upperRaw.Data[restored.upperDiag[i]]) {
+			d.fail("zero pivot in row %d", i)
+			return
+		}
+	}
+	*ilu = restored
+}
+
+// MarshalBinary encodes the factors L and U in a versioned format with a checksum, such
+// that the factorization can be stored and restored with UnmarshalBinary. The symbolic
+// analysis is not part of the encoding. ErrInvalidArgument is returned for a zero value
+// ILUPreconditioner, which has no factors
+func (ilu *ILUPreconditioner) MarshalBinary() ([]byte, error) {
+	if ilu.lower == nil || ilu.upper == nil {
+		return nil, errNoFactors
+	}
+	e := newEncoder(kindILU, csrSize(ilu.lower)+csrSize(ilu.upper))
+	ilu.encode(e)
+	return e.finish(), nil
+}
+
+// UnmarshalBinary restores a factorization encoded by MarshalBinary. ErrInvalidEncoding is
+// returned if the data is corrupt or does not encode an ILUPreconditioner, and
+// ErrUnsupportedVersion if it is encoded with another version of the format. The restored
+// preconditioner can not be refactored, since the symbolic analysis is not encoded
+func (ilu *ILUPreconditioner) UnmarshalBinary(data []byte) error {
+	d, err := newDecoder(data, kindILU)
+	if err != nil {
+		return err
+	}
+
+	var restored ILUPreconditioner
+	restored.decode(d)
+	if err := d.close(); err != nil {
+		return err
+	}
+	*ilu = restored
+	return nil
+}
+
+// MarshalBinary encodes the ordering and the factorization of the permuted matrix in the
+// same format as ILUPreconditioner.MarshalBinary. ErrInvalidArgument is returned for a
+// zero value ReorderedPreconditioner
+func (r *ReorderedPreconditioner) MarshalBinary() ([]byte, error) {
+	if r.factor.lower == nil || r.factor.upper == nil {
+		return nil, errNoFactors
+	}
+	e := newEncoder(kindReordered, 8*(1+len(r.pivot.Pivots))+csrSize(r.factor.lower)+csrSize(r.factor.upper))
+	e.ints(r.pivot.Pivots)
+	r.factor.encode(e)
+	return e.finish(), nil
+}
+
+// UnmarshalBinary restores a preconditioner encoded by MarshalBinary. The errors are the
+// same as for ILUPreconditioner.UnmarshalBinary
+func (r *ReorderedPreconditioner) UnmarshalBinary(data []byte) error {
+	d, err := newDecoder(data, kindReordered)
+	if err != nil {
+		return err
+	}
+
+	restored := ReorderedPreconditioner{pivot: Pivot{Pivots: d.ints()}}
+	restored.factor.decode(d)
+	if err := d.close(); err != nil {
+		return err
+	}
+
+	if n, _ := restored.factor.lower.Dims(); len(restored.pivot.Pivots) != n {
+		return fmt.Errorf("%w: the ordering has length %d, but the factors have %d rows", ErrInvalidEncoding, len(restored.pivot.Pivots), n)
+	}
+	if err := restored.pivot.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+	*r = newReorderedPreconditioner(restored.pivot, restored.factor)
+	return nil
+}
@@ -0,0 +1,208 @@
+package precond
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"testing"
+
+	"github.com/davidkleiven/goprecond/precond/precondtest"
+	"github.com/davidkleiven/goprecond/precond/property"
+	"github.com/james-bowman/sparse"
+	"gonum.org/v1/gonum/mat"
+	"pgregory.net/rapid"
+)
+
+var (
+	_ encoding.BinaryMarshaler   = &ILUPreconditioner{}
+	_ encoding.BinaryUnmarshaler = &ILUPreconditioner{}
+	_ encoding.BinaryMarshaler   = &ReorderedPreconditioner{}
+	_ encoding.BinaryUnmarshaler = &ReorderedPreconditioner{}
+)
+
+// solver is a preconditioner that can be encoded
+type solver interface {
+	SolveVecTo(dst *mat.VecDense, trans bool, rhs mat.Vector) error
+	encoding.BinaryMarshaler
+}
+
+// checkSameSolve verifies that two preconditioners give bitwise identical solutions
+func checkSameSolve(t *rapid.T, want, got solver, n int) {
+	rhs := mat.NewVecDense(n, rapid.SliceOfN(rapid.Float64Range(-1.0, 1.0), n, n).Draw(t, "rhs"))
+	for _, trans := range []bool{false, true} {
+		x, y := mat.NewVecDense(n, nil), mat.NewVecDense(n, nil)
+		if err := want.SolveVecTo(x, trans, rhs); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if err := got.SolveVecTo(y, trans, rhs); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if !mat.Equal(x, y) {
+			t.Fatalf("trans=%v: the restored preconditioner gives another solution", trans)
+		}
+	}
+}
+
+func TestILUMarshalRoundTrip(t *testing.T) {
+	factorizations := map[string]func(ZeroAwareMatrix) (ILUPreconditioner, error){
+		"ILUZero": NewILUZero,
+		"IChol":   NewIChol,
+		"ILUK":    func(A ZeroAwareMatrix) (ILUPreconditioner, error) { return NewILUK(A, 2) },
+		"ILUT":    func(A ZeroAwareMatrix) (ILUPreconditioner, error) { return NewILUT(A, 4, 1e-3) },
+	}
+
+	rapid.Check(t, func(t *rapid.T) {
+		name := rapid.SampledFrom([]string{"ILUZero", "IChol", "ILUK", "ILUT"}).Draw(t, "factorization")
+		A := &precondtest.DenseNonZeroDoer{Dense: mat.DenseCopyOf(property.SparseSymmetricMatrix(t, 1, 30))}
+		ilu, err := factorizations[name](A)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		data, err := ilu.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		var restored ILUPreconditioner
+		if err := restored.UnmarshalBinary(data); err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		n, _ := A.Dims()
+		checkSameSolve(t, &ilu, &restored, n)
+
+		again, err := restored.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if !bytes.Equal(data, again) {
+			t.Fatalf("Expected the restored preconditioner to have the same encoding")
+		}
+	})
+}
+
+func TestReorderedMarshalRoundTrip(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		A := denseToCSR(mat.DenseCopyOf(property.SparseSymmetricMatrix(t, 1, 30)))
+		n, _ := A.Dims()
+		identity := make([]int, n)
+		for i := range identity {
+			identity[i] = i
+		}
+		order := rapid.Permutation(identity).Draw(t, "order")
+
+		reordered := Reordered(A, order, NewIChol)
+		data, err := reordered.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		var restored ReorderedPreconditioner
+		if err := restored.UnmarshalBinary(data); err != nil {
+			t.Fatalf("%v", err)
+		}
+		checkSameSolve(t, &reordered, &restored, n)
+	})
+}
+
+func TestRestoredPreconditionerCanNotBeRefactored(t *testing.T) {
+	A := &precondtest.DenseNonZeroDoer{Dense: mat.NewDense(2, 2, []float64{2.0, 1.0, 1.0, 2.0})}
+	ilu := ILUZero(A)
+	data, err := ilu.MarshalBinary()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	var restored ILUPreconditioner
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := restored.Refactor(A); err == nil {
+		t.Errorf("Expected an error when refactoring without a symbolic analysis")
+	}
+}
+
+func TestMarshalZeroValue(t *testing.T) {
+	var ilu ILUPreconditioner
+	if _, err := ilu.MarshalBinary(); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("ILUPreconditioner: wanted %v got %v", ErrInvalidArgument, err)
+	}
+
+	var reordered ReorderedPreconditioner
+	if _, err := reordered.MarshalBinary(); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("ReorderedPreconditioner: wanted %v got %v", ErrInvalidArgument, err)
+	}
+}
+
+// withChecksum replaces the checksum of the encoded data
+func withChecksum(data []byte) []byte {
+	body := data[:len(data)-checksumSize]
+	return binary.LittleEndian.AppendUint32(bytes.Clone(body), crc32.Checksum(body, crcTable))
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	A := &precondtest.DenseNonZeroDoer{Dense: mat.NewDense(2, 2, []float64{2.0, 1.0, 1.0, 2.0})}
+	ilu := ILUZero(A)
+	data, err := ilu.MarshalBinary()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// factors encodes L and U given by their CSR arrays, with the U of ilu if upper is nil
+	factors := func(lowerIndptr, lowerInd []int, lowerData []float64, upper *sparse.CSR) []byte {
+		if upper == nil {
+			upper = ilu.Upper()
+		}
+		factorized := newILUPreconditioner(sparse.NewCSR(2, 2, lowerIndptr, lowerInd, lowerData), upper)
+		encoded, err := factorized.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		return encoded
+	}
+	lowerOf := func(upperIndptr, upperInd []int, upperData []float64) []byte {
+		return factors([]int{0, 1, 3}, []int{0, 0, 1}, []float64{1.0, 0.5, 1.0}, sparse.NewCSR(2, 2, upperIndptr, upperInd, upperData))
+	}
+
+	modified := func(modify func(b []byte) []byte) []byte {
+		return modify(bytes.Clone(data))
+	}
+
+	for _, test := range []struct {
+		data []byte
+		want error
+		desc string
+	}{
+		{data: nil, want: ErrInvalidEncoding, desc: "empty"},
+		{data: modified(func(b []byte) []byte { b[0] = 'X'; return b }), want: ErrInvalidEncoding, desc: "wrong magic"},
+		{data: modified(func(b []byte) []byte { b[20]++; return b }), want: ErrInvalidEncoding, desc: "flipped bit"},
+		{data: modified(func(b []byte) []byte { return b[:len(b)-9] }), want: ErrInvalidEncoding, desc: "truncated"},
+		{data: modified(func(b []byte) []byte { b[4] = 2; return withChecksum(b) }), want: ErrUnsupportedVersion{Version: 2}, desc: "future version"},
+		{data: modified(func(b []byte) []byte { b[6] = byte(kindReordered); return withChecksum(b) }), want: ErrInvalidEncoding, desc: "wrong kind"},
+		{data: modified(func(b []byte) []byte { return withChecksum(append(b, make([]byte, 8)...)) }), want: ErrInvalidEncoding, desc: "trailing bytes"},
+		{
+			// The first column index of the lower factor is placed after the dimensions,
+			// the length and the three index pointers, and the length of the indices
+			data: modified(func(b []byte) []byte { b[headerSize+8*7] = 5; return withChecksum(b) }),
+			want: ErrInvalidEncoding,
+			desc: "column index out of range",
+		},
+		{data: factors([]int{0, 1, 2}, []int{0, 0}, []float64{1.0, 0.5}, nil), want: ErrInvalidEncoding, desc: "missing diagonal"},
+		{data: factors([]int{0, 2, 4}, []int{0, 1, 0, 1}, []float64{1.0, 0.5, 0.5, 1.0}, nil), want: ErrInvalidEncoding, desc: "L is not lower triangular"},
+		{data: lowerOf([]int{0, 2, 4}, []int{0, 1, 0, 1}, []float64{2.0, 1.0, 1.0, 1.5}), want: ErrInvalidEncoding, desc: "U is not upper triangular"},
+		{data: factors([]int{0, 1, 3}, []int{0, 1, 0}, []float64{1.0, 1.0, 0.5}, nil), want: ErrInvalidEncoding, desc: "unsorted columns"},
+		{data: factors([]int{0, 1, 4}, []int{0, 0, 0, 1}, []float64{1.0, 0.25, 0.25, 1.0}, nil), want: ErrInvalidEncoding, desc: "duplicate columns"},
+		{data: lowerOf([]int{0, 2, 3}, []int{0, 1, 1}, []float64{2.0, 1.0, 0.0}), want: ErrInvalidEncoding, desc: "zero pivot"},
+	} {
+		var restored ILUPreconditioner
+		if err := restored.UnmarshalBinary(test.data); !errors.Is(err, test.want) {
+			t.Errorf("%s: wanted %v got %v", test.desc, test.want, err)
+		}
+	}
+
+	var reordered ReorderedPreconditioner
+	if err := reordered.UnmarshalBinary(data); !errors.Is(err, ErrInvalidEncoding) {
+		t.Errorf("Expected ErrInvalidEncoding when decoding a factorization as a reordered preconditioner, got %v", err)
+	}
+}
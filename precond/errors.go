@@ -0,0 +1,98 @@
+package precond
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrNotSquare is returned when a square matrix is required
+type ErrNotSquare struct {
+	Rows, Cols int
+}
+
+func (e ErrNotSquare) Error() string {
+	return fmt.Sprintf("matrix must be square, got %dx%d", e.Rows, e.Cols)
+}
+
+// ErrZeroPivot is returned when a factorization encounters a pivot that can not be used.
+// For LU type factorizations this happens when the pivot is zero, and for cholesky type
+// factorizations when the pivot is not positive. Row is the row of the pivot and Value
+// the value of the pivot
+type ErrZeroPivot struct {
+	Row   int
+	Value float64
+}
+
+func (e ErrZeroPivot) Error() string {
+	return fmt.Sprintf("invalid pivot %e in row %d", e.Value, e.Row)
+}
+
+// pivotTolerance is the smallest absolute value of a diagonal entry or a pivot that
+// the factorizations accept
+const pivotTolerance = 1e-8
+
+// isZeroPivot returns true if v is too small (or NaN) to be used as a pivot
+func isZeroPivot(v float64) bool {
+	return !(math.Abs(v) >= pivotTolerance)
+}
+
+// ErrInvalidArgument is returned when a parameter of a preconditioner is out of range
+var ErrInvalidArgument = errors.New("invalid argument")
+
+// ErrNoSymbolic is returned when refactoring a preconditioner that was not created
+// from a symbolic analysis
+var ErrNoSymbolic = errors.New("the preconditioner was not created from a symbolic analysis")
+
+// checkSquare returns ErrNotSquare if A is not square
+func checkSquare(A ZeroAwareMatrix) error {
+	if r, c := A.Dims(); r != c {
+		return ErrNotSquare{Rows: r, Cols: c}
+	}
+	return nil
+}
+
+// ErrSingularBlock is returned when a diagonal block of a block preconditioner is singular.
+// Block is the index of the block
+type ErrSingularBlock struct {
+	Block int
+}
+
+func (e ErrSingularBlock) Error() string {
+	return fmt.Sprintf("diagonal block %d is singular", e.Block)
+}
+
+// ErrSingularColumn is returned when the least squares problem for a column of a sparse
+// approximate inverse is singular. Column is the index of the column
+type ErrSingularColumn struct {
+	Column int
+}
+
+func (e ErrSingularColumn) Error() string {
+	return fmt.Sprintf("least squares problem for column %d is singular", e.Column)
+}
+
+// ErrStructurallySingular is returned when the sparsity pattern of a matrix does not allow
+// a zero-free diagonal, thus the matrix is singular for all values of the non-zero entries.
+// Column is a column that can not be matched to a row
+type ErrStructurallySingular struct {
+	Column int
+}
+
+func (e ErrStructurallySingular) Error() string {
+	return fmt.Sprintf("matrix is structurally singular, column %d can not be matched to a row", e.Column)
+}
+
+// ErrInvalidEncoding is returned when binary data can not be decoded, for example because
+// the checksum does not match, the data is truncated or it encodes another type
+var ErrInvalidEncoding = errors.New("invalid binary encoding")
+
+// ErrUnsupportedVersion is returned when binary data is encoded with a format version that
+// can not be decoded by this version of the package
+type ErrUnsupportedVersion struct {
+	Version int
+}
+
+func (e ErrUnsupportedVersion) Error() string {
+	return fmt.Sprintf("unsupported encoding version %d, expected %d", e.Version, encodingVersion)
+}
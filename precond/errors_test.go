@@ -0,0 +1,93 @@
+package precond
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/davidkleiven/goprecond/precond/precondtest"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestConstructorErrors(t *testing.T) {
+	nonSquare := &precondtest.DenseNonZeroDoer{Dense: mat.NewDense(2, 3, []float64{1.0, 0.0, 0.0, 0.0, 1.0, 0.0})}
+	zeroDiag := &precondtest.DenseNonZeroDoer{Dense: mat.NewDense(3, 3, []float64{1.0, 1.0, 0.0, 1.0, 0.0, 0.0, 0.0, 0.0, 1.0})}
+	negativeDiag := &precondtest.DenseNonZeroDoer{Dense: mat.NewDense(2, 2, []float64{1.0, 0.0, 0.0, -2.0})}
+
+	// The second pivot is 1 - 4 = -3 for cholesky and 1 - 1 = 0 for LU
+	breakdown := &precondtest.DenseNonZeroDoer{Dense: mat.NewDense(3, 3, []float64{1.0, 2.0, 0.0, 2.0, 1.0, 1.0, 0.0, 1.0, 1.0})}
+	luBreakdown := &precondtest.DenseNonZeroDoer{Dense: mat.NewDense(3, 3, []float64{1.0, 1.0, 0.0, 1.0, 1.0, 1.0, 0.0, 1.0, 1.0})}
+
+	// Only the last pivot, 1 - 1 = 0, is zero
+	lastPivot := &precondtest.DenseNonZeroDoer{Dense: mat.NewDense(2, 2, []float64{1.0, 1.0, 1.0, 1.0})}
+
+	// The second pivot is 1e-12, which is too small to be used
+	tinyPivot := &precondtest.DenseNonZeroDoer{Dense: mat.NewDense(3, 3, []float64{1.0, 1.0, 0.0, 1.0, 1.0 + 1e-12, 1.0, 0.0, 1.0, 1.0})}
+
+	ilut := func(A ZeroAwareMatrix) (ILUPreconditioner, error) { return NewILUT(A, 3, 0.0) }
+	iluk := func(A ZeroAwareMatrix) (ILUPreconditioner, error) { return NewILUK(A, 1) }
+
+	for _, test := range []struct {
+		create  func(A ZeroAwareMatrix) (ILUPreconditioner, error)
+		matrix  ZeroAwareMatrix
+		wantRow int
+		desc    string
+	}{
+		{create: NewILUZero, matrix: nonSquare, wantRow: -1, desc: "ILUZero non-square"},
+		{create: NewIChol, matrix: nonSquare, wantRow: -1, desc: "IChol non-square"},
+		{create: ilut, matrix: nonSquare, wantRow: -1, desc: "ILUT non-square"},
+		{create: iluk, matrix: nonSquare, wantRow: -1, desc: "ILUK non-square"},
+		{create: NewILUZero, matrix: zeroDiag, wantRow: 1, desc: "ILUZero zero diagonal"},
+		{create: NewIChol, matrix: zeroDiag, wantRow: 1, desc: "IChol zero diagonal"},
+		{create: NewIChol, matrix: negativeDiag, wantRow: 1, desc: "IChol negative diagonal"},
+		{create: NewIChol, matrix: breakdown, wantRow: 1, desc: "IChol breakdown"},
+		{create: NewILUZero, matrix: luBreakdown, wantRow: 1, desc: "ILUZero breakdown"},
+		{create: ilut, matrix: luBreakdown, wantRow: 1, desc: "ILUT breakdown"},
+		{create: ilut, matrix: tinyPivot, wantRow: 1, desc: "ILUT tiny pivot"},
+		{create: NewILUZero, matrix: tinyPivot, wantRow: 1, desc: "ILUZero tiny pivot"},
+		{create: NewIChol, matrix: tinyPivot, wantRow: 1, desc: "IChol tiny pivot"},
+		{create: NewILUZero, matrix: lastPivot, wantRow: 1, desc: "ILUZero last pivot"},
+		{create: iluk, matrix: lastPivot, wantRow: 1, desc: "ILUK last pivot"},
+		{create: ilut, matrix: lastPivot, wantRow: 1, desc: "ILUT last pivot"},
+	} {
+		_, err := test.create(test.matrix)
+		if test.wantRow < 0 {
+			var notSquare ErrNotSquare
+			if !errors.As(err, &notSquare) {
+				t.Errorf("Test %s: wanted ErrNotSquare got %v", test.desc, err)
+			}
+			continue
+		}
+
+		var zeroPivot ErrZeroPivot
+		if !errors.As(err, &zeroPivot) {
+			t.Errorf("Test %s: wanted ErrZeroPivot got %v", test.desc, err)
+			continue
+		}
+
+		if zeroPivot.Row != test.wantRow {
+			t.Errorf("Test %s: wanted row %d got %d", test.desc, test.wantRow, zeroPivot.Row)
+		}
+	}
+}
+
+func TestInvalidArgumentErrors(t *testing.T) {
+	matrix := &precondtest.DenseNonZeroDoer{Dense: mat.NewDense(2, 2, []float64{1.0, 0.0, 0.0, 1.0})}
+
+	if _, err := NewILUT(matrix, -1, 0.0); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("Wanted ErrInvalidArgument got %v", err)
+	}
+
+	if _, err := NewILUK(matrix, -1); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("Wanted ErrInvalidArgument got %v", err)
+	}
+}
+
+func TestPanickingConstructorsPanicWithError(t *testing.T) {
+	defer func() {
+		r := recover()
+		if _, ok := r.(ErrNotSquare); !ok {
+			t.Errorf("Wanted panic with ErrNotSquare got %v", r)
+		}
+	}()
+	ILUZero(&precondtest.DenseNonZeroDoer{Dense: mat.NewDense(2, 3, nil)})
+}
@@ -0,0 +1,64 @@
+package precond_test
+
+import (
+	"fmt"
+
+	"github.com/davidkleiven/goprecond/precond"
+	"github.com/davidkleiven/goprecond/precond/amd"
+	"github.com/james-bowman/sparse"
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/exp/linsolve"
+	"gonum.org/v1/gonum/mat"
+)
+
+// poissonMatrix returns the 5-point Laplacian on a n x n grid
+func poissonMatrix(n int) *sparse.CSR {
+	dok := sparse.NewDOK(n*n, n*n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			row := i*n + j
+			dok.Set(row, row, 4.0)
+			if i > 0 {
+				dok.Set(row, row-n, -1.0)
+			}
+			if i < n-1 {
+				dok.Set(row, row+n, -1.0)
+			}
+			if j > 0 {
+				dok.Set(row, row-1, -1.0)
+			}
+			if j < n-1 {
+				dok.Set(row, row+1, -1.0)
+			}
+		}
+	}
+	return dok.ToCSR()
+}
+
+func ExampleReordered() {
+	// Poisson matrix on a grid where the unknowns are numbered randomly, as is common
+	// for unstructured meshes
+	n := 40
+	shuffle := precond.Pivot{Pivots: rand.New(rand.NewSource(1)).Perm(n * n)}
+	A := shuffle.PermuteSymmetric(poissonMatrix(n))
+	matrix := precond.NewCSRMulVecToer(A)
+	rhs := mat.NewVecDense(n*n, nil)
+	for i := 0; i < n*n; i++ {
+		rhs.SetVec(i, 1.0)
+	}
+
+	natural := precond.IChol(A)
+	reordered := precond.Reordered(A, amd.ReverseCuthillMcKee(amd.CSRAdjacencyList(A)), precond.NewIChol)
+	for _, preconSolve := range []func(*mat.VecDense, bool, mat.Vector) error{natural.SolveVecTo, reordered.SolveVecTo} {
+		result, err := linsolve.Iterative(&matrix, rhs, &linsolve.CG{}, &linsolve.Settings{PreconSolve: preconSolve})
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("# Iterations: %d\n", result.Stats.Iterations)
+	}
+
+	// Output:
+	// # Iterations: 60
+	// # Iterations: 35
+}
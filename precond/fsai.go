@@ -0,0 +1,202 @@
+package precond
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/davidkleiven/goprecond/precond/internal/parallel"
+	"github.com/james-bowman/sparse"
+	"github.com/james-bowman/sparse/blas"
+	"gonum.org/v1/gonum/mat"
+)
+
+// FSAIPreconditioner is a factored sparse approximate inverse G^T G of a symmetric
+// positive definite matrix A, where G is lower triangular. Applying the preconditioner
+// requires two sparse matrix-vector products. It is not safe for concurrent use, see Clone
+type FSAIPreconditioner struct {
+	g  *sparse.CSR
+	gT *sparse.CSR
+
+	// Work vector used by SolveVecTo. It is nil if A is empty
+	product *mat.VecDense
+}
+
+// fsaiWorkspace holds the scratch memory used by one goroutine
+type fsaiWorkspace struct {
+	// Local index of each node in the pattern, -1 if not present
+	local []int
+	nodes []int
+}
+
+// FSAI calculates the factored sparse approximate inverse G^T G of a symmetric positive
+// definite matrix A. The pattern of G is the lower triangular part of the pattern of A^level,
+// where level must be at least one. The rows of G are calculated concurrently. Only the
+// lower triangular part of A is used, so A may store the lower triangle only or both
+// triangles. Contrary to IChol, the factorization never breaks down for symmetric
+// positive definite matrices.
+// The method panics if A is not square, level is smaller than one or A is not positive
+// definite. Use NewFSAI to get an error instead
+func FSAI(A *sparse.CSR, level int) FSAIPreconditioner {
+	fsai, err := NewFSAI(A, level)
+	if err != nil {
+		panic(err)
+	}
+	return fsai
+}
+
+// NewFSAI is the same as FSAI, except that it returns an error instead of panicking.
+// ErrNotSquare is returned if A is not square, ErrInvalidArgument if level is smaller than
+// one and ErrZeroPivot if a local problem is not positive definite
+func NewFSAI(A *sparse.CSR, level int) (FSAIPreconditioner, error) {
+	if err := checkSquare(A); err != nil {
+		return FSAIPreconditioner{}, err
+	}
+
+	if level < 1 {
+		return FSAIPreconditioner{}, fmt.Errorf("%w: level must be at least one, got %d", ErrInvalidArgument, level)
+	}
+
+	n, _ := A.Dims()
+	raw := A.RawMatrix()
+	rows := make([]sparseRow, n)
+
+	// The graph of A is symmetric, but only one triangle may be stored. Searching the
+	// transpose as well gives the same pattern for every storage
+	rawT := transposePattern(n, n, raw.Indptr, raw.Ind)
+
+	workers := parallel.Workers(n)
+	workspaces := make([]fsaiWorkspace, workers)
+	for w := range workspaces {
+		workspaces[w].local = make([]int, n)
+		for i := range workspaces[w].local {
+			workspaces[w].local[i] = -1
+		}
+	}
+
+	err := parallel.For(n, workers, func(worker, i int) error {
+		ws := &workspaces[worker]
+		pattern := ws.lowerPattern(raw, rawT, i, level)
+
+		for q, j := range pattern {
+			ws.local[j] = q
+		}
+		defer func() {
+			for _, j := range pattern {
+				ws.local[j] = -1
+			}
+		}()
+
+		m := len(pattern)
+		sub := mat.NewSymDense(m, nil)
+		for a, p := range pattern {
+			for k := raw.Indptr[p]; k < raw.Indptr[p+1]; k++ {
+				if b := ws.local[raw.Ind[k]]; b != -1 && b <= a {
+					sub.SetSym(a, b, raw.Data[k])
+				}
+			}
+		}
+
+		// Solve A(P, P) y = e_i, where i is the last entry of the pattern
+		var chol mat.Cholesky
+		if !chol.Factorize(sub) {
+			return ErrZeroPivot{Row: i}
+		}
+
+		rhs := mat.NewVecDense(m, nil)
+		rhs.SetVec(m-1, 1.0)
+		y := mat.NewVecDense(m, nil)
+		if err := chol.SolveVecTo(y, rhs); err != nil {
+			return ErrZeroPivot{Row: i}
+		}
+
+		diag := y.AtVec(m - 1)
+		if !(diag > 0.0) {
+			return ErrZeroPivot{Row: i, Value: diag}
+		}
+		y.ScaleVec(1.0/math.Sqrt(diag), y)
+
+		rows[i] = sparseRow{cols: pattern, values: y.RawVector().Data}
+		return nil
+	})
+	if err != nil {
+		return FSAIPreconditioner{}, err
+	}
+
+	g := rowsToCSR(rows)
+	fsai := FSAIPreconditioner{
+		g:  g,
+		gT: transposeCSR(g),
+	}
+	if n > 0 {
+		fsai.product = mat.NewVecDense(n, nil)
+	}
+	return fsai, nil
+}
+
+// lowerPattern returns the sorted columns j <= i of row i of A^level, where the
+// graph of A is the union of the pattern of A and its transpose rawT. The row
+// itself is always the last entry
+func (ws *fsaiWorkspace) lowerPattern(raw *blas.SparseMatrix, rawT transposedPattern, i, level int) []int {
+	ws.nodes = append(ws.nodes[:0], i)
+	ws.local[i] = 0
+
+	// Breadth first search in the graph of A up to a distance of level
+	start := 0
+	for l := 0; l < level; l++ {
+		end := len(ws.nodes)
+		for _, node := range ws.nodes[start:end] {
+			ws.visit(raw.Ind[raw.Indptr[node]:raw.Indptr[node+1]])
+			ws.visit(rawT.ind[rawT.indptr[node]:rawT.indptr[node+1]])
+		}
+		start = end
+	}
+
+	pattern := make([]int, 0, len(ws.nodes))
+	for _, j := range ws.nodes {
+		ws.local[j] = -1
+		if j <= i {
+			pattern = append(pattern, j)
+		}
+	}
+	slices.Sort(pattern)
+	return pattern
+}
+
+// visit adds the neighbours that have not been visited to the nodes of the search
+func (ws *fsaiWorkspace) visit(neighbours []int) {
+	for _, j := range neighbours {
+		if ws.local[j] == -1 {
+			ws.local[j] = 0
+			ws.nodes = append(ws.nodes, j)
+		}
+	}
+}
+
+// Clone returns a preconditioner that shares G with the receiver, but has its own work
+// vector, such that the clones can solve concurrently
+func (f *FSAIPreconditioner) Clone() FSAIPreconditioner {
+	clone := *f
+	if f.product != nil {
+		clone.product = mat.NewVecDense(f.product.Len(), nil)
+	}
+	return clone
+}
+
+// SolveVecTo calculates x = G^T G b. Since the preconditioner is symmetric,
+// the result does not depend on trans. The product G b is stored in a work vector in
+// the preconditioner, so the method must not be called concurrently on the same
+// preconditioner (or copies of it). Use Clone instead
+func (f *FSAIPreconditioner) SolveVecTo(dst *mat.VecDense, trans bool, rhs mat.Vector) error {
+	n, _ := f.g.Dims()
+	if err := checkVecDims(n, dst, rhs); err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+
+	csrMulVec(f.product, f.g, rhs)
+	csrMulVec(dst, f.gT, f.product)
+	return nil
+}
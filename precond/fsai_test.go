@@ -0,0 +1,124 @@
+package precond
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/davidkleiven/goprecond/precond/property"
+	"gonum.org/v1/exp/linsolve"
+	"gonum.org/v1/gonum/mat"
+	"pgregory.net/rapid"
+)
+
+func TestFSAIIsExactForFullPattern(t *testing.T) {
+	n := 10
+	tc := randomSymmetricTestCase(n)
+	fsai := FSAI(denseToCSR(tc.matrix), 1)
+
+	for _, trans := range []bool{true, false} {
+		result := mat.NewVecDense(n, nil)
+		if err := fsai.SolveVecTo(result, trans, tc.rhs); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+
+		got := mat.NewVecDense(n, nil)
+		got.MulVec(tc.matrix, result)
+		if !mat.EqualApprox(got, tc.rhs, 1e-8) {
+			t.Errorf("Wanted\n%v\ngot\n%v\n", mat.Formatted(tc.rhs), mat.Formatted(got))
+		}
+	}
+}
+
+func TestFSAIUnitDiagonal(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		matrix := property.SparseSymmetricMatrix(t, 2, 30)
+		scaled := scaledCopy(matrix, 1.0)
+		n := scaled.SymmetricDim()
+
+		// Make the matrix diagonally dominant
+		for i := 0; i < n; i++ {
+			sum := 0.0
+			for j := 0; j < n; j++ {
+				sum += math.Abs(scaled.At(i, j))
+			}
+			scaled.SetSym(i, i, sum)
+		}
+
+		level := rapid.IntRange(1, 3).Draw(t, "level")
+		fsai, err := NewFSAI(denseToCSR(scaled), level)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		// The rows of G are scaled such that G A G^T has unit diagonal
+		var product mat.Dense
+		product.Product(fsai.g, scaled, fsai.gT)
+		for i := 0; i < n; i++ {
+			if math.Abs(product.At(i, i)-1.0) > 1e-8 {
+				t.Fatalf("Wanted unit diagonal got %v in row %d", product.At(i, i), i)
+			}
+		}
+
+		// The pattern of G is lower triangular
+		fsai.g.DoNonZero(func(i, j int, v float64) {
+			if j > i {
+				t.Fatalf("Entry (%d, %d) is not in the lower triangle", i, j)
+			}
+		})
+	})
+}
+
+func TestFSAILowerTriangleStorage(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		matrix := property.SparseSymmetricMatrix(t, 2, 30)
+		scaled := scaledCopy(matrix, 1.0)
+		n := scaled.SymmetricDim()
+		for i := 0; i < n; i++ {
+			sum := 0.0
+			for j := 0; j < n; j++ {
+				sum += math.Abs(scaled.At(i, j))
+			}
+			scaled.SetSym(i, i, sum)
+		}
+
+		lower := mat.NewTriDense(n, mat.Lower, nil)
+		for i := 0; i < n; i++ {
+			for j := 0; j <= i; j++ {
+				lower.SetTri(i, j, scaled.At(i, j))
+			}
+		}
+
+		level := rapid.IntRange(1, 3).Draw(t, "level")
+		full := FSAI(denseToCSR(scaled), level)
+		lowerOnly := FSAI(denseToCSR(lower), level)
+		if !mat.Equal(full.g, lowerOnly.g) {
+			t.Fatalf("Wanted\n%v\ngot\n%v", mat.Formatted(full.g), mat.Formatted(lowerOnly.g))
+		}
+	})
+}
+
+func TestFSAIErrors(t *testing.T) {
+	identity := denseToCSR(mat.NewDense(2, 2, []float64{1.0, 0.0, 0.0, 1.0}))
+	if _, err := NewFSAI(identity, 0); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("Wanted %v got %v", ErrInvalidArgument, err)
+	}
+
+	indefinite := denseToCSR(mat.NewDense(2, 2, []float64{1.0, 2.0, 2.0, 1.0}))
+	var pivotErr ErrZeroPivot
+	if _, err := NewFSAI(indefinite, 1); !errors.As(err, &pivotErr) || pivotErr.Row != 1 {
+		t.Errorf("Wanted zero pivot in row 1 got %v", err)
+	}
+}
+
+func TestFSAIWithLinsolve(t *testing.T) {
+	tc := randomSymmetricTestCase(50)
+	matrix := NewCSRMulVecToer(denseToCSR(tc.matrix))
+	fsai := FSAI(matrix.Matrix, 2)
+
+	settings := &linsolve.Settings{PreconSolve: fsai.SolveVecTo}
+	if _, err := linsolve.Iterative(&matrix, tc.rhs, &linsolve.CG{}, settings); err != nil {
+		t.Errorf("%v", err)
+	}
+}
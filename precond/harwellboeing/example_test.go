@@ -0,0 +1,66 @@
+package harwellboeing_test
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/davidkleiven/goprecond/precond"
+	"github.com/davidkleiven/goprecond/precond/amd"
+	"github.com/davidkleiven/goprecond/precond/harwellboeing"
+	"github.com/james-bowman/sparse"
+	"gonum.org/v1/exp/linsolve"
+	"gonum.org/v1/gonum/mat"
+)
+
+func ExampleRead() {
+	// Store the Poisson matrix on a 20 x 20 grid together with a right hand side
+	n := 20
+	dok := sparse.NewDOK(n*n, n*n)
+	rhs := mat.NewDense(n*n, 1, nil)
+	for i := 0; i < n*n; i++ {
+		dok.Set(i, i, 4.0)
+		if i%n > 0 {
+			dok.Set(i, i-1, -1.0)
+			dok.Set(i-1, i, -1.0)
+		}
+		if i >= n {
+			dok.Set(i, i-n, -1.0)
+			dok.Set(i-n, i, -1.0)
+		}
+		rhs.Set(i, 0, 1.0)
+	}
+
+	var buf bytes.Buffer
+	settings := &harwellboeing.WriteSettings{Symmetry: harwellboeing.Symmetric, Title: "Poisson", Key: "POIS20", RHS: rhs}
+	if err := harwellboeing.Write(&buf, dok.ToCSR(), settings); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// Read the file back and solve the system with the incomplete Cholesky factorization
+	// of the matrix reordered by AMD
+	file, err := harwellboeing.Read(&buf)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	h := file.Header
+	fmt.Printf("%s: %d x %d %s matrix with %d stored entries\n", h.Title, h.Rows, h.Cols, h.Symmetry, h.Entries)
+
+	A := file.Matrix.ToCSR()
+	order := amd.AMD(amd.CSRAdjacencyList(A), nil)
+	ichol := precond.Reordered(A, order, precond.NewIChol)
+
+	matrix := precond.NewCSRMulVecToer(A)
+	solverSettings := &linsolve.Settings{PreconSolve: ichol.SolveVecTo, MaxIterations: 100}
+	result, err := linsolve.Iterative(&matrix, mat.VecDenseCopyOf(file.RHS.ColView(0)), &linsolve.CG{}, solverSettings)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Converged after %d iterations\n", result.Stats.Iterations)
+
+	// Output:
+	// Poisson: 400 x 400 symmetric matrix with 1160 stored entries
+	// Converged after 35 iterations
+}
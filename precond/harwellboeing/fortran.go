@@ -0,0 +1,178 @@
+package harwellboeing
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// FortranFormat is a Fortran format with a single repeated edit descriptor, such as
+// (10I8) or (1P,4E20.12). Each line holds Repeat fields of Width characters
+type FortranFormat struct {
+	// Scale factor given by the kP descriptor
+	Scale int
+
+	Repeat int
+
+	// Kind is one of I, E, D, F or G
+	Kind     byte
+	Width    int
+	Decimals int
+}
+
+// ParseFortranFormat parses a format such as (16I5), (5E16.8), (1P,4D20.12) or
+// (1P5E25.16E3). The format must contain exactly one data edit descriptor, optionally
+// preceded by a scale factor
+func ParseFortranFormat(s string) (FortranFormat, error) {
+	invalid := func(reason string) (FortranFormat, error) {
+		return FortranFormat{}, fmt.Errorf("invalid Fortran format %q: %s", s, reason)
+	}
+
+	spec := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(s), " ", ""))
+	if len(spec) < 2 || spec[0] != '(' || spec[len(spec)-1] != ')' {
+		return invalid("expected parentheses")
+	}
+	spec = spec[1 : len(spec)-1]
+
+	// readInt consumes the leading digits of spec. The default is returned if there are none
+	readInt := func(def int) int {
+		k := 0
+		for k < len(spec) && spec[k] >= '0' && spec[k] <= '9' {
+			k++
+		}
+		if k == 0 {
+			return def
+		}
+		v, _ := strconv.Atoi(spec[:k])
+		spec = spec[k:]
+		return v
+	}
+
+	f := FortranFormat{}
+	number := readInt(1)
+	if len(spec) > 0 && spec[0] == 'P' {
+		f.Scale = number
+		spec = strings.TrimPrefix(spec[1:], ",")
+		number = readInt(1)
+	}
+	f.Repeat = number
+	if f.Repeat < 1 {
+		return invalid("the repeat count must be positive")
+	}
+
+	if len(spec) == 0 || !strings.ContainsRune("IEDFG", rune(spec[0])) {
+		return invalid("expected one of the edit descriptors I, E, D, F or G")
+	}
+	f.Kind = spec[0]
+	spec = spec[1:]
+	if f.Kind == 'E' && len(spec) > 0 && (spec[0] == 'S' || spec[0] == 'N') {
+		// ES and EN are read as E
+		spec = spec[1:]
+	}
+
+	f.Width = readInt(0)
+	if f.Width < 1 {
+		return invalid("the field width must be positive")
+	}
+	if len(spec) > 0 && spec[0] == '.' {
+		spec = spec[1:]
+		f.Decimals = readInt(-1)
+		if f.Decimals < 0 {
+			return invalid("expected the number of decimals after .")
+		}
+	}
+	if f.Kind != 'I' && len(spec) > 0 && spec[0] == 'E' {
+		// Width of the exponent, which is not needed for reading
+		spec = spec[1:]
+		if readInt(-1) < 0 {
+			return invalid("expected the width of the exponent")
+		}
+	}
+	if len(spec) > 0 {
+		return invalid("only a single edit descriptor is supported")
+	}
+	return f, nil
+}
+
+// String returns the format in Fortran notation
+func (f FortranFormat) String() string {
+	var sb strings.Builder
+	sb.WriteByte('(')
+	if f.Scale != 0 {
+		fmt.Fprintf(&sb, "%dP,", f.Scale)
+	}
+	fmt.Fprintf(&sb, "%d%c%d", f.Repeat, f.Kind, f.Width)
+	if f.Kind != 'I' {
+		fmt.Fprintf(&sb, ".%d", f.Decimals)
+	}
+	sb.WriteByte(')')
+	return sb.String()
+}
+
+// lines returns the number of lines needed to hold n values. It does not overflow for
+// large n
+func (f FortranFormat) lines(n int) int {
+	return n/f.Repeat + min(n%f.Repeat, 1)
+}
+
+// fields appends the fields of a line to dst. Lines where the trailing blanks are
+// removed give fewer fields
+func (f FortranFormat) fields(line []byte, dst [][]byte) [][]byte {
+	for k := 0; k < f.Repeat; k++ {
+		start := k * f.Width
+		if start >= len(line) {
+			break
+		}
+		dst = append(dst, line[start:min(start+f.Width, len(line))])
+	}
+	return dst
+}
+
+// parseInt parses an integer field. Blanks are zero as in Fortran
+func parseInt(field []byte) (int, error) {
+	field = bytes.TrimSpace(field)
+	if len(field) == 0 {
+		return 0, nil
+	}
+	return strconv.Atoi(string(field))
+}
+
+// parseFloat parses a field of the format f. Fortran allows D as the exponent letter, and
+// omits the letter when the exponent has three digits, as in 1.0-100
+func (f FortranFormat) parseFloat(field []byte) (float64, error) {
+	field = bytes.TrimSpace(field)
+	if len(field) == 0 {
+		return 0.0, nil
+	}
+	if f.Kind == 'I' {
+		v, err := strconv.Atoi(string(field))
+		return float64(v), err
+	}
+
+	var buf [64]byte
+	number := append(buf[:0], field...)
+	hasExponent := false
+	for k, c := range number {
+		if c == 'D' || c == 'd' || c == 'E' || c == 'e' {
+			number[k] = 'E'
+			hasExponent = true
+		}
+	}
+	if k := bytes.LastIndexAny(number, "+-"); !hasExponent && k > 0 {
+		number = slices.Insert(number, k, 'E')
+		hasExponent = true
+	}
+
+	v, err := strconv.ParseFloat(string(number), 64)
+	if err != nil {
+		return 0.0, err
+	}
+	if f.Scale != 0 && !hasExponent {
+		// The scale factor only applies to values without an exponent
+		v /= math.Pow10(f.Scale)
+	}
+	return v, nil
+}
@@ -0,0 +1,76 @@
+package harwellboeing
+
+import (
+	"testing"
+)
+
+func TestParseFortranFormat(t *testing.T) {
+	for _, test := range []struct {
+		format string
+		want   FortranFormat
+		str    string
+	}{
+		{format: "(16I5)", want: FortranFormat{Repeat: 16, Kind: 'I', Width: 5}, str: "(16I5)"},
+		{format: "(5E16.8)", want: FortranFormat{Repeat: 5, Kind: 'E', Width: 16, Decimals: 8}, str: "(5E16.8)"},
+		{format: "(1P,4D20.12)", want: FortranFormat{Scale: 1, Repeat: 4, Kind: 'D', Width: 20, Decimals: 12}, str: "(1P,4D20.12)"},
+		{format: "(1p5e25.16E3)", want: FortranFormat{Scale: 1, Repeat: 5, Kind: 'E', Width: 25, Decimals: 16}, str: "(1P,5E25.16)"},
+		{format: " ( 10 I 8 ) ", want: FortranFormat{Repeat: 10, Kind: 'I', Width: 8}, str: "(10I8)"},
+		{format: "(3ES24.15)", want: FortranFormat{Repeat: 3, Kind: 'E', Width: 24, Decimals: 15}, str: "(3E24.15)"},
+		{format: "(F20.10)", want: FortranFormat{Repeat: 1, Kind: 'F', Width: 20, Decimals: 10}, str: "(1F20.10)"},
+	} {
+		got, err := ParseFortranFormat(test.format)
+		if err != nil {
+			t.Errorf("%s: %v", test.format, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: wanted %+v got %+v", test.format, test.want, got)
+		}
+		if got.String() != test.str {
+			t.Errorf("%s: wanted %s got %s", test.format, test.str, got.String())
+		}
+	}
+
+	for _, format := range []string{"16I5", "(I)", "(2I5,3I4)", "(5X10)", "(0I5)", "(4E20.)", "(4E20.12E)", "()"} {
+		if _, err := ParseFortranFormat(format); err == nil {
+			t.Errorf("%s: expected an error", format)
+		}
+	}
+}
+
+func TestParseFloat(t *testing.T) {
+	for _, test := range []struct {
+		format FortranFormat
+		field  string
+		want   float64
+	}{
+		{format: FortranFormat{Kind: 'D'}, field: "  1.5D+02", want: 150.0},
+		{format: FortranFormat{Kind: 'E'}, field: " -2.5e-3", want: -0.0025},
+		{format: FortranFormat{Kind: 'E'}, field: "1.0-100", want: 1e-100},
+		{format: FortranFormat{Kind: 'E'}, field: "-1.0+100", want: -1e100},
+		{format: FortranFormat{Kind: 'E'}, field: "    ", want: 0.0},
+		{format: FortranFormat{Kind: 'I'}, field: "  -42", want: -42.0},
+		{format: FortranFormat{Kind: 'F', Scale: 1}, field: "12.5", want: 1.25},
+		{format: FortranFormat{Kind: 'E', Scale: 1}, field: "1.25E+01", want: 12.5},
+	} {
+		got, err := test.format.parseFloat([]byte(test.field))
+		if err != nil {
+			t.Errorf("%q: %v", test.field, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%q: wanted %g got %g", test.field, test.want, got)
+		}
+	}
+}
+
+func TestFields(t *testing.T) {
+	f := FortranFormat{Repeat: 4, Kind: 'I', Width: 3}
+	var got []string
+	for _, field := range f.fields([]byte("  1100101"), nil) {
+		got = append(got, string(field))
+	}
+	if want := []string{"  1", "100", "101"}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("Wanted %q got %q", want, got)
+	}
+}
@@ -0,0 +1,127 @@
+// Package harwellboeing reads and writes matrices in the Harwell-Boeing and
+// Rutherford-Boeing formats. Both formats store an assembled sparse matrix by column
+// (compressed sparse column) in fixed width fields described by Fortran format
+// descriptors. The Harwell-Boeing format can additionally hold right hand sides, starting
+// guesses and exact solutions in the same file. Symmetric and skew-symmetric matrices
+// are stored by their lower triangle
+package harwellboeing
+
+import (
+	"fmt"
+)
+
+// Variant is the flavour of the file format
+type Variant int
+
+const (
+	// HarwellBoeing is the original format with upper case matrix types and optional
+	// right hand sides
+	HarwellBoeing Variant = iota
+
+	// RutherfordBoeing is the revised format with lower case matrix types, which adds
+	// integer matrices but stores right hand sides in separate files
+	RutherfordBoeing
+)
+
+// Field is the type of the values
+type Field int
+
+const (
+	// Real values are floating point numbers
+	Real Field = iota
+
+	// Integer values are only supported by the Rutherford-Boeing format
+	Integer
+
+	// Pattern matrices store no values. All entries are read as one
+	Pattern
+)
+
+func (f Field) String() string {
+	switch f {
+	case Real:
+		return "real"
+	case Integer:
+		return "integer"
+	case Pattern:
+		return "pattern"
+	}
+	return fmt.Sprintf("Field(%d)", int(f))
+}
+
+// Symmetry determines which entries are stored
+type Symmetry int
+
+const (
+	// General stores all entries. This covers both unsymmetric and rectangular matrices
+	General Symmetry = iota
+
+	// Symmetric stores the lower triangle including the diagonal
+	Symmetric
+
+	// SkewSymmetric stores the strictly lower triangle
+	SkewSymmetric
+)
+
+func (s Symmetry) String() string {
+	switch s {
+	case General:
+		return "general"
+	case Symmetric:
+		return "symmetric"
+	case SkewSymmetric:
+		return "skew-symmetric"
+	}
+	return fmt.Sprintf("Symmetry(%d)", int(s))
+}
+
+// stored returns true if the entry (i, j) is part of the stored triangle
+func (s Symmetry) stored(i, j int) bool {
+	switch s {
+	case Symmetric:
+		return i >= j
+	case SkewSymmetric:
+		return i > j
+	}
+	return true
+}
+
+// Header holds the first lines of a file
+type Header struct {
+	// Title of at most 72 characters and key of at most 8 characters
+	Title string
+	Key   string
+
+	Field    Field
+	Symmetry Symmetry
+
+	Rows int
+	Cols int
+
+	// Number of stored entries. For symmetric and skew-symmetric matrices only the lower
+	// triangle is counted
+	Entries int
+
+	// Formats of the column pointers, the row indices, the values and the right hand sides
+	PtrFormat FortranFormat
+	IndFormat FortranFormat
+	ValFormat FortranFormat
+	RHSFormat FortranFormat
+
+	// Number of right hand sides, and whether starting guesses and exact solutions are
+	// stored. Only the Harwell-Boeing format holds right hand sides
+	NumRHS      int
+	HasGuess    bool
+	HasSolution bool
+}
+
+// ErrFormat is returned when a file does not follow the Harwell-Boeing or the
+// Rutherford-Boeing format
+type ErrFormat struct {
+	Line   int
+	Reason string
+}
+
+func (e ErrFormat) Error() string {
+	return fmt.Sprintf("harwell-boeing: line %d: %s", e.Line, e.Reason)
+}
@@ -0,0 +1,430 @@
+package harwellboeing
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/davidkleiven/goprecond/precond/internal/coo"
+	"github.com/james-bowman/sparse"
+	"gonum.org/v1/gonum/mat"
+)
+
+// File is the content of a Harwell-Boeing or Rutherford-Boeing file
+type File struct {
+	Header Header
+
+	// Matrix where symmetric and skew-symmetric matrices are expanded, such that both
+	// triangles are stored
+	Matrix *sparse.CSC
+
+	// Right hand sides, starting guesses and exact solutions as Rows x NumRHS matrices.
+	// They are nil if they are not part of the file
+	RHS      *mat.Dense
+	Guess    *mat.Dense
+	Solution *mat.Dense
+}
+
+// reader reads a file line by line
+type reader struct {
+	scanner *bufio.Scanner
+	line    int
+	fields  [][]byte
+}
+
+func (r *reader) formatError(format string, args ...any) error {
+	return ErrFormat{Line: r.line, Reason: fmt.Sprintf(format, args...)}
+}
+
+// next returns the next line. Reaching the end of the file is an error
+func (r *reader) next(what string) ([]byte, error) {
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, r.formatError("unexpected end of file while reading %s", what)
+	}
+	r.line++
+	return bytes.TrimRight(r.scanner.Bytes(), "\r"), nil
+}
+
+// readValues reads n values with the format f, starting on a new line
+func (r *reader) readValues(n int, f FortranFormat, what string, parse func(k int, field []byte) error) error {
+	for k := 0; k < n; {
+		line, err := r.next(what)
+		if err != nil {
+			return err
+		}
+		r.fields = f.fields(line, r.fields[:0])
+		if len(r.fields) == 0 {
+			return r.formatError("expected %s", what)
+		}
+		for _, field := range r.fields {
+			if k == n {
+				break
+			}
+			if err := parse(k, field); err != nil {
+				return r.formatError("invalid %s %q", what, field)
+			}
+			k++
+		}
+	}
+	return nil
+}
+
+// headerInts parses the whitespace separated integers of a header line
+func (r *reader) headerInts(line []byte, minCount int, what string) ([]int, error) {
+	fields := bytes.Fields(line)
+	if len(fields) < minCount {
+		return nil, r.formatError("expected at least %d integers in the %s", minCount, what)
+	}
+	values := make([]int, len(fields))
+	for k, field := range fields {
+		v, err := parseInt(field)
+		if err != nil || v < 0 {
+			return nil, r.formatError("invalid integer %q in the %s", field, what)
+		}
+		values[k] = v
+	}
+	return values, nil
+}
+
+// formats returns the Fortran formats enclosed in parentheses on the line
+func (r *reader) formats(line []byte) ([]FortranFormat, error) {
+	var formats []FortranFormat
+	for {
+		start := bytes.IndexByte(line, '(')
+		if start == -1 {
+			return formats, nil
+		}
+		end := bytes.IndexByte(line[start:], ')')
+		if end == -1 {
+			return nil, r.formatError("unbalanced parentheses in the formats")
+		}
+		f, err := ParseFortranFormat(string(line[start : start+end+1]))
+		if err != nil {
+			return nil, r.formatError("%v", err)
+		}
+		formats = append(formats, f)
+		line = line[start+end+1:]
+	}
+}
+
+// readHeader reads the four header lines, and the fifth line describing the right hand
+// sides if the file holds any
+func (r *reader) readHeader() (Header, error) {
+	var h Header
+	line, err := r.next("the title")
+	if err != nil {
+		return h, err
+	}
+	title := string(line)
+	h.Title = strings.TrimSpace(title[:min(72, len(title))])
+	if len(title) > 72 {
+		h.Key = strings.TrimSpace(title[72:min(80, len(title))])
+	}
+
+	if line, err = r.next("the line counts"); err != nil {
+		return h, err
+	}
+	counts, err := r.headerInts(line, 4, "line counts")
+	if err != nil {
+		return h, err
+	}
+	countsLine := r.line
+	ptrLines, indLines, valLines, rhsLines := counts[1], counts[2], counts[3], 0
+	if len(counts) > 4 {
+		rhsLines = counts[4]
+	}
+
+	if line, err = r.next("the matrix type"); err != nil {
+		return h, err
+	}
+	if len(line) < 3 {
+		return h, r.formatError("expected the three letter matrix type")
+	}
+	mxtype := strings.ToUpper(string(line[:3]))
+	switch mxtype[0] {
+	case 'R':
+		h.Field = Real
+	case 'I':
+		h.Field = Integer
+	case 'P', 'Q':
+		h.Field = Pattern
+	default:
+		return h, r.formatError("unsupported value type %q in %q", mxtype[0], mxtype)
+	}
+	switch mxtype[1] {
+	case 'U', 'R':
+		h.Symmetry = General
+	case 'S':
+		h.Symmetry = Symmetric
+	case 'Z':
+		h.Symmetry = SkewSymmetric
+	default:
+		return h, r.formatError("unsupported symmetry %q in %q", mxtype[1], mxtype)
+	}
+	if mxtype[2] != 'A' {
+		return h, r.formatError("only assembled matrices are supported, got %q", mxtype)
+	}
+
+	dims, err := r.headerInts(line[3:], 3, "matrix dimensions")
+	if err != nil {
+		return h, err
+	}
+	h.Rows, h.Cols, h.Entries = dims[0], dims[1], dims[2]
+	if h.Symmetry != General && h.Rows != h.Cols {
+		return h, r.formatError("a %s matrix must be square, got %d x %d", h.Symmetry, h.Rows, h.Cols)
+	}
+
+	// The sizes are not trusted, since they are used to size the storage
+	if h.Rows > 0 && h.Cols > math.MaxInt/h.Rows {
+		return h, r.formatError("the size %d x %d is too large", h.Rows, h.Cols)
+	}
+	if h.Entries > h.Rows*h.Cols {
+		return h, r.formatError("%d entries do not fit in a %d x %d matrix", h.Entries, h.Rows, h.Cols)
+	}
+
+	if line, err = r.next("the formats"); err != nil {
+		return h, err
+	}
+	formats, err := r.formats(line)
+	if err != nil {
+		return h, err
+	}
+	numFormats := 3
+	if h.Field == Pattern {
+		numFormats = 2
+	}
+	if rhsLines > 0 {
+		numFormats++
+	}
+	if len(formats) < numFormats {
+		return h, r.formatError("expected %d formats, got %d", numFormats, len(formats))
+	}
+	h.PtrFormat, h.IndFormat = formats[0], formats[1]
+	switch {
+	case h.Field == Pattern && rhsLines > 0 && len(formats) == 3:
+		// The value format of a pattern matrix is usually left blank
+		h.RHSFormat = formats[2]
+	case len(formats) > 3:
+		h.ValFormat, h.RHSFormat = formats[2], formats[3]
+	case len(formats) > 2:
+		h.ValFormat = formats[2]
+	}
+
+	if rhsLines > 0 {
+		if line, err = r.next("the right hand side type"); err != nil {
+			return h, err
+		}
+		if len(line) < 3 {
+			return h, r.formatError("expected the three letter right hand side type")
+		}
+		rhstype := strings.ToUpper(string(line[:3]))
+		if rhstype[0] != 'F' {
+			return h, r.formatError("only right hand sides in full storage are supported, got %q", rhstype)
+		}
+		h.HasGuess = rhstype[1] == 'G'
+		h.HasSolution = rhstype[2] == 'X'
+
+		numRHS, err := r.headerInts(line[3:], 1, "right hand side count")
+		if err != nil {
+			return h, err
+		}
+		h.NumRHS = numRHS[0]
+		if h.NumRHS > 0 && h.Rows > math.MaxInt/h.NumRHS {
+			return h, r.formatError("%d right hand sides of length %d are too many", h.NumRHS, h.Rows)
+		}
+	}
+
+	// The values must fit on the number of lines given in the line counts
+	numVectors := 1
+	if h.HasGuess {
+		numVectors++
+	}
+	if h.HasSolution {
+		numVectors++
+	}
+	var reason string
+	switch {
+	case h.PtrFormat.lines(h.Cols+1) > ptrLines:
+		reason = fmt.Sprintf("%d column pointers do not fit on %d lines", h.Cols+1, ptrLines)
+	case h.IndFormat.lines(h.Entries) > indLines:
+		reason = fmt.Sprintf("%d row indices do not fit on %d lines", h.Entries, indLines)
+	case h.Field != Pattern && h.ValFormat.lines(h.Entries) > valLines:
+		reason = fmt.Sprintf("%d values do not fit on %d lines", h.Entries, valLines)
+	case h.NumRHS > 0 && h.RHSFormat.lines(h.Rows*h.NumRHS) > rhsLines/numVectors:
+		reason = fmt.Sprintf("%d vectors of length %d do not fit on %d lines", numVectors*h.NumRHS, h.Rows, rhsLines)
+	}
+	if reason != "" {
+		return h, ErrFormat{Line: countsLine, Reason: reason}
+	}
+	return h, nil
+}
+
+// readVectors reads the dense Rows x NumRHS matrix of right hand sides, starting guesses
+// or exact solutions
+func (r *reader) readVectors(h Header, what string) (*mat.Dense, error) {
+	if h.Rows == 0 || h.NumRHS == 0 {
+		return nil, nil
+	}
+
+	// The vectors are stored one after another
+	n := h.Rows * h.NumRHS
+	vectors := make([]float64, 0, min(n, coo.MaxCapacityHint))
+	err := r.readValues(n, h.RHSFormat, what, func(k int, field []byte) error {
+		v, err := h.RHSFormat.parseFloat(field)
+		vectors = append(vectors, v)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]float64, n)
+	for k, v := range vectors {
+		data[(k%h.Rows)*h.NumRHS+k/h.Rows] = v
+	}
+	return mat.NewDense(h.Rows, h.NumRHS, data), nil
+}
+
+// read reads a file, where the symmetry of the matrix is expanded
+func read(in io.Reader) (*File, coo.Triplets, error) {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), math.MaxInt32)
+	r := &reader{scanner: scanner}
+
+	h, err := r.readHeader()
+	if err != nil {
+		return nil, coo.Triplets{}, err
+	}
+
+	colPtr := make([]int, 0, min(h.Cols+1, coo.MaxCapacityHint))
+	err = r.readValues(h.Cols+1, h.PtrFormat, "column pointer", func(k int, field []byte) error {
+		v, err := parseInt(field)
+		if err == nil && v < 1 {
+			err = fmt.Errorf("column pointer must be positive")
+		}
+		colPtr = append(colPtr, v-1)
+		return err
+	})
+	if err != nil {
+		return nil, coo.Triplets{}, err
+	}
+	if colPtr[0] != 0 {
+		return nil, coo.Triplets{}, r.formatError("the first column pointer must be 1, got %d", colPtr[0]+1)
+	}
+	if colPtr[h.Cols] != h.Entries {
+		return nil, coo.Triplets{}, r.formatError("the last column pointer must be one more than the %d entries, got %d", h.Entries, colPtr[h.Cols]+1)
+	}
+	for j := 0; j < h.Cols; j++ {
+		if colPtr[j+1] < colPtr[j] {
+			return nil, coo.Triplets{}, r.formatError("the column pointers must be non-decreasing")
+		}
+	}
+
+	rowInd := make([]int, 0, min(h.Entries, coo.MaxCapacityHint))
+	err = r.readValues(h.Entries, h.IndFormat, "row index", func(k int, field []byte) error {
+		v, err := parseInt(field)
+		if err == nil && (v < 1 || v > h.Rows) {
+			err = fmt.Errorf("row index outside the range [1, %d]", h.Rows)
+		}
+		rowInd = append(rowInd, v-1)
+		return err
+	})
+	if err != nil {
+		return nil, coo.Triplets{}, err
+	}
+
+	// The row indices have been read, thus the number of entries is known to be valid
+	values := make([]float64, 0, h.Entries)
+	if h.Field == Pattern {
+		for range h.Entries {
+			values = append(values, 1.0)
+		}
+	} else {
+		err = r.readValues(h.Entries, h.ValFormat, "value", func(k int, field []byte) error {
+			v, err := h.ValFormat.parseFloat(field)
+			values = append(values, v)
+			return err
+		})
+		if err != nil {
+			return nil, coo.Triplets{}, err
+		}
+	}
+
+	file := &File{Header: h}
+	if file.RHS, err = r.readVectors(h, "right hand side"); err != nil {
+		return nil, coo.Triplets{}, err
+	}
+	if h.HasGuess {
+		if file.Guess, err = r.readVectors(h, "starting guess"); err != nil {
+			return nil, coo.Triplets{}, err
+		}
+	}
+	if h.HasSolution {
+		if file.Solution, err = r.readVectors(h, "exact solution"); err != nil {
+			return nil, coo.Triplets{}, err
+		}
+	}
+
+	capacity := min(h.Entries, coo.MaxCapacityHint)
+	if h.Symmetry != General {
+		capacity *= 2
+	}
+	t := coo.New(h.Rows, h.Cols, capacity)
+	for j := 0; j < h.Cols; j++ {
+		for p := colPtr[j]; p < colPtr[j+1]; p++ {
+			i, v := rowInd[p], values[p]
+			if h.Symmetry == SkewSymmetric && i == j {
+				return nil, coo.Triplets{}, ErrFormat{Line: r.line, Reason: fmt.Sprintf("a skew-symmetric matrix can not store the diagonal entry of column %d", j+1)}
+			}
+
+			t.Append(i, j, v)
+			if i != j {
+				switch h.Symmetry {
+				case Symmetric:
+					t.Append(j, i, v)
+				case SkewSymmetric:
+					t.Append(j, i, -v)
+				}
+			}
+		}
+	}
+	return file, t, nil
+}
+
+// Read reads a Harwell-Boeing or Rutherford-Boeing file. The variant is detected from the
+// header. Right hand sides, starting guesses and exact solutions are read if present.
+// Complex, hermitian and elemental matrices, and right hand sides that are not stored in
+// full, are not supported. ErrFormat is returned if the file is malformed
+func Read(r io.Reader) (*File, error) {
+	file, t, err := read(r)
+	if err != nil {
+		return nil, err
+	}
+	file.Matrix = t.CSC()
+	return file, nil
+}
+
+// ReadCSC reads the matrix of a file into a CSC matrix with sorted row indices
+func ReadCSC(r io.Reader) (*sparse.CSC, error) {
+	file, err := Read(r)
+	if err != nil {
+		return nil, err
+	}
+	return file.Matrix, nil
+}
+
+// ReadCSR reads the matrix of a file into a CSR matrix with sorted column indices, which
+// can be passed directly to the factorizations in the precond package
+func ReadCSR(r io.Reader) (*sparse.CSR, error) {
+	_, t, err := read(r)
+	if err != nil {
+		return nil, err
+	}
+	return t.CSR(), nil
+}
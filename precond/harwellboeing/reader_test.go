@@ -0,0 +1,177 @@
+package harwellboeing
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// symmetricFile is a symmetric 4 x 4 matrix with a right hand side and a starting guess.
+// The row indices run together, and the values use the D exponent and a three digit
+// exponent without the exponent letter
+var symmetricFile = strings.Join([]string{
+	"Symmetric test matrix                                                   TEST0001",
+	"             8             1             1             2             4",
+	"RSA                        4             4             7             0",
+	"(5I3)           (26I3)          (4D20.12)           (3E20.12)           ",
+	"FG                         1             0",
+	"  1  4  6  7  8",
+	"  1  2  4  2  3  3  4",
+	"  4.000000000000D+00 -1.000000000000D+00  1.000000000000-100  4.000000000000D+00",
+	" -1.000000000000D+00  4.000000000000D+00  2.500000000000D+00",
+	"  1.000000000000E+00  2.000000000000E+00  3.000000000000E+00",
+	"  4.000000000000E+00",
+	"  5.000000000000E-01  5.000000000000E-01  5.000000000000E-01",
+	"  5.000000000000E-01",
+}, "\n")
+
+func TestReadHarwellBoeing(t *testing.T) {
+	file, err := Read(strings.NewReader(symmetricFile))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	h := file.Header
+	if h.Title != "Symmetric test matrix" || h.Key != "TEST0001" {
+		t.Errorf("Wrong title %q or key %q", h.Title, h.Key)
+	}
+	if h.Field != Real || h.Symmetry != Symmetric || h.Rows != 4 || h.Cols != 4 || h.Entries != 7 {
+		t.Errorf("Wrong header %+v", h)
+	}
+	if h.NumRHS != 1 || !h.HasGuess || h.HasSolution {
+		t.Errorf("Wrong right hand side description %+v", h)
+	}
+
+	want := mat.NewDense(4, 4, []float64{
+		4.0, -1.0, 0.0, 1e-100,
+		-1.0, 4.0, -1.0, 0.0,
+		0.0, -1.0, 4.0, 0.0,
+		1e-100, 0.0, 0.0, 2.5,
+	})
+	if !mat.Equal(file.Matrix, want) {
+		t.Errorf("Wanted\n%v\ngot\n%v", mat.Formatted(want), mat.Formatted(file.Matrix))
+	}
+	if rhs := mat.NewDense(4, 1, []float64{1.0, 2.0, 3.0, 4.0}); !mat.Equal(file.RHS, rhs) {
+		t.Errorf("Wanted right hand side %v got %v", mat.Formatted(rhs), mat.Formatted(file.RHS))
+	}
+	if guess := mat.NewDense(4, 1, []float64{0.5, 0.5, 0.5, 0.5}); !mat.Equal(file.Guess, guess) {
+		t.Errorf("Wanted starting guess %v got %v", mat.Formatted(guess), mat.Formatted(file.Guess))
+	}
+	if file.Solution != nil {
+		t.Errorf("Expected no exact solution")
+	}
+
+	A, err := ReadCSR(strings.NewReader(symmetricFile))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !mat.Equal(A, want) {
+		t.Errorf("Expected the CSR matrix to match the CSC matrix")
+	}
+}
+
+func TestReadRutherfordBoeing(t *testing.T) {
+	for _, test := range []struct {
+		file string
+		want *mat.Dense
+		desc string
+	}{
+		{
+			file: strings.Join([]string{
+				"Rectangular integer matrix                                              RECT",
+				"             3             1             1             1",
+				"ira                        3             2             4             0",
+				"(3I4)           (4I4)           (4I5)",
+				"   1   3   5",
+				"   1   3   2   3",
+				"    7   -2    5   11",
+			}, "\n"),
+			want: mat.NewDense(3, 2, []float64{7.0, 0.0, 0.0, 5.0, -2.0, 11.0}),
+			desc: "rectangular integer",
+		},
+		{
+			file: strings.Join([]string{
+				"Pattern matrix",
+				"             2             1             1             0",
+				"psa                        3             3             3             0",
+				"(4I2)           (3I2)",
+				" 1 3 4 4",
+				" 1 2 3",
+			}, "\n"),
+			want: mat.NewDense(3, 3, []float64{1.0, 1.0, 0.0, 1.0, 0.0, 1.0, 0.0, 1.0, 0.0}),
+			desc: "symmetric pattern",
+		},
+		{
+			file: strings.Join([]string{
+				"Skew-symmetric matrix",
+				"             3             1             1             1",
+				"rza                        3             3             2             0",
+				"(4I2)           (2I2)           (2E10.2)",
+				" 1 2 3 3",
+				" 2 3",
+				"   1.5E+00  -2.0E+00",
+			}, "\n"),
+			want: mat.NewDense(3, 3, []float64{0.0, -1.5, 0.0, 1.5, 0.0, 2.0, 0.0, -2.0, 0.0}),
+			desc: "skew-symmetric",
+		},
+	} {
+		file, err := Read(strings.NewReader(test.file))
+		if err != nil {
+			t.Errorf("%s: %v", test.desc, err)
+			continue
+		}
+		if !mat.Equal(file.Matrix, test.want) {
+			t.Errorf("%s: wanted\n%v\ngot\n%v", test.desc, mat.Formatted(test.want), mat.Formatted(file.Matrix))
+		}
+		if file.RHS != nil {
+			t.Errorf("%s: expected no right hand side", test.desc)
+		}
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	lines := strings.Split(symmetricFile, "\n")
+	replace := func(line int, content string) string {
+		modified := append([]string{}, lines...)
+		modified[line] = content
+		return strings.Join(modified, "\n")
+	}
+
+	for _, test := range []struct {
+		file string
+		line int
+		desc string
+	}{
+		{file: "", line: 0, desc: "empty file"},
+		{file: strings.Join(lines[:3], "\n"), line: 3, desc: "missing formats"},
+		{file: replace(1, "1 2 3"), line: 2, desc: "missing line counts"},
+		{file: replace(2, "CSA                        4             4             7             0"), line: 3, desc: "complex matrix"},
+		{file: replace(2, "RHA                        4             4             7             0"), line: 3, desc: "hermitian matrix"},
+		{file: replace(2, "RSE                        4             4             7             0"), line: 3, desc: "elemental matrix"},
+		{file: replace(2, "RSA                        4             5             7             0"), line: 3, desc: "rectangular symmetric matrix"},
+		{file: replace(3, "(5I3)           (26I3)"), line: 4, desc: "missing value format"},
+		{file: replace(3, "(5I3)           (26I3)          (4X20)              (3E20.12)"), line: 4, desc: "invalid value format"},
+		{file: replace(4, "MG                         1             0"), line: 5, desc: "sparse right hand side"},
+		{file: replace(5, "  2  4  6  7  8"), line: 6, desc: "pointers not starting at one"},
+		{file: replace(5, "  1  6  4  7  8"), line: 6, desc: "decreasing pointers"},
+		{file: replace(6, "  1  2  5  2  3  3  4"), line: 7, desc: "row index out of range"},
+		{file: replace(7, "  4.000000000000D+00 -1.0000000000XXD+00"), line: 8, desc: "invalid value"},
+		{file: strings.Join(lines[:10], "\n"), line: 10, desc: "truncated right hand side"},
+		{file: replace(2, "RUA                        2   9223372036854775807             1             0"), line: 3, desc: "size overflows"},
+		{file: replace(2, "RSA                        4             4            17             0"), line: 3, desc: "more entries than the matrix holds"},
+		{file: replace(4, "FG                3037000500             0"), line: 2, desc: "too many right hand sides for the line counts"},
+		{file: replace(4, "FG       4611686018427387904             0"), line: 5, desc: "number of right hand side values overflows"},
+		{file: replace(4, "FG                         2             0"), line: 2, desc: "right hand sides do not fit on the lines"},
+		{file: replace(1, "             7             1             1             1             4"), line: 2, desc: "values do not fit on the lines"},
+		{file: replace(5, "  1  4  6  7  9"), line: 6, desc: "last pointer is not the number of entries"},
+		{file: replace(5, "  1  4 -6  7  8"), line: 6, desc: "negative pointer"},
+	} {
+		_, err := Read(strings.NewReader(test.file))
+		var formatErr ErrFormat
+		if !errors.As(err, &formatErr) || formatErr.Line != test.line {
+			t.Errorf("%s: wanted ErrFormat at line %d got %v", test.desc, test.line, err)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package harwellboeing
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/davidkleiven/goprecond/precond"
+	"github.com/davidkleiven/goprecond/precond/internal/coo"
+	"gonum.org/v1/gonum/mat"
+)
+
+// WriteSettings controls the header and the optional right hand sides of the written
+// file. The zero value writes a real, general Harwell-Boeing file
+type WriteSettings struct {
+	Variant  Variant
+	Field    Field
+	Symmetry Symmetry
+
+	// Title is truncated to 72 characters and Key to 8 characters
+	Title string
+	Key   string
+
+	// Right hand sides, starting guesses and exact solutions. Each must have as many rows
+	// as the matrix, and Guess and Solution must have the same number of columns as RHS.
+	// They can only be written to Harwell-Boeing files
+	RHS      mat.Matrix
+	Guess    mat.Matrix
+	Solution mat.Matrix
+}
+
+func (s *WriteSettings) withDefaults() WriteSettings {
+	if s == nil {
+		return WriteSettings{}
+	}
+	return *s
+}
+
+// check verifies that A and the right hand sides can be written with the settings
+func (s *WriteSettings) check(A precond.ZeroAwareMatrix) error {
+	rows, cols := A.Dims()
+	if s.Symmetry != General && rows != cols {
+		return precond.ErrNotSquare{Rows: rows, Cols: cols}
+	}
+	if s.Field == Integer && s.Variant == HarwellBoeing {
+		return fmt.Errorf("%w: integer matrices can only be written to Rutherford-Boeing files", precond.ErrInvalidArgument)
+	}
+
+	if s.RHS == nil && (s.Guess != nil || s.Solution != nil) {
+		return fmt.Errorf("%w: starting guesses and exact solutions require right hand sides", precond.ErrInvalidArgument)
+	}
+	if s.RHS != nil {
+		if s.Variant != HarwellBoeing {
+			return fmt.Errorf("%w: right hand sides can only be written to Harwell-Boeing files", precond.ErrInvalidArgument)
+		}
+		if r, _ := s.RHS.Dims(); r != rows {
+			return fmt.Errorf("%w: the right hand sides have %d rows, but the matrix has %d rows", precond.ErrInvalidArgument, r, rows)
+		}
+		_, numRHS := s.RHS.Dims()
+		for _, m := range []mat.Matrix{s.Guess, s.Solution} {
+			if m == nil {
+				continue
+			}
+			if r, c := m.Dims(); r != rows || c != numRHS {
+				return fmt.Errorf("%w: expected %d x %d starting guesses and solutions, got %d x %d", precond.ErrInvalidArgument, rows, numRHS, r, c)
+			}
+		}
+	}
+
+	sign := 1.0
+	if s.Symmetry == SkewSymmetric {
+		sign = -1.0
+	}
+
+	var err error
+	A.DoNonZero(func(i, j int, v float64) {
+		if err != nil {
+			return
+		}
+		if s.Field == Integer && v != math.Trunc(v) {
+			err = fmt.Errorf("%w: entry (%d, %d) is not an integer, got %g", precond.ErrInvalidArgument, i, j, v)
+			return
+		}
+
+		switch {
+		case s.Symmetry == SkewSymmetric && i == j && v != 0.0:
+			err = fmt.Errorf("%w: the diagonal of a skew-symmetric matrix must be zero, got %g in row %d", precond.ErrInvalidArgument, v, i)
+		case s.Symmetry != General && i != j && A.At(j, i) != sign*v:
+			err = fmt.Errorf("%w: the matrix is not %s, entry (%d, %d) is %g and entry (%d, %d) is %g", precond.ErrInvalidArgument, s.Symmetry, i, j, v, j, i, A.At(j, i))
+		}
+	})
+	return err
+}
+
+// mxtype returns the three letter matrix type
+func (s *WriteSettings) mxtype(rows, cols int) string {
+	letters := []byte{'R', 'U', 'A'}
+	switch s.Field {
+	case Integer:
+		letters[0] = 'I'
+	case Pattern:
+		letters[0] = 'P'
+	}
+	switch {
+	case s.Symmetry == Symmetric:
+		letters[1] = 'S'
+	case s.Symmetry == SkewSymmetric:
+		letters[1] = 'Z'
+	case rows != cols:
+		letters[1] = 'R'
+	}
+
+	if s.Variant == RutherfordBoeing {
+		return strings.ToLower(string(letters))
+	}
+	return string(letters)
+}
+
+// integerFormat returns a format that fits integers with absolute value at most maxAbs
+// on lines of at most 80 characters
+func integerFormat(maxAbs int) FortranFormat {
+	width := len(strconv.Itoa(maxAbs)) + 2
+	return FortranFormat{Repeat: max(1, 80/width), Kind: 'I', Width: width}
+}
+
+// realFormat holds 17 significant digits, such that the values are written exactly
+var realFormat = FortranFormat{Scale: 1, Repeat: 3, Kind: 'E', Width: 26, Decimals: 16}
+
+// writeValues writes n values with the format f, starting on a new line. The values are
+// right aligned in their fields
+func writeValues(w *bufio.Writer, n int, f FortranFormat, value func(k int) float64) {
+	var buf []byte
+	for k := 0; k < n; k++ {
+		buf = buf[:0]
+		if f.Kind == 'I' {
+			buf = strconv.AppendInt(buf, int64(value(k)), 10)
+		} else {
+			// With the scale factor 1P there is one digit before the decimal point
+			buf = strconv.AppendFloat(buf, value(k), 'E', f.Decimals, 64)
+		}
+		for pad := f.Width - len(buf); pad > 0; pad-- {
+			w.WriteByte(' ')
+		}
+		w.Write(buf)
+		if (k+1)%f.Repeat == 0 || k == n-1 {
+			w.WriteByte('\n')
+		}
+	}
+}
+
+// Write writes A to w in the Harwell-Boeing or Rutherford-Boeing format. For symmetric
+// and skew-symmetric matrices only the lower triangle is written. ErrNotSquare is returned
+// if a symmetry is requested for a non-square matrix, and ErrInvalidArgument if A does
+// not have the requested symmetry, holds non-integer values when the integer field is
+// requested, or the right hand sides do not match the settings. Real values are written
+// with 17 significant digits, such that they are read back exactly
+func Write(w io.Writer, A precond.ZeroAwareMatrix, settings *WriteSettings) error {
+	s := settings.withDefaults()
+	if err := s.check(A); err != nil {
+		return err
+	}
+
+	// Compressed sparse column storage of the stored triangle, with sorted row indices
+	rows, cols := A.Dims()
+	var colInd, rowInd []int
+	var values []float64
+	A.DoNonZero(func(i, j int, v float64) {
+		if s.Symmetry.stored(i, j) {
+			colInd = append(colInd, j)
+			rowInd = append(rowInd, i)
+			values = append(values, v)
+		}
+	})
+	colPtr, rowInd, values := coo.Compress(colInd, rowInd, values, cols, rows)
+	nnz := len(rowInd)
+
+	ptrFormat := integerFormat(nnz + 1)
+	indFormat := integerFormat(rows)
+	valFormat := realFormat
+	if s.Field == Integer {
+		maxAbs := 0
+		for _, v := range values {
+			maxAbs = max(maxAbs, int(math.Abs(v)))
+		}
+		valFormat = integerFormat(maxAbs)
+	}
+
+	numRHS, numVectors := 0, 0
+	if s.RHS != nil {
+		_, numRHS = s.RHS.Dims()
+	}
+	if rows*numRHS > 0 {
+		numVectors = 1
+		if s.Guess != nil {
+			numVectors++
+		}
+		if s.Solution != nil {
+			numVectors++
+		}
+	}
+
+	ptrLines, indLines := ptrFormat.lines(cols+1), indFormat.lines(nnz)
+	valLines := 0
+	if s.Field != Pattern {
+		valLines = valFormat.lines(nnz)
+	}
+	rhsLines := numVectors * realFormat.lines(rows*numRHS)
+
+	bw := bufio.NewWriter(w)
+	title, key := s.Title, s.Key
+	fmt.Fprintf(bw, "%-72s%-8s\n", title[:min(72, len(title))], key[:min(8, len(key))])
+
+	valFormatString := ""
+	if s.Field != Pattern {
+		valFormatString = valFormat.String()
+	}
+	if s.Variant == HarwellBoeing {
+		fmt.Fprintf(bw, "%14d%14d%14d%14d%14d\n", ptrLines+indLines+valLines+rhsLines, ptrLines, indLines, valLines, rhsLines)
+		fmt.Fprintf(bw, "%-3s%11s%14d%14d%14d%14d\n", s.mxtype(rows, cols), "", rows, cols, nnz, 0)
+		rhsFormatString := ""
+		if numVectors > 0 {
+			rhsFormatString = realFormat.String()
+		}
+		fmt.Fprintf(bw, "%-16s%-16s%-20s%-20s\n", ptrFormat, indFormat, valFormatString, rhsFormatString)
+		if numVectors > 0 {
+			rhstype := []byte("F  ")
+			if s.Guess != nil {
+				rhstype[1] = 'G'
+			}
+			if s.Solution != nil {
+				rhstype[2] = 'X'
+			}
+			fmt.Fprintf(bw, "%-3s%11s%14d%14d\n", rhstype, "", numRHS, 0)
+		}
+	} else {
+		fmt.Fprintf(bw, "%14d%14d%14d%14d\n", ptrLines+indLines+valLines, ptrLines, indLines, valLines)
+		fmt.Fprintf(bw, "%-3s%11s%14d%14d%14d%14d\n", s.mxtype(rows, cols), "", rows, cols, nnz, 0)
+		fmt.Fprintf(bw, "%-16s%-16s%-20s\n", ptrFormat, indFormat, valFormatString)
+	}
+
+	writeValues(bw, cols+1, ptrFormat, func(k int) float64 { return float64(colPtr[k] + 1) })
+	writeValues(bw, nnz, indFormat, func(k int) float64 { return float64(rowInd[k] + 1) })
+	if s.Field != Pattern {
+		writeValues(bw, nnz, valFormat, func(k int) float64 { return values[k] })
+	}
+
+	// The vectors are written one after another
+	for _, m := range []mat.Matrix{s.RHS, s.Guess, s.Solution} {
+		if m != nil && numVectors > 0 {
+			writeValues(bw, rows*numRHS, realFormat, func(k int) float64 { return m.At(k%rows, k/rows) })
+		}
+	}
+
+	// Errors from the underlying writer are sticky and returned by Flush
+	return bw.Flush()
+}
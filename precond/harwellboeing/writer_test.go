@@ -0,0 +1,218 @@
+package harwellboeing
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/davidkleiven/goprecond/precond"
+	"github.com/davidkleiven/goprecond/precond/precondtest"
+	"github.com/james-bowman/sparse"
+	"gonum.org/v1/gonum/mat"
+	"pgregory.net/rapid"
+)
+
+// sparseMatrix draws a matrix with the requested symmetry, where roughly a third of the
+// entries are non-zero
+func sparseMatrix(t *rapid.T, symmetry Symmetry, field Field) *mat.Dense {
+	rows := rapid.IntRange(1, 12).Draw(t, "rows")
+	cols := rows
+	if symmetry == General {
+		cols = rapid.IntRange(1, 12).Draw(t, "cols")
+	}
+
+	A := mat.NewDense(rows, cols, nil)
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			if !symmetry.stored(i, j) || rapid.IntRange(0, 2).Draw(t, "zero") != 0 {
+				continue
+			}
+
+			v := rapid.Float64Range(-1e6, 1e6).Draw(t, "value")
+			switch field {
+			case Integer:
+				v = math.Round(v)
+			case Pattern:
+				v = 1.0
+			}
+			A.Set(i, j, v)
+			switch symmetry {
+			case Symmetric:
+				A.Set(j, i, v)
+			case SkewSymmetric:
+				A.Set(j, i, -v)
+			}
+		}
+	}
+	return A
+}
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		settings := &WriteSettings{
+			Variant:  rapid.SampledFrom([]Variant{HarwellBoeing, RutherfordBoeing}).Draw(t, "variant"),
+			Symmetry: rapid.SampledFrom([]Symmetry{General, Symmetric, SkewSymmetric}).Draw(t, "symmetry"),
+			Title:    "Round trip",
+			Key:      "RT",
+		}
+		fields := []Field{Real, Pattern}
+		if settings.Variant == RutherfordBoeing {
+			fields = append(fields, Integer)
+		}
+		settings.Field = rapid.SampledFrom(fields).Draw(t, "field")
+
+		A := sparseMatrix(t, settings.Symmetry, settings.Field)
+		rows, _ := A.Dims()
+		if settings.Variant == HarwellBoeing && rapid.Bool().Draw(t, "rhs") {
+			numRHS := rapid.IntRange(1, 3).Draw(t, "num-rhs")
+			vectors := func(label string) *mat.Dense {
+				data := rapid.SliceOfN(rapid.Float64Range(-1e3, 1e3), rows*numRHS, rows*numRHS).Draw(t, label)
+				return mat.NewDense(rows, numRHS, data)
+			}
+			settings.RHS = vectors("rhs")
+			if rapid.Bool().Draw(t, "guess") {
+				settings.Guess = vectors("guess")
+			}
+			if rapid.Bool().Draw(t, "solution") {
+				settings.Solution = vectors("solution")
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := Write(&buf, &precondtest.DenseNonZeroDoer{Dense: A}, settings); err != nil {
+			t.Fatalf("%v", err)
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+			if len(line) > 80 {
+				t.Fatalf("Line with more than 80 characters: %q", line)
+			}
+		}
+
+		file, err := Read(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("%v\n%s", err, buf.String())
+		}
+
+		h := file.Header
+		if h.Title != settings.Title || h.Key != settings.Key || h.Field != settings.Field || h.Symmetry != settings.Symmetry {
+			t.Fatalf("The header does not match the settings %+v. Got %+v", settings, h)
+		}
+		if !mat.Equal(file.Matrix, A) {
+			t.Fatalf("Wanted\n%v\ngot\n%v", mat.Formatted(A), mat.Formatted(file.Matrix))
+		}
+
+		for _, test := range []struct {
+			want mat.Matrix
+			got  *mat.Dense
+			desc string
+		}{
+			{want: settings.RHS, got: file.RHS, desc: "right hand side"},
+			{want: settings.Guess, got: file.Guess, desc: "starting guess"},
+			{want: settings.Solution, got: file.Solution, desc: "exact solution"},
+		} {
+			if (test.want == nil) != (test.got == nil) {
+				t.Fatalf("%s: expected %v got %v", test.desc, test.want, test.got)
+			}
+			if test.want != nil && !mat.Equal(test.want, test.got) {
+				t.Fatalf("%s: wanted\n%v\ngot\n%v", test.desc, mat.Formatted(test.want), mat.Formatted(test.got))
+			}
+		}
+	})
+}
+
+func TestWriteOutput(t *testing.T) {
+	A := sparse.NewCSR(2, 3, []int{0, 2, 3}, []int{0, 2, 1}, []float64{1.5, -2.0, 0.1})
+	var buf strings.Builder
+	if err := Write(&buf, A, &WriteSettings{Variant: RutherfordBoeing, Title: "Example", Key: "EX"}); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	want := strings.Join([]string{
+		"Example                                                                 EX      ",
+		"             3             1             1             1",
+		"rra                        2             3             3             0",
+		"(26I3)          (26I3)          (1P,3E26.16)        ",
+		"  1  2  3  4",
+		"  1  2  1",
+		"    1.5000000000000000E+00    1.0000000000000001E-01   -2.0000000000000000E+00",
+		"",
+	}, "\n")
+	if buf.String() != want {
+		t.Errorf("Wanted\n%s\ngot\n%s", want, buf.String())
+	}
+}
+
+func TestWriteErrors(t *testing.T) {
+	nonSymmetric := sparse.NewCSR(2, 2, []int{0, 1, 2}, []int{1, 1}, []float64{1.0, 2.0})
+	for _, test := range []struct {
+		matrix   precond.ZeroAwareMatrix
+		settings *WriteSettings
+		want     error
+		desc     string
+	}{
+		{
+			matrix:   sparse.NewCSR(1, 2, []int{0, 1}, []int{0}, []float64{1.0}),
+			settings: &WriteSettings{Symmetry: Symmetric},
+			want:     precond.ErrNotSquare{Rows: 1, Cols: 2},
+			desc:     "non-square symmetric",
+		},
+		{
+			matrix:   nonSymmetric,
+			settings: &WriteSettings{Symmetry: Symmetric},
+			want:     precond.ErrInvalidArgument,
+			desc:     "not symmetric",
+		},
+		{
+			matrix:   sparse.NewCSR(1, 1, []int{0, 1}, []int{0}, []float64{1.0}),
+			settings: &WriteSettings{Symmetry: SkewSymmetric},
+			want:     precond.ErrInvalidArgument,
+			desc:     "skew-symmetric with diagonal",
+		},
+		{
+			matrix:   nonSymmetric,
+			settings: &WriteSettings{Field: Integer},
+			want:     precond.ErrInvalidArgument,
+			desc:     "integer Harwell-Boeing",
+		},
+		{
+			matrix:   sparse.NewCSR(1, 1, []int{0, 1}, []int{0}, []float64{1.5}),
+			settings: &WriteSettings{Variant: RutherfordBoeing, Field: Integer},
+			want:     precond.ErrInvalidArgument,
+			desc:     "non-integer value",
+		},
+		{
+			matrix:   nonSymmetric,
+			settings: &WriteSettings{Variant: RutherfordBoeing, RHS: mat.NewDense(2, 1, nil)},
+			want:     precond.ErrInvalidArgument,
+			desc:     "Rutherford-Boeing right hand side",
+		},
+		{
+			matrix:   nonSymmetric,
+			settings: &WriteSettings{RHS: mat.NewDense(3, 1, nil)},
+			want:     precond.ErrInvalidArgument,
+			desc:     "wrong number of rows in the right hand side",
+		},
+		{
+			matrix:   nonSymmetric,
+			settings: &WriteSettings{RHS: mat.NewDense(2, 1, nil), Guess: mat.NewDense(2, 2, nil)},
+			want:     precond.ErrInvalidArgument,
+			desc:     "wrong number of starting guesses",
+		},
+		{
+			matrix:   nonSymmetric,
+			settings: &WriteSettings{Solution: mat.NewDense(2, 1, nil)},
+			want:     precond.ErrInvalidArgument,
+			desc:     "solution without right hand side",
+		},
+	} {
+		var buf bytes.Buffer
+		if err := Write(&buf, test.matrix, test.settings); !errors.Is(err, test.want) {
+			t.Errorf("%s: wanted %v got %v", test.desc, test.want, err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("%s: expected nothing to be written", test.desc)
+		}
+	}
+}
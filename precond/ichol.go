@@ -1,55 +1,76 @@
 package precond
 
-import (
-	"math"
+import "math"
 
-	"github.com/james-bowman/sparse"
-)
+// eliminateCholesky calculates the values of the incomplete cholesky factor L in place.
+// On entry, lower must contain the lower triangular part of A scattered into the pattern
+// of L. Row i of L is calculated from the previous rows as
+//
+//	L(i, j) = (A(i, j) - sum_k L(i, k)L(j, k)) / L(j, j)
+//	L(i, i) = sqrt(A(i, i) - sum_k L(i, k)^2)
+//
+// where only entries that are part of the pattern are included in the sums.
+// pos is a work array of length n that must be filled with -1. It is left in that state on return
+func eliminateCholesky(pattern iluPattern, lower []float64, pos []int) error {
+	n := len(pattern.lowerIndptr) - 1
+	for i := 0; i < n; i++ {
+		start, end := pattern.lowerIndptr[i], pattern.lowerIndptr[i+1]
+		for idx := start; idx < end; idx++ {
+			pos[pattern.lowerInd[idx]] = idx
+		}
+
+		diag := lower[end-1]
+		for idx := start; idx < end-1; idx++ {
+			j := pattern.lowerInd[idx]
+			jStart, jEnd := pattern.lowerIndptr[j], pattern.lowerIndptr[j+1]
+
+			sum := 0.0
+			for jk := jStart; jk < jEnd-1; jk++ {
+				if p := pos[pattern.lowerInd[jk]]; p >= 0 {
+					sum += lower[p] * lower[jk]
+				}
+			}
+			lower[idx] = (lower[idx] - sum) / lower[jEnd-1]
+			diag -= lower[idx] * lower[idx]
+		}
+
+		for idx := start; idx < end; idx++ {
+			pos[pattern.lowerInd[idx]] = -1
+		}
+
+		if isZeroPivot(diag) || diag < 0.0 {
+			return ErrZeroPivot{Row: i, Value: diag}
+		}
+		lower[end-1] = math.Sqrt(diag)
+	}
+	return nil
+}
 
 // Calculate the incomplete cholesky transformation
 // The incomplete cholesky decompositoin is a special case of the
 // incomplete LU transformation where U = L^T. Therefore, an instance
 // if the ILUPreconditioner is returned
 // The method panics if the provided matrix is not square or if the diagonal
-// contain any non-positive elements
+// contain any non-positive elements. Use NewIChol to get an error instead
 func IChol(A ZeroAwareMatrix) ILUPreconditioner {
-	r, c := A.Dims()
-	if r != c {
-		panic("Matrix must be square")
-	}
-
-	lower := emptyDOK(r)
-	A.DoNonZero(func(i, j int, v float64) { lower[i][j] = v })
-	checkDiag(lower, r, true)
-
-	for i := 0; i < r; i++ {
-		diag := math.Sqrt(lower[i][i])
-		lower[i][i] = diag
-		for j := range lower[i] {
-			if j > i {
-				lower[j][i] /= diag
-				for k := range lower[j] {
-					if k > i {
-						lower[j][k] -= lower[j][i] * lower[i][k]
-					}
-				}
-			}
-		}
+	ichol, err := NewIChol(A)
+	if err != nil {
+		panic(err)
 	}
+	return ichol
+}
 
-	lowerSpDOK := sparse.NewDOK(r, r)
-	for i, row := range lower {
-		for j, v := range row {
-			lowerSpDOK.Set(i, j, v)
-		}
+// NewIChol is the same as IChol, except that it returns an error instead of panicking.
+// ErrNotSquare is returned if A is not square, and ErrZeroPivot if the diagonal contains
+// non-positive elements or a pivot is negative or smaller than 1e-8
+func NewIChol(A ZeroAwareMatrix) (ILUPreconditioner, error) {
+	if err := checkSquare(A); err != nil {
+		return ILUPreconditioner{}, err
 	}
 
-	lowerCSR := lowerSpDOK.ToCSR()
-	upper := transposeCSR(lowerCSR)
-	return ILUPreconditioner{
-		lower:  lowerSpDOK.ToCSR(),
-		upper:  upper,
-		lowerT: upper,
-		upperT: lowerCSR,
+	rows := collectRows(A)
+	if err := checkRowDiag(rows, true); err != nil {
+		return ILUPreconditioner{}, err
 	}
+	return analyzeICholRows(rows).Factorize(A)
 }
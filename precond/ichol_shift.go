@@ -0,0 +1,174 @@
+package precond
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// ShiftSettings controls the diagonal shifts tried by ShiftedIChol
+type ShiftSettings struct {
+	// Shift used on the first restart. Defaults to 1e-3
+	InitialShift float64
+
+	// Factor the shift is multiplied with on each subsequent restart. Defaults to 2
+	GrowthFactor float64
+
+	// Maximum number of restarts before giving up. Defaults to 64
+	MaxRestarts int
+}
+
+func (s *ShiftSettings) withDefaults() ShiftSettings {
+	result := ShiftSettings{
+		InitialShift: 1e-3,
+		GrowthFactor: 2.0,
+		MaxRestarts:  64,
+	}
+
+	if s == nil {
+		return result
+	}
+
+	if s.InitialShift > 0.0 {
+		result.InitialShift = s.InitialShift
+	}
+	if s.GrowthFactor > 1.0 {
+		result.GrowthFactor = s.GrowthFactor
+	}
+	if s.MaxRestarts > 0 {
+		result.MaxRestarts = s.MaxRestarts
+	}
+	return result
+}
+
+// ShiftInfo reports the diagonal shift that was needed for the incomplete
+// cholesky factorization to succeed
+type ShiftInfo struct {
+	// Shift is the value of alpha in A + alpha*diag(A)
+	Shift float64
+
+	// Restarts is the number of times the factorization was restarted
+	Restarts int
+}
+
+// shiftedMatrix represents A + alpha*D where D is a diagonal matrix
+type shiftedMatrix struct {
+	ZeroAwareMatrix
+	alpha float64
+	diag  []float64
+
+	// Diagonal entries of A that are explicitly stored
+	hasDiag []bool
+}
+
+func (s *shiftedMatrix) At(i, j int) float64 {
+	v := s.ZeroAwareMatrix.At(i, j)
+	if i == j {
+		v += s.alpha * s.diag[i]
+	}
+	return v
+}
+
+func (s *shiftedMatrix) T() mat.Matrix {
+	return mat.Transpose{Matrix: s}
+}
+
+func (s *shiftedMatrix) DoNonZero(fn func(i, j int, v float64)) {
+	s.ZeroAwareMatrix.DoNonZero(func(i, j int, v float64) {
+		if i == j {
+			v += s.alpha * s.diag[i]
+		}
+		fn(i, j, v)
+	})
+
+	for i, ok := range s.hasDiag {
+		if !ok {
+			fn(i, i, s.alpha*s.diag[i])
+		}
+	}
+}
+
+// ShiftedIChol calculates a robust incomplete cholesky factorization of A. If the
+// factorization breaks down because of a non-positive pivot, it is restarted with the
+// Manteuffel shift A + alpha*diag(A), where alpha grows on each restart until the
+// factorization succeeds. Diagonal entries that are negative, zero or tiny compared to
+// the rest of the row are replaced by the largest absolute value in the row when forming
+// diag(A), such that the shift always moves the matrix towards a diagonally dominant matrix.
+// Entries of diag(A) below the pivot tolerance 1e-8 are raised to it, such that the shift
+// can lift the pivots of badly scaled matrices above the tolerance.
+//
+// If settings is nil, the default settings are used. The returned ShiftInfo reports the
+// shift that was used and the number of restarts. An error is returned if A is not
+// square (ErrNotSquare) or if the factorization did not succeed within the maximum number
+// of restarts. In the latter case the error wraps the ErrZeroPivot from the last attempt.
+// Only ErrZeroPivot triggers a restart, any other error is returned immediately
+func ShiftedIChol(A ZeroAwareMatrix, settings *ShiftSettings) (ILUPreconditioner, ShiftInfo, error) {
+	if err := checkSquare(A); err != nil {
+		return ILUPreconditioner{}, ShiftInfo{}, err
+	}
+	r, _ := A.Dims()
+
+	opts := settings.withDefaults()
+	shifted := shiftedMatrix{
+		ZeroAwareMatrix: A,
+		diag:            make([]float64, r),
+		hasDiag:         make([]bool, r),
+	}
+
+	rowMax := make([]float64, r)
+	A.DoNonZero(func(i, j int, v float64) {
+		rowMax[i] = math.Max(rowMax[i], math.Abs(v))
+		if i == j {
+			shifted.hasDiag[i] = true
+			shifted.diag[i] = v
+		}
+	})
+
+	for i, d := range shifted.diag {
+		if d <= pivotTolerance*rowMax[i] {
+			shifted.diag[i] = rowMax[i]
+		}
+		if shifted.diag[i] == 0.0 {
+			// Empty rows. Any positive value makes the pivot positive
+			shifted.diag[i] = 1.0
+		}
+		shifted.diag[i] = max(shifted.diag[i], pivotTolerance)
+	}
+
+	symbolic, err := NewSymbolicIChol(&shifted)
+	if err != nil {
+		return ILUPreconditioner{}, ShiftInfo{}, err
+	}
+
+	// The factors are computed in place since a failed attempt is never used
+	ichol := symbolic.allocate()
+	lower := ichol.lower.RawMatrix().Data
+	upper := ichol.upper.RawMatrix().Data
+	err = symbolic.numeric(&shifted, lower, upper, ichol.pos)
+
+	info := ShiftInfo{}
+	var pivotErr ErrZeroPivot
+	for err != nil {
+		if !errors.As(err, &pivotErr) {
+			// Shifting the diagonal does not help for other errors
+			return ILUPreconditioner{}, info, err
+		}
+
+		if info.Restarts == opts.MaxRestarts {
+			return ILUPreconditioner{}, info, fmt.Errorf("incomplete cholesky did not succeed after %d restarts (shift %e): %w", info.Restarts, info.Shift, err)
+		}
+
+		if info.Shift == 0.0 {
+			info.Shift = opts.InitialShift
+		} else {
+			info.Shift *= opts.GrowthFactor
+		}
+		info.Restarts++
+
+		shifted.alpha = info.Shift
+		err = symbolic.numeric(&shifted, lower, upper, ichol.pos)
+	}
+	return ichol, info, nil
+}
@@ -0,0 +1,119 @@
+package precond
+
+import (
+	"math"
+	"testing"
+
+	"github.com/davidkleiven/goprecond/precond/precondtest"
+	"github.com/davidkleiven/goprecond/precond/property"
+	"gonum.org/v1/gonum/mat"
+	"pgregory.net/rapid"
+)
+
+func TestShiftedICholNoShiftForSPD(t *testing.T) {
+	c := randomSymmetricTestCase(10)
+	zeroAware := &precondtest.DenseNonZeroDoer{Dense: c.matrix}
+
+	ichol, info, err := ShiftedIChol(zeroAware, nil)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if info.Shift != 0.0 || info.Restarts != 0 {
+		t.Errorf("Positive definite matrices should not be shifted. Got %+v", info)
+	}
+
+	want := IChol(zeroAware)
+	if !mat.EqualApprox(ichol.lower, want.lower, 1e-12) {
+		t.Errorf("Wanted\n%v\ngot\n%v\n", mat.Formatted(want.lower), mat.Formatted(ichol.lower))
+	}
+}
+
+func TestShiftedICholBreakdown(t *testing.T) {
+	for _, test := range []struct {
+		matrix *mat.Dense
+		desc   string
+	}{
+		{
+			// Positive diagonal, but the second pivot is 1 - 4 < 0
+			matrix: mat.NewDense(2, 2, []float64{1.0, 2.0, 2.0, 1.0}),
+			desc:   "Pivot breaks down during factorization",
+		},
+		{
+			// The second pivot is positive, but smaller than the pivot tolerance
+			matrix: mat.NewDense(2, 2, []float64{1.0, 1.0, 1.0, 1.0 + 1e-12}),
+			desc:   "Tiny pivot",
+		},
+		{
+			matrix: mat.NewDense(2, 2, []float64{-1.0, 0.5, 0.5, 2.0}),
+			desc:   "Negative diagonal",
+		},
+		{
+			matrix: mat.NewDense(3, 3, []float64{0.0, 1.0, 0.0, 1.0, 0.0, 0.0, 0.0, 0.0, 0.0}),
+			desc:   "Zero diagonal and empty row",
+		},
+	} {
+		ichol, info, err := ShiftedIChol(&precondtest.DenseNonZeroDoer{Dense: test.matrix}, nil)
+		if err != nil {
+			t.Errorf("Test %s: %v", test.desc, err)
+			continue
+		}
+
+		if info.Shift <= 0.0 || info.Restarts == 0 {
+			t.Errorf("Test %s: expected a positive shift and at least one restart. Got %+v", test.desc, info)
+		}
+
+		n, _ := test.matrix.Dims()
+		rhs := mat.NewVecDense(n, nil)
+		for i := 0; i < n; i++ {
+			rhs.SetVec(i, 1.0)
+		}
+
+		result := mat.NewVecDense(n, nil)
+		if err := ichol.SolveVecTo(result, false, rhs); err != nil {
+			t.Errorf("Test %s: %v", test.desc, err)
+		}
+
+		for i := 0; i < n; i++ {
+			if v := result.AtVec(i); math.IsNaN(v) || math.IsInf(v, 0) {
+				t.Errorf("Test %s: the solution should be finite. Got\n%v\n", test.desc, mat.Formatted(result))
+				break
+			}
+		}
+	}
+}
+
+func TestShiftedICholMaxRestarts(t *testing.T) {
+	matrix := &precondtest.DenseNonZeroDoer{Dense: mat.NewDense(2, 2, []float64{1.0, 2.0, 2.0, 1.0})}
+
+	// The required shift is larger than 1e-6 * 2^3
+	_, info, err := ShiftedIChol(matrix, &ShiftSettings{InitialShift: 1e-6, MaxRestarts: 3})
+	if err == nil {
+		t.Errorf("Expected an error when the maximum number of restarts is reached")
+	}
+
+	if info.Restarts != 3 {
+		t.Errorf("Wanted 3 restarts got %d", info.Restarts)
+	}
+}
+
+func TestShiftedICholNonSquare(t *testing.T) {
+	matrix := &precondtest.DenseNonZeroDoer{Dense: mat.NewDense(2, 3, nil)}
+	if _, _, err := ShiftedIChol(matrix, nil); err == nil {
+		t.Errorf("Expected an error for non-square matrix")
+	}
+}
+
+func TestShiftedICholNeverFailsOnSymmetricMatrices(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		matrix := property.DenseSquareMatrix(t, 1, 30)
+		symmetric := mat.NewDense(matrix.RawMatrix().Rows, matrix.RawMatrix().Cols, nil)
+		symmetric.Add(matrix, matrix.T())
+
+		_, info, err := ShiftedIChol(&precondtest.DenseNonZeroDoer{Dense: symmetric}, nil)
+		if err != nil {
+			t.Fatalf("%v (%+v)", err, info)
+		}
+	})
+}
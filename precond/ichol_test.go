@@ -14,8 +14,13 @@ func randomSymmetricTestCase(N int) testCase {
 	c := randomTestCase(N)
 	c.matrix.Add(c.matrix, c.matrix.T())
 
+	// Make the matrix diagonally dominant such that it is positive definite
 	for i := 0; i < N; i++ {
-		c.matrix.Set(i, i, math.Abs(c.matrix.At(i, i)))
+		sum := 0.0
+		for j := 0; j < N; j++ {
+			sum += math.Abs(c.matrix.At(i, j))
+		}
+		c.matrix.Set(i, i, sum)
 	}
 	return c
 }
@@ -30,8 +35,8 @@ func TestICholSolution(t *testing.T) {
 			icholResultvec := mat.NewVecDense(n, nil)
 			ichoTResultVec := mat.NewVecDense(n, nil)
 			dotProduct := mat.NewVecDense(n, nil)
-			ichol.SolveVecTo(ichoTResultVec, false, c.rhs)
-			ichol.SolveVecTo(ichoTResultVec, false, c.rhs)
+			ichol.SolveVecTo(icholResultvec, false, c.rhs)
+			ichol.SolveVecTo(ichoTResultVec, true, c.rhs)
 			dotProduct.MulVec(c.matrix, icholResultvec)
 
 			tol := 1e-6
@@ -41,7 +46,7 @@ func TestICholSolution(t *testing.T) {
 					return
 				}
 
-				if math.Abs(icholResultvec.AtVec(i)-dotProduct.AtVec(i)) > tol {
+				if math.Abs(c.rhs.AtVec(i)-dotProduct.AtVec(i)) > tol {
 					t.Errorf("Test #%d: wanted\n%v\ngot\n%v\n", testNum, c.rhs, dotProduct)
 					return
 				}
@@ -50,3 +55,12 @@ func TestICholSolution(t *testing.T) {
 		})
 	}
 }
+
+func BenchmarkIChol(b *testing.B) {
+	A := gridLaplacian(benchmarkGridSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		IChol(A)
+	}
+}
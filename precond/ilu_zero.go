@@ -1,8 +1,7 @@
 package precond
 
 import (
-	"fmt"
-	"math"
+	"slices"
 
 	"github.com/james-bowman/sparse"
 	"gonum.org/v1/gonum/mat"
@@ -13,11 +12,48 @@ type ZeroAwareMatrix interface {
 	mat.NonZeroDoer
 }
 
+// ILUPreconditioner holds incomplete LU (or cholesky) factors of a matrix. The work vector
+// used by SolveVecTo is shared by copies of a preconditioner, thus it is not safe for
+// concurrent use. Use Clone to get a preconditioner for each goroutine
 type ILUPreconditioner struct {
 	lower  *sparse.CSR
 	upper  *sparse.CSR
 	lowerT *sparse.CSR
 	upperT *sparse.CSR
+
+	// Index of the diagonal in each row of the factors and of the transposed factors
+	lowerDiag  []int
+	upperDiag  []int
+	lowerTDiag []int
+	upperTDiag []int
+
+	// Symbolic analysis the factorization was created from. It is nil
+	// if the factorization can not be refactored
+	symbolic *Symbolic
+
+	// Work array used when refactoring
+	pos []int
+
+	// Values of the factors computed by Refactor. They replace the values of the
+	// factors once the factorization has succeeded
+	newLower []float64
+	newUpper []float64
+
+	// Work vector used by SolveVecTo
+	work []float64
+}
+
+// newILUPreconditioner creates a preconditioner from the factors L and U, which must
+// both contain the diagonal
+func newILUPreconditioner(lower, upper *sparse.CSR) ILUPreconditioner {
+	n, _ := lower.Dims()
+	return ILUPreconditioner{
+		lower:     lower,
+		upper:     upper,
+		lowerDiag: diagonalIndices(lower),
+		upperDiag: diagonalIndices(upper),
+		work:      make([]float64, n),
+	}
 }
 
 func (ilu *ILUPreconditioner) initT() {
@@ -26,137 +62,138 @@ func (ilu *ILUPreconditioner) initT() {
 		return
 	}
 
-	ilu.lowerT = transposeCSR(ilu.lower)
-	ilu.upperT = transposeCSR(ilu.upper)
+	if s := ilu.symbolic; s != nil {
+		// Use the transposed pattern from the symbolic analysis such that the
+		// transposed factors are updated on refactorization
+		lowerT := make([]float64, len(s.lowerT.ind))
+		upperT := make([]float64, len(s.upperT.ind))
+		s.lowerT.scatterTranspose(lowerT, ilu.lower.RawMatrix().Data)
+		s.upperT.scatterTranspose(upperT, ilu.upper.RawMatrix().Data)
+		ilu.lowerT = sparse.NewCSR(s.n, s.n, s.lowerT.indptr, s.lowerT.ind, lowerT)
+		ilu.upperT = sparse.NewCSR(s.n, s.n, s.upperT.indptr, s.upperT.ind, upperT)
+	} else {
+		ilu.lowerT = transposeCSR(ilu.lower)
+		ilu.upperT = transposeCSR(ilu.upper)
+	}
+	ilu.lowerTDiag = diagonalIndices(ilu.lowerT)
+	ilu.upperTDiag = diagonalIndices(ilu.upperT)
 }
 
 func (ilu *ILUPreconditioner) checkDimensions(dst *mat.VecDense, rhs mat.Vector) error {
 	n, _ := ilu.lower.Dims()
-	dstDim, _ := dst.Dims()
-	rhsDim, _ := rhs.Dims()
-
-	if dstDim != n || rhsDim != n {
-		return fmt.Errorf("expected lengths to be %d, got dst: %d and rhs: %d", n, dstDim, rhsDim)
-	}
-	return nil
+	return checkVecDims(n, dst, rhs)
 }
 
 // SolveVecTo solves the linear system of equation given by
-// Ax = b using the LU transformation stored in the receiver
+// Ax = b using the LU transformation stored in the receiver. No memory is allocated,
+// except for the transposed factors that are created in the first call with trans
+// equal to true. The solve uses a work vector stored in the receiver (and shared by
+// its copies), thus SolveVecTo must not be called concurrently. Use Clone to get a
+// preconditioner for each goroutine
 func (ilu *ILUPreconditioner) SolveVecTo(dst *mat.VecDense, trans bool, rhs mat.Vector) error {
 	if err := ilu.checkDimensions(dst, rhs); err != nil {
 		return err
 	}
 
-	n, _ := dst.Dims()
-	tmpSolution := mat.NewVecDense(n, nil)
-
+	copyFromVec(ilu.work, rhs)
 	if trans {
 		// Initialize the transposed matrices on request
 		ilu.initT()
-		forwardSubstition(ilu.upperT, tmpSolution, rhs)
-		backwardSubstitiion(ilu.lowerT, dst, tmpSolution)
+		forwardSubstitution(ilu.upperT, ilu.upperTDiag, ilu.work)
+		backwardSubstitution(ilu.lowerT, ilu.lowerTDiag, ilu.work)
 	} else {
-		forwardSubstition(ilu.lower, tmpSolution, rhs)
-		backwardSubstitiion(ilu.upper, dst, tmpSolution)
+		forwardSubstitution(ilu.lower, ilu.lowerDiag, ilu.work)
+		backwardSubstitution(ilu.upper, ilu.upperDiag, ilu.work)
 	}
-
+	copyToVec(dst, ilu.work)
 	return nil
 }
 
-func forwardSubstition(lower *sparse.CSR, dst *mat.VecDense, rhs mat.Vector) {
-	n, _ := rhs.Dims()
-	for i := 0; i < n; i++ {
-		sum := 0.0
-		lower.DoRowNonZero(i, func(i, j int, v float64) {
-			if j < i {
-				sum += v * dst.AtVec(j)
-			}
-		})
-		dst.SetVec(i, (rhs.AtVec(i)-sum)/lower.At(i, i))
+// Clone returns a preconditioner that shares the factors with the receiver, but has its
+// own work vector. The clones can solve concurrently, as long as none of them is
+// refactored at the same time. Refactor on any of them updates the factors of all.
+// Clone modifies the receiver by creating the transposed factors, such that they are
+// shared as well, thus it must not be called concurrently with SolveVecTo
+func (ilu *ILUPreconditioner) Clone() ILUPreconditioner {
+	if ilu.lower == nil {
+		return ILUPreconditioner{}
 	}
+	ilu.initT()
+	clone := *ilu
+	clone.work = make([]float64, len(ilu.work))
+	return clone
 }
 
-func backwardSubstitiion(upper *sparse.CSR, dst *mat.VecDense, rhs mat.Vector) {
-	n, _ := rhs.Dims()
-	for i := n - 1; i >= 0; i-- {
-		sum := 0.0
-		upper.DoRowNonZero(i, func(i, j int, v float64) {
-			if j > i {
-				sum += v * dst.AtVec(j)
-			}
-		})
-
-		dst.SetVec(i, (rhs.AtVec(i)-sum)/upper.At(i, i))
-	}
+// Lower returns the lower triangular factor L, including the diagonal. The matrix shares
+// storage with the receiver, thus it is updated by Refactor and must not be modified
+func (ilu *ILUPreconditioner) Lower() *sparse.CSR {
+	return ilu.lower
 }
 
-func checkDiag(lu map[int]map[int]float64, N int, requirePositive bool) {
-	tol := 1e-8
-	for i := 0; i < N; i++ {
-		if diag, ok := lu[i][i]; !ok || math.Abs(diag) < tol || (diag < tol && requirePositive) {
-			panic("Zero on diagonal")
+// Upper returns the upper triangular factor U, including the diagonal. The matrix shares
+// storage with the receiver, thus it is updated by Refactor and must not be modified
+func (ilu *ILUPreconditioner) Upper() *sparse.CSR {
+	return ilu.upper
+}
+
+// diagonalIndices returns the index of the diagonal in each row of a square CSR matrix.
+// The index is -1 if the diagonal is not stored
+func diagonalIndices(matrix *sparse.CSR) []int {
+	raw := matrix.RawMatrix()
+	diag := make([]int, raw.I)
+	for i := range diag {
+		start := raw.Indptr[i]
+		if idx := slices.Index(raw.Ind[start:raw.Indptr[i+1]], i); idx >= 0 {
+			diag[i] = start + idx
+		} else {
+			diag[i] = -1
 		}
 	}
+	return diag
 }
 
-func emptyDOK(N int) map[int]map[int]float64 {
-	dok := make(map[int]map[int]float64)
-	for i := 0; i < N; i++ {
-		dok[i] = make(map[int]float64)
+// forwardSubstitution solves Lx = b in place, where x contains b on entry. L is a lower
+// triangular CSR matrix and diag holds the index of the diagonal in each row
+func forwardSubstitution(lower *sparse.CSR, diag []int, x []float64) {
+	raw := lower.RawMatrix()
+	for i := range x {
+		x[i] = (x[i] - offDiagonalDot(raw.Indptr, raw.Ind, raw.Data, i, diag[i], x)) / raw.Data[diag[i]]
 	}
-	return dok
 }
 
-// ILUZero calculates the incomplete LU decomposition of the matrix A
-// If A is dense, this is the same as the complete LU decomposition
-// The method panics if A is not square or the diagonal contains zeros
-func ILUZero(A ZeroAwareMatrix) ILUPreconditioner {
-	nrows, ncols := A.Dims()
-	if nrows != ncols {
-		panic("Matrix must be square")
+// backwardSubstitution solves Ux = b in place, where x contains b on entry. U is an upper
+// triangular CSR matrix and diag holds the index of the diagonal in each row
+func backwardSubstitution(upper *sparse.CSR, diag []int, x []float64) {
+	raw := upper.RawMatrix()
+	for i := len(x) - 1; i >= 0; i-- {
+		x[i] = (x[i] - offDiagonalDot(raw.Indptr, raw.Ind, raw.Data, i, diag[i], x)) / raw.Data[diag[i]]
 	}
+}
 
-	lu := emptyDOK(nrows)
-
-	// Transfer matrix into temporary lu
-	A.DoNonZero(func(i, j int, v float64) {
-		lu[i][j] = v
-	})
-	checkDiag(lu, nrows, false)
-
-	for i := 0; i < nrows; i++ {
-		diag := lu[i][i]
-		for j := range lu[i] {
-			if j > i {
-				lu[j][i] /= diag
-				for k := range lu[j] {
-					if k > i {
-						lu[j][k] -= lu[j][i] * lu[i][k]
-					}
-				}
-			}
+// offDiagonalDot returns the dot product of row i of a CSR matrix and x, where the
+// diagonal stored at index d is excluded
+func offDiagonalDot(indptr, ind []int, data []float64, i, d int, x []float64) float64 {
+	sum := 0.0
+	for k := indptr[i]; k < indptr[i+1]; k++ {
+		if k != d {
+			sum += data[k] * x[ind[k]]
 		}
 	}
+	return sum
+}
 
-	// Collect upper/lower matrices
-	upper := sparse.NewDOK(nrows, nrows)
-	lower := sparse.NewDOK(nrows, ncols)
-	for i, row := range lu {
-		for j, v := range row {
-			if j >= i {
-				upper.Set(i, j, v)
-			} else {
-				lower.Set(i, j, v)
-			}
-		}
-	}
+// ILUZero calculates the incomplete LU decomposition of the matrix A
+// If A is dense, this is the same as the complete LU decomposition
+// ILUZero is the same as ILUK with zero level of fill
+// The method panics if A is not square or the diagonal contains zeros.
+// Use NewILUZero to get an error instead
+func ILUZero(A ZeroAwareMatrix) ILUPreconditioner {
+	return ILUK(A, 0)
+}
 
-	for i := 0; i < nrows; i++ {
-		lower.Set(i, i, 1.0)
-	}
-	return ILUPreconditioner{
-		upper: upper.ToCSR(),
-		lower: lower.ToCSR(),
-	}
+// NewILUZero is the same as ILUZero, except that it returns an error instead of
+// panicking. ErrNotSquare is returned if A is not square, and ErrZeroPivot if the
+// diagonal contains zeros or a zero pivot is encountered
+func NewILUZero(A ZeroAwareMatrix) (ILUPreconditioner, error) {
+	return NewILUK(A, 0)
 }
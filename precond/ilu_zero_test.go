@@ -3,6 +3,7 @@ package precond
 import (
 	"fmt"
 	"math"
+	"sync"
 	"testing"
 
 	"github.com/davidkleiven/goprecond/precond/precondtest"
@@ -23,9 +24,9 @@ func TestKnownDecompositions(t *testing.T) {
 			wantU:  mat.NewDense(2, 2, []float64{1.0, 2.0, 0.0, -2.0}),
 		},
 		{
-			matrix: mat.NewDense(3, 3, []float64{1.0, 2.0, 3.0, 4.0, 5.0, 6.0, 7.0, 8.0, 9.0}),
+			matrix: mat.NewDense(3, 3, []float64{1.0, 2.0, 3.0, 4.0, 5.0, 6.0, 7.0, 8.0, 10.0}),
 			wantL:  mat.NewDense(3, 3, []float64{1.0, 0.0, 0.0, 4.0, 1.0, 0.0, 7.0, 2.0, 1.0}),
-			wantU:  mat.NewDense(3, 3, []float64{1.0, 2.0, 3.0, 0.0, -3.0, -6.0, 0.0, 0.0, 0.0}),
+			wantU:  mat.NewDense(3, 3, []float64{1.0, 2.0, 3.0, 0.0, -3.0, -6.0, 0.0, 0.0, 1.0}),
 		},
 	} {
 		zeroAware := &precondtest.DenseNonZeroDoer{Dense: test.matrix}
@@ -168,3 +169,204 @@ func TestRandomMatricesAgainstGonum(t *testing.T) {
 
 	}
 }
+
+// plainVector hides the concrete type of a vector, such that the solves can not use
+// the raw data of a *mat.VecDense
+type plainVector struct {
+	mat.Vector
+}
+
+func TestSolveVecToStridedVectors(t *testing.T) {
+	A := gridLaplacian(4)
+	n, _ := A.Dims()
+	rhs := mat.NewDense(n, 2, nil)
+	for i := 0; i < n; i++ {
+		rhs.Set(i, 1, float64(i))
+	}
+
+	for name, ilu := range map[string]ILUPreconditioner{"ILUZero": ILUZero(A), "ILUT": ILUT(A, 4, 1e-3)} {
+		for _, trans := range []bool{false, true} {
+			want := mat.NewVecDense(n, nil)
+			if err := ilu.SolveVecTo(want, trans, mat.VecDenseCopyOf(rhs.ColView(1))); err != nil {
+				t.Fatalf("%v", err)
+			}
+
+			// The columns of a matrix have a stride of two
+			for _, b := range []mat.Vector{rhs.ColView(1), plainVector{rhs.ColView(1)}} {
+				dst := mat.NewDense(n, 2, nil)
+				if err := ilu.SolveVecTo(dst.ColView(0).(*mat.VecDense), trans, b); err != nil {
+					t.Fatalf("%v", err)
+				}
+				if !mat.Equal(dst.ColView(0), want) {
+					t.Errorf("%s trans=%v: wanted %v got %v", name, trans, mat.Formatted(want.T()), mat.Formatted(dst.ColView(0).T()))
+				}
+			}
+		}
+	}
+}
+
+func TestSolveVecToDoesNotAllocate(t *testing.T) {
+	A := gridLaplacian(10)
+	n, _ := A.Dims()
+	ilu := ILUZero(A)
+	ichol := IChol(A)
+	ssor := SSOR(A, 1.5)
+	reordered := Reordered(A, rand.New(rand.NewSource(1)).Perm(n), NewIChol)
+	fsai := FSAI(A, 1)
+
+	rhs := mat.NewVecDense(n, nil)
+	for i := 0; i < n; i++ {
+		rhs.SetVec(i, float64(i%7))
+	}
+	dst := mat.NewVecDense(n, nil)
+
+	for name, s := range map[string]interface {
+		SolveVecTo(dst *mat.VecDense, trans bool, rhs mat.Vector) error
+	}{"ILUZero": &ilu, "IChol": &ichol, "SSOR": &ssor, "Reordered": &reordered, "FSAI": &fsai} {
+		for _, trans := range []bool{false, true} {
+			// The first run initializes the transposed factors
+			allocs := testing.AllocsPerRun(10, func() {
+				if err := s.SolveVecTo(dst, trans, rhs); err != nil {
+					t.Fatalf("%v", err)
+				}
+			})
+			if allocs != 0 {
+				t.Errorf("%s trans=%v: expected no allocations, got %v", name, trans, allocs)
+			}
+		}
+	}
+}
+
+func BenchmarkILUSolveVecTo(b *testing.B) {
+	A := gridLaplacian(benchmarkGridSize)
+	n, _ := A.Dims()
+	ilu := ILUZero(A)
+	rhs := mat.NewVecDense(n, nil)
+	for i := 0; i < n; i++ {
+		rhs.SetVec(i, 1.0)
+	}
+	dst := mat.NewVecDense(n, nil)
+
+	for _, trans := range []bool{false, true} {
+		b.Run(fmt.Sprintf("trans=%v", trans), func(b *testing.B) {
+			if err := ilu.SolveVecTo(dst, trans, rhs); err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				ilu.SolveVecTo(dst, trans, rhs)
+			}
+		})
+	}
+}
+
+func BenchmarkILUZero(b *testing.B) {
+	A := gridLaplacian(benchmarkGridSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ILUZero(A)
+	}
+}
+
+func TestCloneSolvesConcurrently(t *testing.T) {
+	type solver interface {
+		SolveVecTo(dst *mat.VecDense, trans bool, rhs mat.Vector) error
+	}
+
+	A := gridLaplacian(10)
+	n, _ := A.Dims()
+	ilu := ILUZero(A)
+	iluk := ILUK(A, 1)
+	ichol := IChol(A)
+	ssor := SSOR(A, 1.5)
+	reordered := Reordered(A, rand.New(rand.NewSource(1)).Perm(n), NewIChol)
+	mulVecToer := NewCSRMulVecToer(A)
+	cheb := Chebyshev(&mulVecToer, nil)
+	spai := SPAI(A)
+	fsai := FSAI(A, 1)
+
+	for name, clone := range map[string]func() solver{
+		"ILUZero": func() solver {
+			c := ilu.Clone()
+			return &c
+		},
+		"ILUK": func() solver {
+			c := iluk.Clone()
+			return &c
+		},
+		"IChol": func() solver {
+			c := ichol.Clone()
+			return &c
+		},
+		"Chebyshev": func() solver {
+			c := cheb.Clone()
+			return &c
+		},
+		"SPAI": func() solver {
+			c := spai.Clone()
+			return &c
+		},
+		"FSAI": func() solver {
+			c := fsai.Clone()
+			return &c
+		},
+		"SSOR": func() solver {
+			c := ssor.Clone()
+			return &c
+		},
+		"Reordered": func() solver {
+			c := reordered.Clone()
+			return &c
+		},
+	} {
+		const numWorkers = 4
+		clones := make([]solver, numWorkers)
+		for i := range clones {
+			clones[i] = clone()
+		}
+
+		// Solve with a different right hand side in each goroutine, and compare with
+		// a serial solve
+		errs := make([]error, numWorkers)
+		results := make([]*mat.VecDense, numWorkers)
+		var wg sync.WaitGroup
+		for w := range clones {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				rhs := mat.NewVecDense(n, nil)
+				for i := 0; i < n; i++ {
+					rhs.SetVec(i, float64((i+w)%7))
+				}
+				results[w] = mat.NewVecDense(n, nil)
+				for k := 0; k < 20; k++ {
+					if err := clones[w].SolveVecTo(results[w], k%2 == 1, rhs); err != nil {
+						errs[w] = err
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		serial := clone()
+		for w := range clones {
+			if errs[w] != nil {
+				t.Fatalf("%s: %v", name, errs[w])
+			}
+			rhs := mat.NewVecDense(n, nil)
+			for i := 0; i < n; i++ {
+				rhs.SetVec(i, float64((i+w)%7))
+			}
+			want := mat.NewVecDense(n, nil)
+			if err := serial.SolveVecTo(want, true, rhs); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if !mat.Equal(results[w], want) {
+				t.Errorf("%s worker %d: expected\n%v\ngot\n%v", name, w, mat.Formatted(want.T()), mat.Formatted(results[w].T()))
+			}
+		}
+	}
+}
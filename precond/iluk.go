@@ -0,0 +1,211 @@
+package precond
+
+import (
+	"slices"
+)
+
+// iluPattern holds the sparsity pattern of the L and U factors of an incomplete LU
+// factorization. The columns in each row are sorted. The unit diagonal of L is stored
+// explicitly as the last entry of each row in L, and the diagonal of U is the first
+// entry of each row in U
+type iluPattern struct {
+	lowerIndptr []int
+	lowerInd    []int
+	upperIndptr []int
+	upperInd    []int
+}
+
+// symbolicILUK calculates the sparsity pattern of the ILU(k) factorization. The level
+// of an entry that is non-zero in A is zero. A fill-in created when eliminating (i, j)
+// using row m gets the level lev(i, m) + lev(m, j) + 1. All entries with a level larger
+// than k are dropped. Columns in each of the rows must be sorted.
+func symbolicILUK(rows [][]int, k int) iluPattern {
+	n := len(rows)
+
+	pattern := iluPattern{
+		lowerIndptr: make([]int, 1, n+1),
+		upperIndptr: make([]int, 1, n+1),
+	}
+
+	// Levels of the entries in the pattern of U
+	var upperLevels []int
+
+	levels := make([]int, n)
+	inRow := make([]bool, n)
+	nonZero := make([]int, 0, n)
+	lowerIdx := make(intHeap, 0, n)
+
+	for i := 0; i < n; i++ {
+		for _, j := range rows[i] {
+			inRow[j] = true
+			levels[j] = 0
+			nonZero = append(nonZero, j)
+			if j < i {
+				lowerIdx = append(lowerIdx, j)
+			}
+		}
+		lowerIdx.init()
+
+		for len(lowerIdx) > 0 {
+			m := lowerIdx.pop()
+
+			// The diagonal is the first entry in row m of U
+			for idx := pattern.upperIndptr[m] + 1; idx < pattern.upperIndptr[m+1]; idx++ {
+				j := pattern.upperInd[idx]
+				level := levels[m] + upperLevels[idx] + 1
+				if level > k {
+					continue
+				}
+
+				if !inRow[j] {
+					inRow[j] = true
+					levels[j] = level
+					nonZero = append(nonZero, j)
+					if j < i {
+						lowerIdx.push(j)
+					}
+				} else if level < levels[j] {
+					levels[j] = level
+				}
+			}
+		}
+
+		pattern.upperInd = append(pattern.upperInd, i)
+		upperLevels = append(upperLevels, 0)
+
+		slices.Sort(nonZero)
+		for _, j := range nonZero {
+			if j < i {
+				pattern.lowerInd = append(pattern.lowerInd, j)
+			} else if j > i {
+				pattern.upperInd = append(pattern.upperInd, j)
+				upperLevels = append(upperLevels, levels[j])
+			}
+			inRow[j] = false
+		}
+		nonZero = nonZero[:0]
+
+		pattern.lowerInd = append(pattern.lowerInd, i)
+		pattern.lowerIndptr = append(pattern.lowerIndptr, len(pattern.lowerInd))
+		pattern.upperIndptr = append(pattern.upperIndptr, len(pattern.upperInd))
+	}
+	return pattern
+}
+
+// eliminateILU calculates the values of the L and U factors in place. On entry, lower
+// and upper must contain the entries of A scattered into the pattern of the factors.
+// The elimination is performed row by row (IKJ variant), where updates falling outside
+// of the pattern are ignored. ErrZeroPivot is returned if the absolute value of a diagonal
+// of U is smaller than pivotTolerance. pos is a work array of length n that must be filled with -1.
+// It is left in that state on return
+func eliminateILU(pattern iluPattern, lower, upper []float64, pos []int) error {
+	n := len(pattern.lowerIndptr) - 1
+	for i := 0; i < n; i++ {
+		lowerStart, lowerEnd := pattern.lowerIndptr[i], pattern.lowerIndptr[i+1]
+
+		pattern.setPositions(i, pos)
+
+		for idx := lowerStart; idx < lowerEnd-1; idx++ {
+			k := pattern.lowerInd[idx]
+			diagIdx := pattern.upperIndptr[k]
+			factor := lower[idx] / upper[diagIdx]
+			lower[idx] = factor
+
+			for kj := diagIdx + 1; kj < pattern.upperIndptr[k+1]; kj++ {
+				j := pattern.upperInd[kj]
+				if p := pos[j]; p >= 0 {
+					if j < i {
+						lower[p] -= factor * upper[kj]
+					} else {
+						upper[p] -= factor * upper[kj]
+					}
+				}
+			}
+		}
+		lower[lowerEnd-1] = 1.0
+		pattern.clearPositions(i, pos)
+
+		// The diagonal is checked for every row, since the last rows are not used as
+		// pivots in the elimination of other rows
+		if diag := upper[pattern.upperIndptr[i]]; isZeroPivot(diag) {
+			return ErrZeroPivot{Row: i, Value: diag}
+		}
+	}
+	return nil
+}
+
+// setPositions stores the position of each column of row i in pos. Columns smaller
+// than i refer to the values of L and the remaining columns to the values of U. The
+// unit diagonal of L is not included
+func (p *iluPattern) setPositions(i int, pos []int) {
+	for idx := p.lowerIndptr[i]; idx < p.lowerIndptr[i+1]-1; idx++ {
+		pos[p.lowerInd[idx]] = idx
+	}
+	for idx := p.upperIndptr[i]; idx < p.upperIndptr[i+1]; idx++ {
+		pos[p.upperInd[idx]] = idx
+	}
+}
+
+// clearPositions resets the entries of pos set by setPositions to -1
+func (p *iluPattern) clearPositions(i int, pos []int) {
+	for idx := p.lowerIndptr[i]; idx < p.lowerIndptr[i+1]-1; idx++ {
+		pos[p.lowerInd[idx]] = -1
+	}
+	for idx := p.upperIndptr[i]; idx < p.upperIndptr[i+1]; idx++ {
+		pos[p.upperInd[idx]] = -1
+	}
+}
+
+// checkRowDiag returns ErrZeroPivot if any of the rows has a zero diagonal. If
+// requirePositive is true, negative diagonals are also reported
+func checkRowDiag(rows []sparseRow, requirePositive bool) error {
+	for i, row := range rows {
+		idx, found := slices.BinarySearch(row.cols, i)
+		if !found {
+			return ErrZeroPivot{Row: i}
+		}
+
+		if diag := row.values[idx]; isZeroPivot(diag) || (requirePositive && diag < 0.0) {
+			return ErrZeroPivot{Row: i, Value: diag}
+		}
+	}
+	return nil
+}
+
+// ILUK calculates the incomplete LU decomposition with level of fill k, ILU(k).
+// In the symbolic phase each entry is assigned a level of fill. Non-zero entries
+// in A has level zero, and a fill-in gets a level that is one larger than the sum
+// of the levels of the entries that created it. All entries with level larger
+// than k are dropped. In the numeric phase, the values of L and U are calculated
+// on the resulting sparsity pattern.
+//
+// ILUK(A, 0) is the same as ILUZero(A). If k is at least the size of the matrix
+// ILUK is the complete LU decomposition without pivoting.
+// The method panics if A is not square, k is negative or the diagonal contains zeros.
+// Use NewILUK to get an error instead
+func ILUK(A ZeroAwareMatrix, k int) ILUPreconditioner {
+	lu, err := NewILUK(A, k)
+	if err != nil {
+		panic(err)
+	}
+	return lu
+}
+
+// NewILUK is the same as ILUK, except that it returns an error instead of panicking.
+// ErrNotSquare is returned if A is not square, and ErrZeroPivot if the diagonal
+// contains zeros or a zero pivot is encountered
+func NewILUK(A ZeroAwareMatrix, k int) (ILUPreconditioner, error) {
+	if err := checkSquare(A); err != nil {
+		return ILUPreconditioner{}, err
+	}
+	if err := checkFillLevel(k); err != nil {
+		return ILUPreconditioner{}, err
+	}
+
+	// The rows are collected once, and shared by the check and the symbolic analysis
+	rows := collectRows(A)
+	if err := checkRowDiag(rows, false); err != nil {
+		return ILUPreconditioner{}, err
+	}
+	return analyzeILUKRows(rows, k).Factorize(A)
+}
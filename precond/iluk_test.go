@@ -0,0 +1,162 @@
+package precond
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/davidkleiven/goprecond/precond/precondtest"
+	"github.com/davidkleiven/goprecond/precond/property"
+	"gonum.org/v1/gonum/mat"
+	"pgregory.net/rapid"
+)
+
+func TestSymbolicILUK(t *testing.T) {
+	// Arrow shaped pattern
+	// [x, x, x]
+	// [x, x, 0]
+	// [x, 0, x]
+	rows := [][]int{{0, 1, 2}, {0, 1}, {0, 2}}
+
+	for _, test := range []struct {
+		k         int
+		wantLower []int
+		wantUpper []int
+		desc      string
+	}{
+		{
+			k:         0,
+			wantLower: []int{0, 0, 1, 0, 2},
+			wantUpper: []int{0, 1, 2, 1, 2},
+			desc:      "No fill-in for level zero",
+		},
+		{
+			k:         1,
+			wantLower: []int{0, 0, 1, 0, 1, 2},
+			wantUpper: []int{0, 1, 2, 1, 2, 2},
+			desc:      "Entries (1, 2) and (2, 1) are filled for level one",
+		},
+	} {
+		pattern := symbolicILUK(rows, test.k)
+		if !slices.Equal(pattern.lowerInd, test.wantLower) {
+			t.Errorf("Test %s: wanted L pattern %v got %v\n", test.desc, test.wantLower, pattern.lowerInd)
+		}
+		if !slices.Equal(pattern.upperInd, test.wantUpper) {
+			t.Errorf("Test %s: wanted U pattern %v got %v\n", test.desc, test.wantUpper, pattern.upperInd)
+		}
+	}
+}
+
+func TestILUKZeroIsILUZeroPattern(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		matrix := mat.DenseCopyOf(property.SparseSymmetricMatrix(t, 2, 40))
+		lu := ILUK(&precondtest.DenseNonZeroDoer{Dense: matrix}, 0)
+
+		n, _ := matrix.Dims()
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				inPattern := matrix.At(i, j) != 0.0
+				if j < i && (lu.lower.At(i, j) != 0.0) && !inPattern {
+					t.Fatalf("L has a fill-in at (%d, %d)", i, j)
+				}
+				if j >= i && (lu.upper.At(i, j) != 0.0) && !inPattern {
+					t.Fatalf("U has a fill-in at (%d, %d)", i, j)
+				}
+			}
+		}
+	})
+}
+
+func TestILUKFillIncreasesWithLevel(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		matrix := property.SparseSymmetricMatrix(t, 2, 40)
+		zeroAware := &precondtest.DenseNonZeroDoer{Dense: mat.DenseCopyOf(matrix)}
+
+		prevNNZ := 0
+		for k := 0; k < 4; k++ {
+			lu := ILUK(zeroAware, k)
+			nnz := lu.lower.NNZ() + lu.upper.NNZ()
+			if nnz < prevNNZ {
+				t.Fatalf("Level %d has %d non-zeros while the previous level had %d", k, nnz, prevNNZ)
+			}
+			prevNNZ = nnz
+		}
+	})
+}
+
+func TestILUKFullLevelIsExact(t *testing.T) {
+	t.Parallel()
+
+	for _, trans := range []bool{true, false} {
+		for _, dim := range []int{5, 10, 15, 20} {
+			t.Run(fmt.Sprintf("trans %v dim %d", trans, dim), func(t *testing.T) {
+				tc := randomSymmetricTestCase(dim)
+
+				// Make the matrix sparse by removing entries far from the diagonal
+				for i := 0; i < dim; i++ {
+					for j := 0; j < dim; j++ {
+						if (i-j)%3 != 0 && i != j {
+							tc.matrix.Set(i, j, 0.0)
+						}
+					}
+				}
+
+				lu := ILUK(&precondtest.DenseNonZeroDoer{Dense: tc.matrix}, dim)
+
+				result := mat.NewVecDense(dim, nil)
+				if err := lu.SolveVecTo(result, trans, tc.rhs); err != nil {
+					t.Errorf("%v\n", err)
+					return
+				}
+
+				got := mat.NewVecDense(dim, nil)
+				if trans {
+					got.MulVec(tc.matrix.T(), result)
+				} else {
+					got.MulVec(tc.matrix, result)
+				}
+
+				if !mat.EqualApprox(got, tc.rhs, 1e-6) {
+					t.Errorf("Wanted\n%v\ngot\n%v\n", mat.Formatted(tc.rhs), mat.Formatted(got))
+				}
+			})
+		}
+	}
+}
+
+func TestILUKUnsymmetricPattern(t *testing.T) {
+	// Entry (2, 0) is non-zero, but (0, 2) is zero. Row 2 must still be
+	// updated by row 0
+	matrix := mat.NewDense(3, 3, []float64{
+		2.0, 1.0, 0.0,
+		0.0, 3.0, 1.0,
+		1.0, 0.0, 4.0,
+	})
+	wantL := mat.NewDense(3, 3, []float64{
+		1.0, 0.0, 0.0,
+		0.0, 1.0, 0.0,
+		0.5, 0.0, 1.0,
+	})
+	wantU := mat.NewDense(3, 3, []float64{
+		2.0, 1.0, 0.0,
+		0.0, 3.0, 1.0,
+		0.0, 0.0, 4.0,
+	})
+
+	lu := ILUK(&precondtest.DenseNonZeroDoer{Dense: matrix}, 0)
+	if !equal(lu.lower, wantL, 1e-10) {
+		t.Errorf("Wanted L\n%v\ngot\n%v\n", mat.Formatted(wantL), mat.Formatted(lu.lower))
+	}
+	if !equal(lu.upper, wantU, 1e-10) {
+		t.Errorf("Wanted U\n%v\ngot\n%v\n", mat.Formatted(wantU), mat.Formatted(lu.upper))
+	}
+}
+
+func BenchmarkILUK(b *testing.B) {
+	A := gridLaplacian(benchmarkGridSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ILUK(A, 2)
+	}
+}
@@ -0,0 +1,215 @@
+package precond
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// intHeap is a min-heap of column indices. It is used to visit the lower part of
+// the work row in increasing column order while fill-ins are being added. The
+// methods are implemented on the slice, since container/heap boxes every index
+type intHeap []int
+
+// init establishes the heap ordering of the indices
+func (h intHeap) init() {
+	for i := len(h)/2 - 1; i >= 0; i-- {
+		h.down(i)
+	}
+}
+
+func (h *intHeap) push(j int) {
+	*h = append(*h, j)
+	h.up(len(*h) - 1)
+}
+
+// pop removes and returns the smallest index
+func (h *intHeap) pop() int {
+	old := *h
+	n := len(old) - 1
+	old[0], old[n] = old[n], old[0]
+	*h = old[:n]
+	h.down(0)
+	return old[n]
+}
+
+func (h intHeap) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if h[parent] <= h[i] {
+			return
+		}
+		h[parent], h[i] = h[i], h[parent]
+		i = parent
+	}
+}
+
+func (h intHeap) down(i int) {
+	for {
+		child := 2*i + 1
+		if child >= len(h) {
+			return
+		}
+		if right := child + 1; right < len(h) && h[right] < h[child] {
+			child = right
+		}
+		if h[i] <= h[child] {
+			return
+		}
+		h[i], h[child] = h[child], h[i]
+		i = child
+	}
+}
+
+// largestEntries keeps the p entries with largest magnitude in the row. The
+// returned row is sorted by column
+func largestEntries(row sparseRow, p int) sparseRow {
+	if len(row.cols) > p {
+		order := make([]int, len(row.cols))
+		for i := range order {
+			order[i] = i
+		}
+		slices.SortFunc(order, func(a, b int) int {
+			absA, absB := math.Abs(row.values[a]), math.Abs(row.values[b])
+			if absA > absB {
+				return -1
+			} else if absA < absB {
+				return 1
+			}
+			return row.cols[a] - row.cols[b]
+		})
+
+		kept := sparseRow{cols: make([]int, p), values: make([]float64, p)}
+		for i, idx := range order[:p] {
+			kept.cols[i] = row.cols[idx]
+			kept.values[i] = row.values[idx]
+		}
+		row = kept
+	}
+
+	return sortRow(row)
+}
+
+// ILUT calculates the threshold based incomplete LU decomposition ILUT(p, tau) of A.
+// While row i is eliminated, all entries that are smaller (in absolute value) than
+// tau times the 2-norm of row i of A are dropped. Thereafter, only the p largest
+// off-diagonal entries are kept in row i of L and in row i of U. The diagonal is
+// always kept.
+//
+// With p equal to the size of the matrix and tau equal to zero, ILUT is the same as the
+// complete LU decomposition without pivoting
+// The method panics if A is not square, p is negative or a zero pivot is encountered.
+// Use NewILUT to get an error instead
+func ILUT(A ZeroAwareMatrix, p int, tau float64) ILUPreconditioner {
+	lu, err := NewILUT(A, p, tau)
+	if err != nil {
+		panic(err)
+	}
+	return lu
+}
+
+// NewILUT is the same as ILUT, except that it returns an error instead of panicking.
+// ErrNotSquare is returned if A is not square, ErrInvalidArgument if p is negative and
+// ErrZeroPivot if a pivot is smaller than 1e-8 in absolute value
+func NewILUT(A ZeroAwareMatrix, p int, tau float64) (ILUPreconditioner, error) {
+	if err := checkSquare(A); err != nil {
+		return ILUPreconditioner{}, err
+	}
+
+	if p < 0 {
+		return ILUPreconditioner{}, fmt.Errorf("%w: the number of entries to keep per row must be non-negative, got %d", ErrInvalidArgument, p)
+	}
+	nrows, _ := A.Dims()
+
+	rows := collectRows(A)
+
+	// Rows of U stored with the diagonal first
+	upperRows := make([]sparseRow, nrows)
+	lowerRows := make([]sparseRow, nrows)
+
+	work := make([]float64, nrows)
+	inRow := make([]bool, nrows)
+	nonZero := make([]int, 0, nrows)
+	lowerIdx := make(intHeap, 0, nrows)
+
+	for i := 0; i < nrows; i++ {
+		norm := 0.0
+		for k, j := range rows[i].cols {
+			v := rows[i].values[k]
+			norm += v * v
+			if !inRow[j] {
+				inRow[j] = true
+				nonZero = append(nonZero, j)
+				if j < i {
+					lowerIdx = append(lowerIdx, j)
+				}
+			}
+			work[j] += v
+		}
+		tol := tau * math.Sqrt(norm)
+		lowerIdx.init()
+
+		for len(lowerIdx) > 0 {
+			k := lowerIdx.pop()
+			work[k] /= upperRows[k].values[0]
+
+			if math.Abs(work[k]) < tol {
+				work[k] = 0.0
+				continue
+			}
+
+			factor := work[k]
+			for idx, j := range upperRows[k].cols[1:] {
+				if !inRow[j] {
+					inRow[j] = true
+					nonZero = append(nonZero, j)
+					if j < i {
+						lowerIdx.push(j)
+					}
+				}
+				work[j] -= factor * upperRows[k].values[idx+1]
+			}
+		}
+
+		diag := work[i]
+		if isZeroPivot(diag) {
+			return ILUPreconditioner{}, ErrZeroPivot{Row: i, Value: diag}
+		}
+
+		lower := sparseRow{}
+		upper := sparseRow{}
+		for _, j := range nonZero {
+			v := work[j]
+			work[j] = 0.0
+			inRow[j] = false
+
+			if j == i || v == 0.0 || math.Abs(v) < tol {
+				continue
+			}
+
+			if j < i {
+				lower.cols = append(lower.cols, j)
+				lower.values = append(lower.values, v)
+			} else {
+				upper.cols = append(upper.cols, j)
+				upper.values = append(upper.values, v)
+			}
+		}
+		nonZero = nonZero[:0]
+
+		lowerRows[i] = largestEntries(lower, p)
+		upper = largestEntries(upper, p)
+		upperRows[i] = sparseRow{
+			cols:   append([]int{i}, upper.cols...),
+			values: append([]float64{diag}, upper.values...),
+		}
+	}
+
+	// The unit diagonal of L is stored explicitly
+	for i := range lowerRows {
+		lowerRows[i].cols = append(lowerRows[i].cols, i)
+		lowerRows[i].values = append(lowerRows[i].values, 1.0)
+	}
+
+	return newILUPreconditioner(rowsToCSR(lowerRows), rowsToCSR(upperRows)), nil
+}
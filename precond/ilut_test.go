@@ -0,0 +1,150 @@
+package precond
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/davidkleiven/goprecond/precond/precondtest"
+	"github.com/davidkleiven/goprecond/precond/property"
+	"gonum.org/v1/gonum/mat"
+	"pgregory.net/rapid"
+)
+
+func TestILUTKnownDecomposition(t *testing.T) {
+	matrix := mat.NewDense(3, 3, []float64{1.0, 2.0, 3.0, 4.0, 5.0, 6.0, 7.0, 8.0, 10.0})
+	wantL := mat.NewDense(3, 3, []float64{1.0, 0.0, 0.0, 4.0, 1.0, 0.0, 7.0, 2.0, 1.0})
+	wantU := mat.NewDense(3, 3, []float64{1.0, 2.0, 3.0, 0.0, -3.0, -6.0, 0.0, 0.0, 1.0})
+
+	lu := ILUT(&precondtest.DenseNonZeroDoer{Dense: matrix}, 3, 0.0)
+
+	if !equal(lu.lower, wantL, 1e-10) {
+		t.Errorf("Wanted L\n%v\ngot\n%v\n", mat.Formatted(wantL), mat.Formatted(lu.lower))
+	}
+
+	if !equal(lu.upper, wantU, 1e-10) {
+		t.Errorf("Wanted U\n%v\ngot\n%v\n", mat.Formatted(wantU), mat.Formatted(lu.upper))
+	}
+}
+
+func TestILUTWithoutDroppingIsExact(t *testing.T) {
+	t.Parallel()
+
+	for _, trans := range []bool{true, false} {
+		for _, dim := range []int{5, 10, 15, 20} {
+			t.Run(fmt.Sprintf("trans %v dim %d", trans, dim), func(t *testing.T) {
+				tc := randomTestCase(dim)
+				lu := ILUT(&precondtest.DenseNonZeroDoer{Dense: tc.matrix}, dim, 0.0)
+
+				result := mat.NewVecDense(dim, nil)
+				if err := lu.SolveVecTo(result, trans, tc.rhs); err != nil {
+					t.Errorf("%v\n", err)
+					return
+				}
+
+				got := mat.NewVecDense(dim, nil)
+				if trans {
+					got.MulVec(tc.matrix.T(), result)
+				} else {
+					got.MulVec(tc.matrix, result)
+				}
+
+				if !mat.EqualApprox(got, tc.rhs, 1e-6) {
+					t.Errorf("Wanted\n%v\ngot\n%v\n", mat.Formatted(tc.rhs), mat.Formatted(got))
+				}
+			})
+		}
+	}
+}
+
+func TestILUTKeepsAtMostPEntries(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		matrix := property.SparseSymmetricMatrix(t, 2, 40)
+		p := rapid.IntRange(0, 5).Draw(t, "p")
+		tau := rapid.Float64Range(0.0, 0.1).Draw(t, "tau")
+
+		lu := ILUT(&precondtest.DenseNonZeroDoer{Dense: mat.DenseCopyOf(matrix)}, p, tau)
+
+		n, _ := lu.lower.Dims()
+		for i := 0; i < n; i++ {
+			// The diagonal is always stored in addition to the p off-diagonal entries
+			if nnz := lu.lower.RowNNZ(i); nnz > p+1 {
+				t.Fatalf("Row %d of L has %d entries. Wanted at most %d", i, nnz, p+1)
+			}
+			if nnz := lu.upper.RowNNZ(i); nnz > p+1 {
+				t.Fatalf("Row %d of U has %d entries. Wanted at most %d", i, nnz, p+1)
+			}
+			if lu.lower.At(i, i) != 1.0 {
+				t.Fatalf("L should have a unit diagonal. Got %f", lu.lower.At(i, i))
+			}
+		}
+	})
+}
+
+func TestILUTDropTolerance(t *testing.T) {
+	matrix := mat.NewDense(3, 3, []float64{
+		4.0, 1.0, 0.0,
+		1.0, 4.0, 1.0,
+		0.0, 1.0, 4.0,
+	})
+
+	for _, test := range []struct {
+		tau      float64
+		wantNNZL int
+		desc     string
+	}{
+		{
+			tau:      0.0,
+			wantNNZL: 5,
+			desc:     "No entries are dropped",
+		},
+		{
+			tau:      0.5,
+			wantNNZL: 3,
+			desc:     "All off-diagonal entries of L are dropped",
+		},
+	} {
+		lu := ILUT(&precondtest.DenseNonZeroDoer{Dense: matrix}, 3, test.tau)
+		if nnz := lu.lower.NNZ(); nnz != test.wantNNZL {
+			t.Errorf("Test %s: wanted %d non-zeros in L got %d\n", test.desc, test.wantNNZL, nnz)
+		}
+	}
+}
+
+func TestILUTExactForTridiagonal(t *testing.T) {
+	// A tridiagonal matrix has no fill-in, so ILUT with p >= 1 is the exact LU
+	n := 50
+	matrix := mat.NewDense(n, n, nil)
+	for i := 0; i < n; i++ {
+		matrix.Set(i, i, 2.0)
+		if i > 0 {
+			matrix.Set(i, i-1, -1.0)
+		}
+		if i < n-1 {
+			matrix.Set(i, i+1, -1.5)
+		}
+	}
+
+	lu := ILUT(&precondtest.DenseNonZeroDoer{Dense: matrix}, 1, 1e-12)
+	rhs := mat.NewVecDense(n, nil)
+	for i := 0; i < n; i++ {
+		rhs.SetVec(i, 1.0)
+	}
+
+	result := mat.NewVecDense(n, nil)
+	lu.SolveVecTo(result, false, rhs)
+
+	got := mat.NewVecDense(n, nil)
+	got.MulVec(matrix, result)
+	if !mat.EqualApprox(got, rhs, 1e-8) {
+		t.Errorf("Tridiagonal system should be solved exactly. Wanted\n%v\ngot\n%v\n", mat.Formatted(rhs), mat.Formatted(got))
+	}
+}
+
+func BenchmarkILUT(b *testing.B) {
+	A := gridLaplacian(benchmarkGridSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ILUT(A, 10, 1e-4)
+	}
+}
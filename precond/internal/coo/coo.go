@@ -0,0 +1,114 @@
+// Package coo holds the coordinate (triplet) storage used while reading matrix files,
+// and converts it to compressed storage. It is shared by the file format packages
+package coo
+
+import "github.com/james-bowman/sparse"
+
+// MaxCapacityHint is the largest number of values that is allocated up front based on
+// the header of a file. Larger slices grow as the values are read
+const MaxCapacityHint = 1 << 20
+
+// Triplets holds the entries of a matrix as (row, column, value) triplets
+type Triplets struct {
+	Rows int
+	Cols int
+	I    []int
+	J    []int
+	Data []float64
+}
+
+// New creates an empty rows x cols matrix with room for the given number of entries.
+// The capacity is limited by MaxCapacityHint
+func New(rows, cols, capacity int) Triplets {
+	capacity = min(capacity, MaxCapacityHint)
+	return Triplets{
+		Rows: rows,
+		Cols: cols,
+		I:    make([]int, 0, capacity),
+		J:    make([]int, 0, capacity),
+		Data: make([]float64, 0, capacity),
+	}
+}
+
+// Append adds the entry (i, j) with value v
+func (t *Triplets) Append(i, j int, v float64) {
+	t.I = append(t.I, i)
+	t.J = append(t.J, j)
+	t.Data = append(t.Data, v)
+}
+
+// COO returns the entries as a COO matrix that shares storage with t
+func (t *Triplets) COO() *sparse.COO {
+	return sparse.NewCOO(t.Rows, t.Cols, t.I, t.J, t.Data)
+}
+
+// CSR returns the entries as a CSR matrix with sorted column indices, where duplicated
+// entries are summed
+func (t *Triplets) CSR() *sparse.CSR {
+	indptr, ind, data := Compress(t.I, t.J, t.Data, t.Rows, t.Cols)
+	return sparse.NewCSR(t.Rows, t.Cols, indptr, ind, data)
+}
+
+// CSC returns the entries as a CSC matrix with sorted row indices, where duplicated
+// entries are summed
+func (t *Triplets) CSC() *sparse.CSC {
+	indptr, ind, data := Compress(t.J, t.I, t.Data, t.Cols, t.Rows)
+	return sparse.NewCSC(t.Rows, t.Cols, indptr, ind, data)
+}
+
+// Compress returns the compressed storage of the entries, where major is the row for CSR
+// and the column for CSC. The minor indices of each major index are sorted and duplicates
+// are summed
+func Compress(major, minor []int, values []float64, numMajor, numMinor int) ([]int, []int, []float64) {
+	// Bucket the entries by the minor index, and then by the major index, such that the
+	// minor indices are sorted
+	minorPtr := make([]int, numMinor+1)
+	for _, m := range minor {
+		minorPtr[m+1]++
+	}
+	for m := 0; m < numMinor; m++ {
+		minorPtr[m+1] += minorPtr[m]
+	}
+	byMinor := make([]int, len(minor))
+	next := make([]int, numMinor)
+	copy(next, minorPtr[:numMinor])
+	for p, m := range minor {
+		byMinor[next[m]] = p
+		next[m]++
+	}
+
+	indptr := make([]int, numMajor+1)
+	for _, m := range major {
+		indptr[m+1]++
+	}
+	for m := 0; m < numMajor; m++ {
+		indptr[m+1] += indptr[m]
+	}
+	ind := make([]int, len(major))
+	data := make([]float64, len(major))
+	next = make([]int, numMajor)
+	copy(next, indptr[:numMajor])
+	for _, p := range byMinor {
+		m := major[p]
+		ind[next[m]] = minor[p]
+		data[next[m]] = values[p]
+		next[m]++
+	}
+
+	// Sum duplicates, which are adjacent after sorting
+	nnz := 0
+	for m := 0; m < numMajor; m++ {
+		start := nnz
+		for p := indptr[m]; p < indptr[m+1]; p++ {
+			if nnz > start && ind[nnz-1] == ind[p] {
+				data[nnz-1] += data[p]
+				continue
+			}
+			ind[nnz], data[nnz] = ind[p], data[p]
+			nnz++
+		}
+		indptr[m] = start
+	}
+	indptr[numMajor] = nnz
+	return indptr, ind[:nnz], data[:nnz]
+}
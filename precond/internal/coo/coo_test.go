@@ -0,0 +1,53 @@
+package coo
+
+import (
+	"slices"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestCompressSortsAndSumsDuplicates(t *testing.T) {
+	major := []int{1, 0, 1, 0, 1}
+	minor := []int{2, 1, 0, 1, 2}
+	values := []float64{1.0, 2.0, 3.0, 4.0, 5.0}
+
+	indptr, ind, data := Compress(major, minor, values, 3, 3)
+	wantIndptr := []int{0, 1, 3, 3}
+	wantInd := []int{1, 0, 2}
+	wantData := []float64{6.0, 3.0, 6.0}
+	if !slices.Equal(indptr, wantIndptr) {
+		t.Errorf("Wanted indptr %v got %v", wantIndptr, indptr)
+	}
+	if !slices.Equal(ind, wantInd) {
+		t.Errorf("Wanted ind %v got %v", wantInd, ind)
+	}
+	if !slices.Equal(data, wantData) {
+		t.Errorf("Wanted data %v got %v", wantData, data)
+	}
+}
+
+func TestTripletsToCompressed(t *testing.T) {
+	tr := New(2, 3, 4)
+	tr.Append(1, 2, 1.0)
+	tr.Append(0, 1, 2.0)
+	tr.Append(1, 0, 3.0)
+	tr.Append(1, 2, 4.0)
+
+	want := mat.NewDense(2, 3, []float64{
+		0.0, 2.0, 0.0,
+		3.0, 0.0, 5.0,
+	})
+	for name, m := range map[string]mat.Matrix{"COO": tr.COO(), "CSR": tr.CSR(), "CSC": tr.CSC()} {
+		if !mat.Equal(m, want) {
+			t.Errorf("%s: Wanted\n%v\ngot\n%v", name, mat.Formatted(want), mat.Formatted(m))
+		}
+	}
+}
+
+func TestNewLimitsCapacity(t *testing.T) {
+	tr := New(1, 1, 2*MaxCapacityHint)
+	if c := cap(tr.Data); c != MaxCapacityHint {
+		t.Errorf("Wanted capacity %d got %d", MaxCapacityHint, c)
+	}
+}
@@ -0,0 +1,48 @@
+// Package parallel runs independent tasks on a bounded number of goroutines. It is
+// shared by the preconditioners that set up or apply independent local problems
+package parallel
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// For calls fn(worker, i) for i = 0, ..., n-1 using the given number of goroutines.
+// The worker index is in [0, workers) and can be used to access
+// per-worker scratch memory. The first error returned by fn is returned
+func For(n, workers int, fn func(worker, i int) error) error {
+	var (
+		next     atomic.Int64
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		failed   atomic.Bool
+	)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for !failed.Load() {
+				i := int(next.Add(1) - 1)
+				if i >= n {
+					return
+				}
+
+				if err := fn(worker, i); err != nil {
+					errOnce.Do(func() { firstErr = err })
+					failed.Store(true)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// Workers returns the number of goroutines used to process n independent tasks
+func Workers(n int) int {
+	return max(1, min(n, runtime.GOMAXPROCS(0)))
+}
@@ -0,0 +1,43 @@
+package parallel
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+func TestForVisitsAllIndices(t *testing.T) {
+	for _, n := range []int{0, 1, 7, 100} {
+		workers := Workers(n)
+		visits := make([]atomic.Int32, n)
+		err := For(n, workers, func(worker, i int) error {
+			if worker < 0 || worker >= workers {
+				t.Errorf("Worker %d is out of range [0, %d)", worker, workers)
+			}
+			visits[i].Add(1)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		for i := range visits {
+			if v := visits[i].Load(); v != 1 {
+				t.Errorf("n=%d: index %d was visited %d times", n, i, v)
+			}
+		}
+	}
+}
+
+func TestForReturnsError(t *testing.T) {
+	want := errors.New("task failed")
+	err := For(100, 4, func(worker, i int) error {
+		if i == 10 {
+			return want
+		}
+		return nil
+	})
+	if !errors.Is(err, want) {
+		t.Errorf("Wanted %v got %v", want, err)
+	}
+}
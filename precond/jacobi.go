@@ -0,0 +1,217 @@
+package precond
+
+import (
+	"fmt"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// JacobiPreconditioner approximates A by its diagonal
+type JacobiPreconditioner struct {
+	invDiag []float64
+}
+
+// Jacobi creates a preconditioner that approximates A by its diagonal
+// The method panics if A is not square or the diagonal contains zeros.
+// Use NewJacobi to get an error instead
+func Jacobi(A ZeroAwareMatrix) JacobiPreconditioner {
+	jacobi, err := NewJacobi(A)
+	if err != nil {
+		panic(err)
+	}
+	return jacobi
+}
+
+// NewJacobi is the same as Jacobi, except that it returns an error instead of panicking.
+// ErrNotSquare is returned if A is not square and ErrZeroPivot if a diagonal entry is
+// smaller than 1e-8 in absolute value
+func NewJacobi(A ZeroAwareMatrix) (JacobiPreconditioner, error) {
+	if err := checkSquare(A); err != nil {
+		return JacobiPreconditioner{}, err
+	}
+
+	n, _ := A.Dims()
+	diag := make([]float64, n)
+	A.DoNonZero(func(i, j int, v float64) {
+		if i == j {
+			diag[i] += v
+		}
+	})
+
+	for i, v := range diag {
+		if isZeroPivot(v) {
+			return JacobiPreconditioner{}, ErrZeroPivot{Row: i, Value: v}
+		}
+		diag[i] = 1.0 / v
+	}
+	return JacobiPreconditioner{invDiag: diag}, nil
+}
+
+// SolveVecTo solves the linear system of equation given by
+// Dx = b where D is the diagonal of A
+func (j *JacobiPreconditioner) SolveVecTo(dst *mat.VecDense, trans bool, rhs mat.Vector) error {
+	n := len(j.invDiag)
+	if err := checkVecDims(n, dst, rhs); err != nil {
+		return err
+	}
+
+	for i, v := range j.invDiag {
+		dst.SetVec(i, v*rhs.AtVec(i))
+	}
+	return nil
+}
+
+// BlockJacobiPreconditioner approximates A by a block diagonal matrix. Each block
+// is factorized with a dense LU decomposition
+type BlockJacobiPreconditioner struct {
+	n      int
+	blocks [][]int
+	lu     []mat.LU
+
+	// Work vectors for each block
+	blockRhs []*mat.VecDense
+	blockSol []*mat.VecDense
+}
+
+// BlockJacobi creates a block Jacobi preconditioner where blocks[k] lists the rows
+// (and columns) of A that belong to block k. Each row must be part of exactly one block.
+// The method panics if A is not square, the blocks are not a partition of the rows or
+// if any of the blocks are singular. Use NewBlockJacobi to get an error instead
+func BlockJacobi(A ZeroAwareMatrix, blocks [][]int) BlockJacobiPreconditioner {
+	bj, err := NewBlockJacobi(A, blocks)
+	if err != nil {
+		panic(err)
+	}
+	return bj
+}
+
+// FixedSizeBlockJacobi creates a block Jacobi preconditioner with contiguous blocks of
+// the given size. The last block is smaller if the size of A is not a multiple of size.
+// The method panics if A is not square, size is not positive or if any of the blocks are
+// singular. Use NewFixedSizeBlockJacobi to get an error instead
+func FixedSizeBlockJacobi(A ZeroAwareMatrix, size int) BlockJacobiPreconditioner {
+	bj, err := NewFixedSizeBlockJacobi(A, size)
+	if err != nil {
+		panic(err)
+	}
+	return bj
+}
+
+// NewFixedSizeBlockJacobi is the same as FixedSizeBlockJacobi, except that it returns an
+// error instead of panicking
+func NewFixedSizeBlockJacobi(A ZeroAwareMatrix, size int) (BlockJacobiPreconditioner, error) {
+	if size <= 0 {
+		return BlockJacobiPreconditioner{}, fmt.Errorf("%w: block size must be positive, got %d", ErrInvalidArgument, size)
+	}
+
+	n, _ := A.Dims()
+	blocks := make([][]int, 0, (n+size-1)/size)
+	for start := 0; start < n; start += size {
+		block := make([]int, 0, size)
+		for i := start; i < min(start+size, n); i++ {
+			block = append(block, i)
+		}
+		blocks = append(blocks, block)
+	}
+	return NewBlockJacobi(A, blocks)
+}
+
+// NewBlockJacobi is the same as BlockJacobi, except that it returns an error instead
+// of panicking. ErrNotSquare is returned if A is not square, ErrInvalidArgument if the
+// blocks are not a partition of the rows and ErrSingularBlock if the condition number of
+// a block exceeds mat.ConditionTolerance
+func NewBlockJacobi(A ZeroAwareMatrix, blocks [][]int) (BlockJacobiPreconditioner, error) {
+	if err := checkSquare(A); err != nil {
+		return BlockJacobiPreconditioner{}, err
+	}
+
+	n, _ := A.Dims()
+	blockOf := make([]int, n)
+	local := make([]int, n)
+	for i := range blockOf {
+		blockOf[i] = -1
+	}
+
+	for b, block := range blocks {
+		for k, i := range block {
+			if i < 0 || i >= n {
+				return BlockJacobiPreconditioner{}, fmt.Errorf("%w: row %d in block %d is out of range", ErrInvalidArgument, i, b)
+			}
+
+			if blockOf[i] != -1 {
+				return BlockJacobiPreconditioner{}, fmt.Errorf("%w: row %d is part of block %d and %d", ErrInvalidArgument, i, blockOf[i], b)
+			}
+			blockOf[i] = b
+			local[i] = k
+		}
+	}
+
+	for i, b := range blockOf {
+		if b == -1 {
+			return BlockJacobiPreconditioner{}, fmt.Errorf("%w: row %d is not part of any block", ErrInvalidArgument, i)
+		}
+	}
+
+	dense := make([]*mat.Dense, len(blocks))
+	for b, block := range blocks {
+		if len(block) > 0 {
+			dense[b] = mat.NewDense(len(block), len(block), nil)
+		}
+	}
+
+	A.DoNonZero(func(i, j int, v float64) {
+		if b := blockOf[i]; b == blockOf[j] {
+			dense[b].Set(local[i], local[j], dense[b].At(local[i], local[j])+v)
+		}
+	})
+
+	bj := BlockJacobiPreconditioner{
+		n:        n,
+		blocks:   blocks,
+		lu:       make([]mat.LU, len(blocks)),
+		blockRhs: make([]*mat.VecDense, len(blocks)),
+		blockSol: make([]*mat.VecDense, len(blocks)),
+	}
+
+	for b, block := range blocks {
+		if len(block) == 0 {
+			continue
+		}
+
+		bj.lu[b].Factorize(dense[b])
+		if bj.lu[b].Cond() > mat.ConditionTolerance {
+			return BlockJacobiPreconditioner{}, ErrSingularBlock{Block: b}
+		}
+
+		bj.blockRhs[b] = mat.NewVecDense(len(block), nil)
+		bj.blockSol[b] = mat.NewVecDense(len(block), nil)
+	}
+	return bj, nil
+}
+
+// SolveVecTo solves the linear system of equation given by
+// Mx = b where M is the block diagonal part of A
+func (bj *BlockJacobiPreconditioner) SolveVecTo(dst *mat.VecDense, trans bool, rhs mat.Vector) error {
+	if err := checkVecDims(bj.n, dst, rhs); err != nil {
+		return err
+	}
+
+	for b, block := range bj.blocks {
+		if len(block) == 0 {
+			continue
+		}
+
+		for k, i := range block {
+			bj.blockRhs[b].SetVec(k, rhs.AtVec(i))
+		}
+
+		if err := bj.lu[b].SolveVecTo(bj.blockSol[b], trans, bj.blockRhs[b]); err != nil {
+			return err
+		}
+
+		for k, i := range block {
+			dst.SetVec(i, bj.blockSol[b].AtVec(k))
+		}
+	}
+	return nil
+}
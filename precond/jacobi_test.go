@@ -0,0 +1,168 @@
+package precond
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/davidkleiven/goprecond/precond/precondtest"
+	"gonum.org/v1/exp/linsolve"
+	"gonum.org/v1/gonum/mat"
+)
+
+// blockDiagonalTestCase returns a random matrix where all entries outside the
+// diagonal blocks of the given size are zero
+func blockDiagonalTestCase(dim, size int) testCase {
+	tc := randomTestCase(dim)
+	for i := 0; i < dim; i++ {
+		for j := 0; j < dim; j++ {
+			if i/size != j/size {
+				tc.matrix.Set(i, j, 0.0)
+			}
+		}
+	}
+	return tc
+}
+
+func TestJacobi(t *testing.T) {
+	matrix := mat.NewDense(3, 3, []float64{
+		2.0, 1.0, 0.0,
+		1.0, 4.0, 1.0,
+		0.0, 1.0, -5.0,
+	})
+	jacobi := Jacobi(&precondtest.DenseNonZeroDoer{Dense: matrix})
+
+	rhs := mat.NewVecDense(3, []float64{1.0, 2.0, 3.0})
+	want := mat.NewVecDense(3, []float64{0.5, 0.5, -0.6})
+	for _, trans := range []bool{true, false} {
+		result := mat.NewVecDense(3, nil)
+		if err := jacobi.SolveVecTo(result, trans, rhs); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+
+		if !mat.EqualApprox(result, want, 1e-12) {
+			t.Errorf("Wanted\n%v\ngot\n%v\n", mat.Formatted(want), mat.Formatted(result))
+		}
+	}
+}
+
+func TestBlockJacobiExactForBlockDiagonal(t *testing.T) {
+	for _, trans := range []bool{true, false} {
+		for _, size := range []int{1, 2, 3, 5} {
+			t.Run(fmt.Sprintf("trans %v size %d", trans, size), func(t *testing.T) {
+				dim := 11
+				tc := blockDiagonalTestCase(dim, size)
+				bj := FixedSizeBlockJacobi(&precondtest.DenseNonZeroDoer{Dense: tc.matrix}, size)
+
+				result := mat.NewVecDense(dim, nil)
+				if err := bj.SolveVecTo(result, trans, tc.rhs); err != nil {
+					t.Errorf("%v", err)
+					return
+				}
+
+				got := mat.NewVecDense(dim, nil)
+				if trans {
+					got.MulVec(tc.matrix.T(), result)
+				} else {
+					got.MulVec(tc.matrix, result)
+				}
+
+				if !mat.EqualApprox(got, tc.rhs, 1e-8) {
+					t.Errorf("Wanted\n%v\ngot\n%v\n", mat.Formatted(tc.rhs), mat.Formatted(got))
+				}
+			})
+		}
+	}
+}
+
+func TestBlockJacobiUserBlocks(t *testing.T) {
+	// Rows 0 and 2 are coupled, row 1 is decoupled
+	matrix := mat.NewDense(3, 3, []float64{
+		2.0, 0.0, 1.0,
+		0.0, 4.0, 0.0,
+		1.0, 0.0, 3.0,
+	})
+	bj := BlockJacobi(&precondtest.DenseNonZeroDoer{Dense: matrix}, [][]int{{2, 0}, {1}})
+
+	rhs := mat.NewVecDense(3, []float64{3.0, 4.0, 4.0})
+	want := mat.NewVecDense(3, []float64{1.0, 1.0, 1.0})
+	result := mat.NewVecDense(3, nil)
+	if err := bj.SolveVecTo(result, false, rhs); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if !mat.EqualApprox(result, want, 1e-12) {
+		t.Errorf("Wanted\n%v\ngot\n%v\n", mat.Formatted(want), mat.Formatted(result))
+	}
+}
+
+func TestBlockJacobiErrors(t *testing.T) {
+	matrix := &precondtest.DenseNonZeroDoer{Dense: mat.NewDense(3, 3, []float64{
+		1.0, 1.0, 0.0,
+		1.0, 1.0, 0.0,
+		0.0, 0.0, 1.0,
+	})}
+
+	for _, test := range []struct {
+		blocks  [][]int
+		wantErr error
+		desc    string
+	}{
+		{
+			blocks:  [][]int{{0, 1}},
+			wantErr: ErrInvalidArgument,
+			desc:    "Row 2 is missing",
+		},
+		{
+			blocks:  [][]int{{0, 1}, {1, 2}},
+			wantErr: ErrInvalidArgument,
+			desc:    "Row 1 is part of two blocks",
+		},
+		{
+			blocks:  [][]int{{0, 1, 2}, {3}},
+			wantErr: ErrInvalidArgument,
+			desc:    "Row out of range",
+		},
+		{
+			blocks:  [][]int{{2}, {0, 1}},
+			wantErr: ErrSingularBlock{Block: 1},
+			desc:    "Second block is singular",
+		},
+	} {
+		if _, err := NewBlockJacobi(matrix, test.blocks); !errors.Is(err, test.wantErr) {
+			t.Errorf("Test %s: wanted %v got %v", test.desc, test.wantErr, err)
+		}
+	}
+
+	if _, err := NewFixedSizeBlockJacobi(matrix, 0); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("Wanted %v got %v", ErrInvalidArgument, err)
+	}
+
+	zeroDiag := &precondtest.DenseNonZeroDoer{Dense: mat.NewDense(2, 2, []float64{0.0, 1.0, 1.0, 1.0})}
+	if _, err := NewJacobi(zeroDiag); !errors.Is(err, ErrZeroPivot{Row: 0}) {
+		t.Errorf("Wanted %v got %v", ErrZeroPivot{Row: 0}, err)
+	}
+
+	tinyDiag := &precondtest.DenseNonZeroDoer{Dense: mat.NewDense(2, 2, []float64{1.0, 1.0, 1.0, 1e-12})}
+	if _, err := NewJacobi(tinyDiag); !errors.Is(err, ErrZeroPivot{Row: 1, Value: 1e-12}) {
+		t.Errorf("Wanted %v got %v", ErrZeroPivot{Row: 1, Value: 1e-12}, err)
+	}
+}
+
+func TestJacobiWithLinsolve(t *testing.T) {
+	tc := randomSymmetricTestCase(50)
+	matrix := NewCSRMulVecToer(denseToCSR(tc.matrix))
+	jacobi := Jacobi(matrix.Matrix)
+	bj := FixedSizeBlockJacobi(matrix.Matrix, 4)
+
+	for _, settings := range []*linsolve.Settings{
+		{PreconSolve: jacobi.SolveVecTo},
+		{PreconSolve: bj.SolveVecTo},
+	} {
+		if _, err := linsolve.Iterative(&matrix, tc.rhs, &linsolve.GMRES{}, settings); err != nil {
+			t.Errorf("%v", err)
+		}
+	}
+}
@@ -0,0 +1,378 @@
+package precond
+
+import (
+	"math"
+
+	"github.com/james-bowman/sparse"
+)
+
+// Matching is a row permutation together with a row and column scaling of a matrix A,
+// such that the matrix B = P Dr A Dc has entries with absolute value one on the diagonal
+// and entries with absolute value at most one elsewhere. Here P is the pivot matrix, and
+// Dr and Dc are the diagonal matrices holding the row and column scaling
+type Matching struct {
+	// Row Pivots[k] of A is matched with column k and is placed at row k of B
+	Pivot Pivot
+
+	// Scaling of the rows and columns of A
+	RowScaling []float64
+	ColScaling []float64
+}
+
+// Apply returns the permuted and scaled matrix B = P Dr A Dc. The solution of A x = b is
+// x = Dc y, where y is the solution of B y = P Dr b
+func (m *Matching) Apply(A *sparse.CSR) *sparse.CSR {
+	raw := A.RawMatrix()
+	n := len(m.Pivot.Pivots)
+	indptr := make([]int, n+1)
+	ind := make([]int, 0, len(raw.Ind))
+	data := make([]float64, 0, len(raw.Data))
+	for k, i := range m.Pivot.Pivots {
+		for p := raw.Indptr[i]; p < raw.Indptr[i+1]; p++ {
+			j := raw.Ind[p]
+			ind = append(ind, j)
+			data = append(data, m.RowScaling[i]*raw.Data[p]*m.ColScaling[j])
+		}
+		indptr[k+1] = len(ind)
+	}
+	return sparse.NewCSR(n, n, indptr, ind, data)
+}
+
+// distItem is an entry in the priority queue of the shortest path search
+type distItem struct {
+	dist float64
+	row  int
+}
+
+// distHeap is a min-heap of distances
+type distHeap []distItem
+
+func (h *distHeap) push(item distItem) {
+	*h = append(*h, item)
+	q := *h
+	for i := len(q) - 1; i > 0; {
+		parent := (i - 1) / 2
+		if q[parent].dist <= q[i].dist {
+			break
+		}
+		q[i], q[parent] = q[parent], q[i]
+		i = parent
+	}
+}
+
+func (h *distHeap) pop() distItem {
+	q := *h
+	item := q[0]
+	last := len(q) - 1
+	q[0] = q[last]
+	q = q[:last]
+	for i := 0; ; {
+		child := 2*i + 1
+		if child >= len(q) {
+			break
+		}
+		if child+1 < len(q) && q[child+1].dist < q[child].dist {
+			child++
+		}
+		if q[i].dist <= q[child].dist {
+			break
+		}
+		q[i], q[child] = q[child], q[i]
+		i = child
+	}
+	*h = q
+	return item
+}
+
+// matchingProblem is the weighted bipartite matching problem stored by column. The cost
+// of an entry is log(max_i |a_ij|) - log|a_ij| >= 0, and a matching of minimum cost is a
+// matching of maximum product
+type matchingProblem struct {
+	n      int
+	colPtr []int
+	rowInd []int
+	cost   []float64
+	colMax []float64
+
+	// Dual variables of the columns and rows. The reduced cost
+	// cost - colDual[j] - rowDual[i] is non-negative, and zero for matched entries
+	colDual []float64
+	rowDual []float64
+
+	// Row matched with each column and column matched with each row, or -1
+	rowOf []int
+	colOf []int
+}
+
+func newMatchingProblem(A *sparse.CSR) (*matchingProblem, error) {
+	raw := A.RawMatrix()
+	n := raw.I
+	mp := &matchingProblem{
+		n:       n,
+		colPtr:  make([]int, n+1),
+		colMax:  make([]float64, n),
+		colDual: make([]float64, n),
+		rowDual: make([]float64, n),
+		rowOf:   make([]int, n),
+		colOf:   make([]int, n),
+	}
+
+	// Explicitly stored zeros can not be matched
+	for p, j := range raw.Ind {
+		if raw.Data[p] != 0.0 {
+			mp.colPtr[j+1]++
+			mp.colMax[j] = max(mp.colMax[j], math.Abs(raw.Data[p]))
+		}
+	}
+	for j := 0; j < n; j++ {
+		if mp.colPtr[j+1] == 0 {
+			return nil, ErrStructurallySingular{Column: j}
+		}
+		mp.colPtr[j+1] += mp.colPtr[j]
+	}
+
+	mp.rowInd = make([]int, mp.colPtr[n])
+	mp.cost = make([]float64, mp.colPtr[n])
+	next := make([]int, n)
+	copy(next, mp.colPtr[:n])
+	for i := 0; i < n; i++ {
+		for p := raw.Indptr[i]; p < raw.Indptr[i+1]; p++ {
+			if v := raw.Data[p]; v != 0.0 {
+				j := raw.Ind[p]
+				mp.rowInd[next[j]] = i
+				mp.cost[next[j]] = math.Log(mp.colMax[j]) - math.Log(math.Abs(v))
+				next[j]++
+			}
+		}
+	}
+	return mp, nil
+}
+
+// reducedCost returns the reduced cost of entry p in column j. Rounding errors can make
+// it slightly negative, thus it is clamped at zero
+func (mp *matchingProblem) reducedCost(p, j int) float64 {
+	return max(0.0, mp.cost[p]-mp.colDual[j]-mp.rowDual[mp.rowInd[p]])
+}
+
+// initialize sets feasible dual variables and matches the columns greedily along
+// entries with zero reduced cost
+func (mp *matchingProblem) initialize() {
+	for i := 0; i < mp.n; i++ {
+		mp.rowDual[i] = math.Inf(1)
+		mp.rowOf[i] = -1
+		mp.colOf[i] = -1
+	}
+	for p, i := range mp.rowInd {
+		mp.rowDual[i] = min(mp.rowDual[i], mp.cost[p])
+	}
+	for i := range mp.rowDual {
+		if math.IsInf(mp.rowDual[i], 1) {
+			// Empty row
+			mp.rowDual[i] = 0.0
+		}
+	}
+
+	for j := 0; j < mp.n; j++ {
+		best, bestCost := -1, math.Inf(1)
+		for p := mp.colPtr[j]; p < mp.colPtr[j+1]; p++ {
+			i := mp.rowInd[p]
+			if c := mp.cost[p] - mp.rowDual[i]; c < bestCost || (c == bestCost && mp.colOf[i] == -1) {
+				best, bestCost = i, c
+			}
+		}
+		mp.colDual[j] = bestCost
+		if mp.colOf[best] == -1 {
+			mp.rowOf[j], mp.colOf[best] = best, j
+		}
+	}
+
+	// Augmenting paths of length two along entries with zero reduced cost. The unmatched
+	// column j takes the row i of column k, if k can be matched with a free row instead
+	for j := 0; j < mp.n; j++ {
+		if mp.rowOf[j] != -1 {
+			continue
+		}
+
+	search:
+		for p := mp.colPtr[j]; p < mp.colPtr[j+1]; p++ {
+			i := mp.rowInd[p]
+			if mp.reducedCost(p, j) > 0.0 {
+				continue
+			}
+
+			k := mp.colOf[i]
+			for q := mp.colPtr[k]; q < mp.colPtr[k+1]; q++ {
+				if free := mp.rowInd[q]; mp.colOf[free] == -1 && mp.reducedCost(q, k) == 0.0 {
+					mp.rowOf[k], mp.colOf[free] = free, k
+					mp.rowOf[j], mp.colOf[i] = i, j
+					break search
+				}
+			}
+		}
+	}
+}
+
+// augment matches column root by a shortest augmenting path search, where the lengths of
+// the edges are the reduced costs. The dual variables are updated such that the reduced
+// costs stay non-negative. False is returned if there is no augmenting path
+func (mp *matchingProblem) augment(root int, ws *matchingWorkspace) bool {
+	ws.h = ws.h[:0]
+	ws.cols = append(ws.cols[:0], root)
+	ws.colDist = append(ws.colDist[:0], 0.0)
+	ws.rows = ws.rows[:0]
+
+	sink, sinkDist := -1, 0.0
+	for head := 0; sink == -1; {
+		// Relax the entries of the columns reached since the last row was finalized
+		for ; head < len(ws.cols); head++ {
+			j, d := ws.cols[head], ws.colDist[head]
+			for p := mp.colPtr[j]; p < mp.colPtr[j+1]; p++ {
+				i := mp.rowInd[p]
+				if ws.done[i] {
+					continue
+				}
+				if nd := d + mp.reducedCost(p, j); nd < ws.dist[i] {
+					if math.IsInf(ws.dist[i], 1) {
+						ws.touched = append(ws.touched, i)
+					}
+					ws.dist[i] = nd
+					ws.pred[i] = j
+					ws.h.push(distItem{dist: nd, row: i})
+				}
+			}
+		}
+
+		// Finalize the closest row
+		i := -1
+		for len(ws.h) > 0 {
+			item := ws.h.pop()
+			if !ws.done[item.row] && item.dist == ws.dist[item.row] {
+				i = item.row
+				break
+			}
+		}
+		if i == -1 {
+			ws.reset()
+			return false
+		}
+
+		ws.done[i] = true
+		ws.rows = append(ws.rows, i)
+		if mp.colOf[i] == -1 {
+			sink, sinkDist = i, ws.dist[i]
+		} else {
+			// The matched entry has zero reduced cost
+			ws.cols = append(ws.cols, mp.colOf[i])
+			ws.colDist = append(ws.colDist, ws.dist[i])
+		}
+	}
+
+	for k, j := range ws.cols {
+		mp.colDual[j] += sinkDist - ws.colDist[k]
+	}
+	for _, i := range ws.rows {
+		mp.rowDual[i] -= sinkDist - ws.dist[i]
+	}
+
+	// Flip the matching along the path
+	for i := sink; ; {
+		j := ws.pred[i]
+		prev := mp.rowOf[j]
+		mp.rowOf[j], mp.colOf[i] = i, j
+		if j == root {
+			break
+		}
+		i = prev
+	}
+	ws.reset()
+	return true
+}
+
+// matchingWorkspace holds the work arrays of the shortest path search
+type matchingWorkspace struct {
+	dist    []float64
+	pred    []int
+	done    []bool
+	touched []int
+	h       distHeap
+
+	// Columns reached in the search and their distance from the root, and the rows
+	// that are finalized
+	cols    []int
+	colDist []float64
+	rows    []int
+}
+
+func newMatchingWorkspace(n int) *matchingWorkspace {
+	ws := &matchingWorkspace{
+		dist: make([]float64, n),
+		pred: make([]int, n),
+		done: make([]bool, n),
+	}
+	for i := range ws.dist {
+		ws.dist[i] = math.Inf(1)
+	}
+	return ws
+}
+
+// reset restores the rows reached in the last search
+func (ws *matchingWorkspace) reset() {
+	for _, i := range ws.touched {
+		ws.dist[i] = math.Inf(1)
+		ws.done[i] = false
+	}
+	ws.touched = ws.touched[:0]
+}
+
+// MaximumProductMatching calculates a row permutation that places large entries on the
+// diagonal of A, similar to the MC64 routine of Duff and Koster. Among all permutations
+// giving a zero-free diagonal, the one maximizing the product of the absolute values of
+// the diagonal entries is chosen. The problem is solved as a minimum cost bipartite
+// matching by shortest augmenting paths, and the dual variables give a row and column
+// scaling such that the permuted and scaled matrix has ones on the diagonal and entries
+// of absolute value at most one elsewhere. Unlike PartialPivotMatrix, a zero-free diagonal
+// is always found if one exists, thus the matrix returned by Matching.Apply can be
+// factorized by ILUZero when A has zeros on the diagonal. The method panics if A is not
+// square or is structurally singular. Use NewMaximumProductMatching to get an error instead
+func MaximumProductMatching(A *sparse.CSR) Matching {
+	m, err := NewMaximumProductMatching(A)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// NewMaximumProductMatching is the same as MaximumProductMatching, except that it returns
+// an error instead of panicking. ErrNotSquare is returned if A is not square and
+// ErrStructurallySingular if no permutation gives a zero-free diagonal
+func NewMaximumProductMatching(A *sparse.CSR) (Matching, error) {
+	if err := checkSquare(A); err != nil {
+		return Matching{}, err
+	}
+
+	mp, err := newMatchingProblem(A)
+	if err != nil {
+		return Matching{}, err
+	}
+
+	mp.initialize()
+	ws := newMatchingWorkspace(mp.n)
+	for j := 0; j < mp.n; j++ {
+		if mp.rowOf[j] == -1 && !mp.augment(j, ws) {
+			return Matching{}, ErrStructurallySingular{Column: j}
+		}
+	}
+
+	m := Matching{
+		Pivot:      Pivot{Pivots: mp.rowOf},
+		RowScaling: make([]float64, mp.n),
+		ColScaling: make([]float64, mp.n),
+	}
+	for i := range m.RowScaling {
+		m.RowScaling[i] = math.Exp(mp.rowDual[i])
+	}
+	for j := range m.ColScaling {
+		m.ColScaling[j] = math.Exp(mp.colDual[j]) / mp.colMax[j]
+	}
+	return m, nil
+}
@@ -0,0 +1,182 @@
+package precond
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/james-bowman/sparse"
+	"gonum.org/v1/exp/linsolve"
+	"gonum.org/v1/gonum/mat"
+	"pgregory.net/rapid"
+)
+
+// bestDiagonalProduct returns the largest product of absolute values of a diagonal
+// obtained by permuting the rows of A, found by trying all permutations
+func bestDiagonalProduct(A *mat.Dense) float64 {
+	n, _ := A.Dims()
+	perm := make([]int, n)
+	for i := range perm {
+		perm[i] = i
+	}
+
+	best := 0.0
+	var search func(k int)
+	search = func(k int) {
+		if k == n {
+			product := 1.0
+			for j, i := range perm {
+				product *= math.Abs(A.At(i, j))
+			}
+			best = max(best, product)
+			return
+		}
+		for i := k; i < n; i++ {
+			perm[k], perm[i] = perm[i], perm[k]
+			search(k + 1)
+			perm[k], perm[i] = perm[i], perm[k]
+		}
+	}
+	search(0)
+	return best
+}
+
+func TestMaximumProductMatchingProperties(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		n := rapid.IntRange(1, 6).Draw(t, "n")
+		identity := make([]int, n)
+		for i := range identity {
+			identity[i] = i
+		}
+		perm := rapid.Permutation(identity).Draw(t, "perm")
+
+		// Random sparse matrix with a zero-free diagonal after the permutation perm
+		A := mat.NewDense(n, n, nil)
+		value := rapid.Float64Range(0.01, 100.0)
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				if perm[j] == i || rapid.IntRange(0, 2).Draw(t, "zero") == 0 {
+					sign := float64(1 - 2*rapid.IntRange(0, 1).Draw(t, "sign"))
+					A.Set(i, j, sign*value.Draw(t, "value"))
+				}
+			}
+		}
+
+		m := MaximumProductMatching(denseToCSR(A))
+		seen := make([]bool, n)
+		product := 1.0
+		for j, i := range m.Pivot.Pivots {
+			if seen[i] {
+				t.Fatalf("The pivots are not a permutation: %v", m.Pivot.Pivots)
+			}
+			seen[i] = true
+			product *= math.Abs(A.At(i, j))
+		}
+
+		if best := bestDiagonalProduct(A); product < best*(1.0-1e-10) {
+			t.Fatalf("Expected the product of the diagonal to be %f got %f", best, product)
+		}
+
+		B := m.Apply(denseToCSR(A))
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				v := math.Abs(B.At(i, j))
+				if i == j && math.Abs(v-1.0) > 1e-10 {
+					t.Fatalf("Expected a unit diagonal, got %f in row %d", v, i)
+				}
+				if v > 1.0+1e-10 {
+					t.Fatalf("Expected entries of absolute value at most one, got %f at (%d, %d)", v, i, j)
+				}
+			}
+		}
+	})
+}
+
+func TestMatchingWherePartialPivotingFails(t *testing.T) {
+	// Column 1 can only be matched with row 0, but row 0 holds the largest entry of column 0
+	A := mat.NewDense(2, 2, []float64{3.0, 5.0, 1.0, 0.0})
+	m := MaximumProductMatching(denseToCSR(A))
+	if want := []int{1, 0}; !slices.Equal(m.Pivot.Pivots, want) {
+		t.Errorf("Wanted %v got %v", want, m.Pivot.Pivots)
+	}
+}
+
+func TestMatchingMakesILUZeroApplicable(t *testing.T) {
+	// Poisson matrix where the rows are shifted cyclically, such that the diagonal
+	// is zero
+	n := 100
+	dok := sparse.NewDOK(n, n)
+	for i := 0; i < n; i++ {
+		row := (i + 1) % n
+		dok.Set(row, i, 2.0+0.1*float64(i))
+		if i > 0 {
+			dok.Set(row, i-1, -1.0)
+		}
+		if i < n-1 {
+			dok.Set(row, i+1, -1.0)
+		}
+	}
+	A := dok.ToCSR()
+
+	if _, err := NewILUZero(A); !errors.As(err, &ErrZeroPivot{}) {
+		t.Fatalf("Expected ErrZeroPivot without the matching got %v", err)
+	}
+
+	m := MaximumProductMatching(A)
+	B := m.Apply(A)
+	ilu, err := NewILUZero(B)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// Solve A x = b by solving B y = P Dr b and setting x = Dc y
+	b := mat.NewVecDense(n, nil)
+	for i := 0; i < n; i++ {
+		b.SetVec(i, 1.0)
+	}
+	rhs := mat.NewVecDense(n, nil)
+	for k, i := range m.Pivot.Pivots {
+		rhs.SetVec(k, m.RowScaling[i]*b.AtVec(i))
+	}
+
+	matrix := NewCSRMulVecToer(B)
+	settings := &linsolve.Settings{PreconSolve: ilu.SolveVecTo, MaxIterations: 10}
+	result, err := linsolve.Iterative(&matrix, rhs, &linsolve.BiCGStab{}, settings)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	x := mat.NewVecDense(n, nil)
+	for j := 0; j < n; j++ {
+		x.SetVec(j, m.ColScaling[j]*result.X.AtVec(j))
+	}
+
+	got := mat.NewVecDense(n, nil)
+	got.MulVec(A, x)
+	if !mat.EqualApprox(got, b, 1e-8) {
+		t.Errorf("Expected A x = b. Got residual %e", mat.Norm(got, 2))
+	}
+}
+
+func TestMatchingErrors(t *testing.T) {
+	nonSquare := sparse.NewCSR(2, 3, []int{0, 1, 2}, []int{0, 1}, []float64{1.0, 1.0})
+	if _, err := NewMaximumProductMatching(nonSquare); !errors.Is(err, ErrNotSquare{Rows: 2, Cols: 3}) {
+		t.Errorf("Wanted %v got %v", ErrNotSquare{Rows: 2, Cols: 3}, err)
+	}
+
+	for _, test := range []struct {
+		matrix *mat.Dense
+		column int
+		desc   string
+	}{
+		{matrix: mat.NewDense(2, 2, []float64{1.0, 0.0, 1.0, 0.0}), column: 1, desc: "zero column"},
+		{matrix: mat.NewDense(3, 3, []float64{1.0, 1.0, 1.0, 1.0, 0.0, 0.0, 1.0, 0.0, 0.0}), column: 2, desc: "two columns share one row"},
+	} {
+		var singular ErrStructurallySingular
+		_, err := NewMaximumProductMatching(denseToCSR(test.matrix))
+		if !errors.As(err, &singular) || singular.Column != test.column {
+			t.Errorf("%s: wanted ErrStructurallySingular got %v", test.desc, err)
+		}
+	}
+}
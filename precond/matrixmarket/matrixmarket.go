@@ -0,0 +1,131 @@
+// Package matrixmarket reads and writes matrices in the Matrix Market exchange format.
+// The coordinate format stores the non-zero entries of a sparse matrix as one-based
+// (row, column, value) triplets, and the array format stores all entries of a dense
+// matrix in column major order. Real, integer and pattern matrices are supported, and
+// symmetric and skew-symmetric matrices are stored by their lower triangle. Files are
+// read and written line by line, such that large files are never held in memory
+package matrixmarket
+
+import (
+	"fmt"
+)
+
+// Format is the storage format of the matrix
+type Format int
+
+const (
+	// Coordinate stores the non-zero entries as (row, column, value) triplets
+	Coordinate Format = iota
+
+	// Array stores all entries in column major order
+	Array
+)
+
+func (f Format) String() string {
+	switch f {
+	case Coordinate:
+		return "coordinate"
+	case Array:
+		return "array"
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// Field is the type of the values
+type Field int
+
+const (
+	// Real values are floating point numbers
+	Real Field = iota
+
+	// Integer values are read as float64, thus integers larger than 2^53 lose precision
+	Integer
+
+	// Pattern matrices store no values. All entries are read as one
+	Pattern
+)
+
+func (f Field) String() string {
+	switch f {
+	case Real:
+		return "real"
+	case Integer:
+		return "integer"
+	case Pattern:
+		return "pattern"
+	}
+	return fmt.Sprintf("Field(%d)", int(f))
+}
+
+// Symmetry determines which entries are stored
+type Symmetry int
+
+const (
+	// General stores all entries
+	General Symmetry = iota
+
+	// Symmetric stores the lower triangle including the diagonal. The entry (j, i)
+	// equals the entry (i, j)
+	Symmetric
+
+	// SkewSymmetric stores the strictly lower triangle. The entry (j, i) equals minus
+	// the entry (i, j), and the diagonal is zero
+	SkewSymmetric
+)
+
+func (s Symmetry) String() string {
+	switch s {
+	case General:
+		return "general"
+	case Symmetric:
+		return "symmetric"
+	case SkewSymmetric:
+		return "skew-symmetric"
+	}
+	return fmt.Sprintf("Symmetry(%d)", int(s))
+}
+
+// firstRow returns the first stored row of column j in the array format
+func (s Symmetry) firstRow(j int) int {
+	switch s {
+	case Symmetric:
+		return j
+	case SkewSymmetric:
+		return j + 1
+	}
+	return 0
+}
+
+// Header holds the banner, the comments and the size line of a Matrix Market file
+type Header struct {
+	Format   Format
+	Field    Field
+	Symmetry Symmetry
+
+	// Comment lines without the leading %
+	Comments []string
+
+	Rows int
+	Cols int
+
+	// Number of stored entries. For symmetric and skew-symmetric matrices only the lower
+	// triangle is counted, and for the array format the zeros are included
+	Entries int
+}
+
+// Entry is a stored entry of the matrix with zero-based indices
+type Entry struct {
+	Row   int
+	Col   int
+	Value float64
+}
+
+// ErrFormat is returned when a file does not follow the Matrix Market format
+type ErrFormat struct {
+	Line   int
+	Reason string
+}
+
+func (e ErrFormat) Error() string {
+	return fmt.Sprintf("matrix market: line %d: %s", e.Line, e.Reason)
+}
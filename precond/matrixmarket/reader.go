@@ -0,0 +1,383 @@
+package matrixmarket
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/davidkleiven/goprecond/precond/internal/coo"
+	"github.com/james-bowman/sparse"
+)
+
+// Reader reads the entries of a Matrix Market file one at a time, such that a file can
+// be processed without storing the full matrix
+type Reader struct {
+	scanner *bufio.Scanner
+	header  Header
+	line    int
+	count   int
+
+	// Position of the next entry in the array format
+	row int
+	col int
+
+	fields [][]byte
+}
+
+// NewReader reads the header of a Matrix Market file from r. ErrFormat is returned if
+// the header is malformed or if the file holds complex or hermitian matrices, which are
+// not supported
+func NewReader(r io.Reader) (*Reader, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), math.MaxInt32)
+	reader := &Reader{scanner: scanner}
+	if err := reader.readHeader(); err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
+// Header returns the header of the file
+func (r *Reader) Header() Header {
+	return r.header
+}
+
+// scan advances to the next line. io.EOF is returned at the end of the file
+func (r *Reader) scan() ([]byte, error) {
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	r.line++
+	return r.scanner.Bytes(), nil
+}
+
+// nextLine returns the next line that is neither blank nor a comment
+func (r *Reader) nextLine() ([]byte, error) {
+	for {
+		line, err := r.scan()
+		if err != nil {
+			return nil, err
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 && line[0] != '%' {
+			return line, nil
+		}
+	}
+}
+
+func (r *Reader) formatError(format string, args ...any) error {
+	return ErrFormat{Line: r.line, Reason: fmt.Sprintf(format, args...)}
+}
+
+func (r *Reader) readHeader() error {
+	banner, err := r.scan()
+	if err == io.EOF {
+		return r.formatError("the file is empty")
+	}
+	if err != nil {
+		return err
+	}
+
+	r.fields = splitFields(banner, r.fields[:0])
+	if len(r.fields) != 5 || !strings.EqualFold(string(r.fields[0]), "%%MatrixMarket") {
+		return r.formatError("expected the banner %%%%MatrixMarket matrix <format> <field> <symmetry>")
+	}
+	if object := string(r.fields[1]); !strings.EqualFold(object, "matrix") {
+		return r.formatError("unsupported object %q", object)
+	}
+
+	h := &r.header
+	switch format := strings.ToLower(string(r.fields[2])); format {
+	case "coordinate":
+		h.Format = Coordinate
+	case "array":
+		h.Format = Array
+	default:
+		return r.formatError("unsupported format %q", format)
+	}
+
+	switch field := strings.ToLower(string(r.fields[3])); field {
+	case "real", "double":
+		h.Field = Real
+	case "integer":
+		h.Field = Integer
+	case "pattern":
+		h.Field = Pattern
+	default:
+		return r.formatError("unsupported field %q", field)
+	}
+
+	switch symmetry := strings.ToLower(string(r.fields[4])); symmetry {
+	case "general":
+		h.Symmetry = General
+	case "symmetric":
+		h.Symmetry = Symmetric
+	case "skew-symmetric":
+		h.Symmetry = SkewSymmetric
+	default:
+		return r.formatError("unsupported symmetry %q", symmetry)
+	}
+
+	if h.Format == Array && h.Field == Pattern {
+		return r.formatError("the array format can not hold a pattern matrix")
+	}
+
+	// Comments and blank lines up to the size line
+	var size []byte
+	for {
+		line, err := r.scan()
+		if err == io.EOF {
+			return r.formatError("missing size line")
+		}
+		if err != nil {
+			return err
+		}
+
+		trimmed := bytes.TrimSpace(line)
+		if len(trimmed) > 0 && trimmed[0] == '%' {
+			h.Comments = append(h.Comments, string(trimmed[1:]))
+			continue
+		}
+		if len(trimmed) > 0 {
+			size = trimmed
+			break
+		}
+	}
+
+	r.fields = splitFields(size, r.fields[:0])
+	numFields := 3
+	if h.Format == Array {
+		numFields = 2
+	}
+	if len(r.fields) != numFields {
+		return r.formatError("expected %d integers on the size line, got %d fields", numFields, len(r.fields))
+	}
+
+	dims := make([]int, numFields)
+	for k, field := range r.fields {
+		v, err := strconv.Atoi(string(field))
+		if err != nil || v < 0 {
+			return r.formatError("invalid size %q", field)
+		}
+		dims[k] = v
+	}
+	h.Rows, h.Cols = dims[0], dims[1]
+
+	if h.Symmetry != General && h.Rows != h.Cols {
+		return r.formatError("a %s matrix must be square, got %d x %d", h.Symmetry, h.Rows, h.Cols)
+	}
+
+	// The sizes are not trusted, since they are used to size the storage. The number
+	// of entries in the matrix must be representable
+	if h.Rows > 0 && h.Cols > math.MaxInt/h.Rows {
+		return r.formatError("the size %d x %d is too large", h.Rows, h.Cols)
+	}
+
+	if h.Format == Coordinate {
+		h.Entries = dims[2]
+		if h.Entries > h.Rows*h.Cols {
+			return r.formatError("%d entries do not fit in a %d x %d matrix", h.Entries, h.Rows, h.Cols)
+		}
+	} else {
+		// n*(n-1) is at most n*n, which is known not to overflow
+		n := h.Rows
+		switch h.Symmetry {
+		case General:
+			h.Entries = h.Rows * h.Cols
+		case Symmetric:
+			h.Entries = n*max(n-1, 0)/2 + n
+		case SkewSymmetric:
+			h.Entries = n * max(n-1, 0) / 2
+		}
+		r.col = 0
+		r.row = h.Symmetry.firstRow(0)
+		r.advanceColumn()
+	}
+	return nil
+}
+
+// advanceColumn moves the array position to the next column when the current column is
+// exhausted
+func (r *Reader) advanceColumn() {
+	for r.row >= r.header.Rows && r.col < r.header.Cols {
+		r.col++
+		r.row = r.header.Symmetry.firstRow(r.col)
+	}
+}
+
+// Next returns the next stored entry. For symmetric and skew-symmetric matrices the
+// mirrored entry is not returned. io.EOF is returned when all entries are read, and
+// ErrFormat if an entry is malformed or the file ends early
+func (r *Reader) Next() (Entry, error) {
+	if r.count == r.header.Entries {
+		return Entry{}, io.EOF
+	}
+
+	line, err := r.nextLine()
+	if err == io.EOF {
+		return Entry{}, r.formatError("expected %d entries, got %d", r.header.Entries, r.count)
+	}
+	if err != nil {
+		return Entry{}, err
+	}
+	r.fields = splitFields(line, r.fields[:0])
+
+	var entry Entry
+	if r.header.Format == Array {
+		if len(r.fields) != 1 {
+			return Entry{}, r.formatError("expected one value, got %d fields", len(r.fields))
+		}
+		entry.Row, entry.Col = r.row, r.col
+		if entry.Value, err = r.parseValue(r.fields[0]); err != nil {
+			return Entry{}, err
+		}
+		r.row++
+		r.advanceColumn()
+	} else {
+		numFields := 3
+		if r.header.Field == Pattern {
+			numFields = 2
+		}
+		if len(r.fields) != numFields {
+			return Entry{}, r.formatError("expected %d fields, got %d", numFields, len(r.fields))
+		}
+
+		if entry.Row, err = r.parseIndex(r.fields[0], r.header.Rows); err != nil {
+			return Entry{}, err
+		}
+		if entry.Col, err = r.parseIndex(r.fields[1], r.header.Cols); err != nil {
+			return Entry{}, err
+		}
+
+		entry.Value = 1.0
+		if r.header.Field != Pattern {
+			if entry.Value, err = r.parseValue(r.fields[2]); err != nil {
+				return Entry{}, err
+			}
+		}
+
+		if r.header.Symmetry == SkewSymmetric && entry.Row == entry.Col {
+			return Entry{}, r.formatError("a skew-symmetric matrix can not store diagonal entries")
+		}
+	}
+
+	r.count++
+	return entry, nil
+}
+
+// parseIndex parses a one-based index and returns the zero-based index
+func (r *Reader) parseIndex(field []byte, size int) (int, error) {
+	v, err := strconv.Atoi(string(field))
+	if err != nil || v < 1 || v > size {
+		return 0, r.formatError("index %q is outside the range [1, %d]", field, size)
+	}
+	return v - 1, nil
+}
+
+func (r *Reader) parseValue(field []byte) (float64, error) {
+	if r.header.Field == Integer {
+		v, err := strconv.ParseInt(string(field), 10, 64)
+		if err != nil {
+			return 0.0, r.formatError("invalid integer %q", field)
+		}
+		return float64(v), nil
+	}
+
+	v, err := strconv.ParseFloat(string(field), 64)
+	if err != nil {
+		return 0.0, r.formatError("invalid value %q", field)
+	}
+	return v, nil
+}
+
+// splitFields appends the whitespace separated fields of line to dst
+func splitFields(line []byte, dst [][]byte) [][]byte {
+	for start := 0; start < len(line); {
+		for start < len(line) && isSpace(line[start]) {
+			start++
+		}
+		end := start
+		for end < len(line) && !isSpace(line[end]) {
+			end++
+		}
+		if end > start {
+			dst = append(dst, line[start:end])
+		}
+		start = end
+	}
+	return dst
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\v' || c == '\f'
+}
+
+// readTriplets reads the entries of a file, where the symmetry is expanded
+func readTriplets(r io.Reader) (coo.Triplets, error) {
+	reader, err := NewReader(r)
+	if err != nil {
+		return coo.Triplets{}, err
+	}
+
+	// The number of entries in the header is only a hint, thus the capacity is limited
+	// and the slices grow as the entries are read
+	h := reader.Header()
+	capacity := min(h.Entries, coo.MaxCapacityHint)
+	if h.Symmetry != General {
+		capacity *= 2
+	}
+	t := coo.New(h.Rows, h.Cols, capacity)
+
+	for {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			return t, nil
+		}
+		if err != nil {
+			return coo.Triplets{}, err
+		}
+
+		// Zeros in the array format are not part of the sparsity pattern
+		if h.Format == Array && entry.Value == 0.0 {
+			continue
+		}
+
+		t.Append(entry.Row, entry.Col, entry.Value)
+		if entry.Row != entry.Col {
+			switch h.Symmetry {
+			case Symmetric:
+				t.Append(entry.Col, entry.Row, entry.Value)
+			case SkewSymmetric:
+				t.Append(entry.Col, entry.Row, -entry.Value)
+			}
+		}
+	}
+}
+
+// ReadCOO reads a Matrix Market file into a COO matrix. Symmetric and skew-symmetric
+// matrices are expanded, such that both triangles are stored. Zeros in the array format
+// are skipped, while explicit zeros in the coordinate format are kept
+func ReadCOO(r io.Reader) (*sparse.COO, error) {
+	t, err := readTriplets(r)
+	if err != nil {
+		return nil, err
+	}
+	return t.COO(), nil
+}
+
+// ReadCSR reads a Matrix Market file into a CSR matrix with sorted column indices.
+// Duplicated entries are summed. Otherwise, it is the same as ReadCOO
+func ReadCSR(r io.Reader) (*sparse.CSR, error) {
+	t, err := readTriplets(r)
+	if err != nil {
+		return nil, err
+	}
+	return t.CSR(), nil
+}
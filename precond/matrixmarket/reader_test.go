@@ -0,0 +1,193 @@
+package matrixmarket
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestReadCSR(t *testing.T) {
+	for _, test := range []struct {
+		file string
+		want *mat.Dense
+		desc string
+	}{
+		{
+			file: "%%MatrixMarket matrix coordinate real general\n% comment\n\n3 2 3\n1 1 1.5\n3 2 -2e-1\n2 1 4\n",
+			want: mat.NewDense(3, 2, []float64{1.5, 0.0, 4.0, 0.0, 0.0, -0.2}),
+			desc: "coordinate real general",
+		},
+		{
+			file: "%%MatrixMarket matrix coordinate integer symmetric\n3 3 4\n1 1 2\n2 1 -1\n3 2 -1\n3 3 2\n",
+			want: mat.NewDense(3, 3, []float64{2.0, -1.0, 0.0, -1.0, 0.0, -1.0, 0.0, -1.0, 2.0}),
+			desc: "coordinate integer symmetric",
+		},
+		{
+			file: "%%MatrixMarket matrix coordinate real skew-symmetric\n3 3 2\n2 1 3.0\n3 1 -1.0\n",
+			want: mat.NewDense(3, 3, []float64{0.0, -3.0, 1.0, 3.0, 0.0, 0.0, -1.0, 0.0, 0.0}),
+			desc: "coordinate skew-symmetric",
+		},
+		{
+			file: "%%MatrixMarket matrix coordinate pattern symmetric\n2 2 2\n1 1\n2 1\n",
+			want: mat.NewDense(2, 2, []float64{1.0, 1.0, 1.0, 0.0}),
+			desc: "coordinate pattern symmetric",
+		},
+		{
+			file: "%%MatrixMarket matrix array real general\n2 3\n1\n2\n0\n4\n5\n6\n",
+			want: mat.NewDense(2, 3, []float64{1.0, 0.0, 5.0, 2.0, 4.0, 6.0}),
+			desc: "array general",
+		},
+		{
+			file: "%%MatrixMarket matrix array integer symmetric\n3 3\n1\n2\n3\n4\n5\n6\n",
+			want: mat.NewDense(3, 3, []float64{1.0, 2.0, 3.0, 2.0, 4.0, 5.0, 3.0, 5.0, 6.0}),
+			desc: "array symmetric",
+		},
+		{
+			file: "%%MatrixMarket matrix array real skew-symmetric\n3 3\n1\n2\n3\n",
+			want: mat.NewDense(3, 3, []float64{0.0, -1.0, -2.0, 1.0, 0.0, -3.0, 2.0, 3.0, 0.0}),
+			desc: "array skew-symmetric",
+		},
+		{
+			file: "%%matrixmarket MATRIX Coordinate Real General\n2 2 3\n1 2 1.0\n1 1 2.0\n1 2 3.0\n",
+			want: mat.NewDense(2, 2, []float64{2.0, 4.0, 0.0, 0.0}),
+			desc: "case insensitive banner and summed duplicates",
+		},
+	} {
+		A, err := ReadCSR(strings.NewReader(test.file))
+		if err != nil {
+			t.Errorf("%s: %v", test.desc, err)
+			continue
+		}
+		if !mat.Equal(A, test.want) {
+			t.Errorf("%s: wanted\n%v\ngot\n%v", test.desc, mat.Formatted(test.want), mat.Formatted(A))
+		}
+
+		raw := A.RawMatrix()
+		for i := 0; i < raw.I; i++ {
+			if row := raw.Ind[raw.Indptr[i]:raw.Indptr[i+1]]; !slices.IsSorted(row) || len(slices.Compact(slices.Clone(row))) != len(row) {
+				t.Errorf("%s: expected sorted and unique columns in row %d, got %v", test.desc, i, row)
+			}
+		}
+
+		coo, err := ReadCOO(strings.NewReader(test.file))
+		if err != nil {
+			t.Errorf("%s: %v", test.desc, err)
+			continue
+		}
+		if !mat.Equal(coo.ToDense(), test.want) {
+			t.Errorf("%s: expected the COO matrix to match the CSR matrix", test.desc)
+		}
+	}
+}
+
+func TestReadKeepsExplicitZerosInCoordinateFormat(t *testing.T) {
+	A, err := ReadCSR(strings.NewReader("%%MatrixMarket matrix coordinate real general\n2 2 2\n1 1 0.0\n2 2 1.0\n"))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if A.NNZ() != 2 {
+		t.Errorf("Expected two stored entries got %d", A.NNZ())
+	}
+}
+
+func TestReaderHeaderAndEntries(t *testing.T) {
+	file := "%%MatrixMarket matrix coordinate real symmetric\n%first\n% second\n3 3 2\n1 1 1.0\n\n3 1 2.0\n"
+	reader, err := NewReader(strings.NewReader(file))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	want := Header{
+		Format:   Coordinate,
+		Field:    Real,
+		Symmetry: Symmetric,
+		Comments: []string{"first", " second"},
+		Rows:     3,
+		Cols:     3,
+		Entries:  2,
+	}
+	if got := reader.Header(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Wanted header %v got %v", want, got)
+	}
+
+	// The mirrored entries of a symmetric matrix are not returned
+	var entries []Entry
+	for {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		entries = append(entries, entry)
+	}
+	if wantEntries := []Entry{{0, 0, 1.0}, {2, 0, 2.0}}; !slices.Equal(entries, wantEntries) {
+		t.Errorf("Wanted entries %v got %v", wantEntries, entries)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	for _, test := range []struct {
+		file string
+		line int
+		desc string
+	}{
+		{file: "", line: 0, desc: "empty file"},
+		{file: "%MatrixMarket matrix coordinate real general\n1 1 1\n1 1 1\n", line: 1, desc: "missing banner"},
+		{file: "%%MatrixMarket vector coordinate real general\n1 1 1\n1 1 1\n", line: 1, desc: "unsupported object"},
+		{file: "%%MatrixMarket matrix coordinate complex general\n1 1 1\n1 1 1 0\n", line: 1, desc: "complex field"},
+		{file: "%%MatrixMarket matrix coordinate real hermitian\n1 1 1\n1 1 1\n", line: 1, desc: "hermitian symmetry"},
+		{file: "%%MatrixMarket matrix array pattern general\n1 1\n", line: 1, desc: "array pattern"},
+		{file: "%%MatrixMarket matrix coordinate real general\n% only comments\n", line: 2, desc: "missing size line"},
+		{file: "%%MatrixMarket matrix coordinate real general\n2 2\n", line: 2, desc: "short size line"},
+		{file: "%%MatrixMarket matrix coordinate real general\n2 -2 1\n", line: 2, desc: "negative size"},
+		{file: "%%MatrixMarket matrix coordinate real symmetric\n2 3 1\n", line: 2, desc: "non-square symmetric"},
+		{file: "%%MatrixMarket matrix coordinate real general\n2 2 2\n1 1 1.0\n", line: 3, desc: "missing entries"},
+		{file: "%%MatrixMarket matrix coordinate real general\n2 2 1\n3 1 1.0\n", line: 3, desc: "row out of range"},
+		{file: "%%MatrixMarket matrix coordinate real general\n2 2 1\n1 0 1.0\n", line: 3, desc: "column out of range"},
+		{file: "%%MatrixMarket matrix coordinate real general\n2 2 1\n1 1 abc\n", line: 3, desc: "invalid value"},
+		{file: "%%MatrixMarket matrix coordinate integer general\n2 2 1\n1 1 1.5\n", line: 3, desc: "invalid integer"},
+		{file: "%%MatrixMarket matrix coordinate pattern general\n2 2 1\n1 1 1.0\n", line: 3, desc: "value in pattern"},
+		{file: "%%MatrixMarket matrix coordinate real skew-symmetric\n2 2 1\n1 1 1.0\n", line: 3, desc: "skew-symmetric diagonal"},
+		{file: "%%MatrixMarket matrix array real general\n1 2\n1.0 2.0\n", line: 3, desc: "two values on one line"},
+		{file: "%%MatrixMarket matrix array real general\n3037000500 3037000500\n1\n", line: 2, desc: "size overflows"},
+		{file: "%%MatrixMarket matrix coordinate real symmetric\n2 2 9223372036854775807\n1 1 1\n", line: 2, desc: "too many entries"},
+		{file: "%%MatrixMarket matrix coordinate real general\n2 3 7\n1 1 1\n", line: 2, desc: "more entries than the matrix holds"},
+	} {
+		_, err := ReadCSR(strings.NewReader(test.file))
+		var formatErr ErrFormat
+		if !errors.As(err, &formatErr) || formatErr.Line != test.line {
+			t.Errorf("%s: wanted ErrFormat at line %d got %v", test.desc, test.line, err)
+		}
+	}
+}
+
+func BenchmarkReadCSR(b *testing.B) {
+	// 5-point stencil on a 300 x 300 grid stored by its lower triangle
+	n := 300
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "%%%%MatrixMarket matrix coordinate real symmetric\n%d %d %d\n", n*n, n*n, 3*n*n-2*n)
+	for i := 0; i < n*n; i++ {
+		if i%n > 0 {
+			fmt.Fprintf(&builder, "%d %d -1.0\n", i+1, i)
+		}
+		if i >= n {
+			fmt.Fprintf(&builder, "%d %d -1.0\n", i+1, i-n+1)
+		}
+		fmt.Fprintf(&builder, "%d %d 4.0\n", i+1, i+1)
+	}
+	file := builder.String()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ReadCSR(strings.NewReader(file)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package matrixmarket
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/davidkleiven/goprecond/precond"
+)
+
+// WriteSettings controls the header of the written file. The zero value writes the
+// coordinate format with real values and general symmetry
+type WriteSettings struct {
+	Format   Format
+	Field    Field
+	Symmetry Symmetry
+
+	// Comment written below the banner. Each line is prefixed with %
+	Comment string
+}
+
+func (s *WriteSettings) withDefaults() WriteSettings {
+	if s == nil {
+		return WriteSettings{}
+	}
+	return *s
+}
+
+// stored returns true if the entry (i, j) is written for the given symmetry
+func (s Symmetry) stored(i, j int) bool {
+	switch s {
+	case Symmetric:
+		return i >= j
+	case SkewSymmetric:
+		return i > j
+	}
+	return true
+}
+
+// position is the row and column of an entry
+type position struct {
+	row, col int
+}
+
+// check verifies that A can be written with the settings. For pattern matrices only the
+// symmetry of the pattern is checked
+func (s *WriteSettings) check(A precond.ZeroAwareMatrix) error {
+	rows, cols := A.Dims()
+	if s.Format == Array && s.Field == Pattern {
+		return fmt.Errorf("%w: the array format can not hold a pattern matrix", precond.ErrInvalidArgument)
+	}
+	if s.Symmetry != General && rows != cols {
+		return precond.ErrNotSquare{Rows: rows, Cols: cols}
+	}
+
+	sign := 1.0
+	if s.Symmetry == SkewSymmetric {
+		sign = -1.0
+	}
+
+	// The off-diagonal entries are collected once, such that the transposed entry is found
+	// in constant time. Duplicates are summed, as in A.At
+	var offDiagonal map[position]float64
+	if s.Symmetry != General {
+		offDiagonal = make(map[position]float64)
+	}
+
+	var err error
+	A.DoNonZero(func(i, j int, v float64) {
+		if err != nil {
+			return
+		}
+		if s.Field == Integer && v != math.Trunc(v) {
+			err = fmt.Errorf("%w: entry (%d, %d) is not an integer, got %g", precond.ErrInvalidArgument, i, j, v)
+			return
+		}
+
+		switch {
+		case s.Symmetry == SkewSymmetric && i == j && v != 0.0:
+			err = fmt.Errorf("%w: the diagonal of a skew-symmetric matrix must be zero, got %g in row %d", precond.ErrInvalidArgument, v, i)
+		case offDiagonal != nil && i != j:
+			offDiagonal[position{row: i, col: j}] += v
+		}
+	})
+	if err != nil || offDiagonal == nil {
+		return err
+	}
+
+	A.DoNonZero(func(i, j int, v float64) {
+		if err != nil || i == j {
+			return
+		}
+
+		v = offDiagonal[position{row: i, col: j}]
+		vT, stored := offDiagonal[position{row: j, col: i}]
+		switch {
+		case s.Field == Pattern && !stored:
+			err = fmt.Errorf("%w: the pattern is not %s, entry (%d, %d) is stored but entry (%d, %d) is not", precond.ErrInvalidArgument, s.Symmetry, i, j, j, i)
+		case s.Field != Pattern && vT != sign*v:
+			err = fmt.Errorf("%w: the matrix is not %s, entry (%d, %d) is %g and entry (%d, %d) is %g", precond.ErrInvalidArgument, s.Symmetry, i, j, v, j, i, vT)
+		}
+	})
+	return err
+}
+
+// Write writes A to w in the Matrix Market format. For symmetric and skew-symmetric
+// matrices only the lower triangle is written. ErrNotSquare is returned if a symmetry is
+// requested for a non-square matrix, and ErrInvalidArgument if A does not have the
+// requested symmetry or holds non-integer values when the integer field is requested.
+// The entries are written in the order of A.DoNonZero, thus a CSR matrix is written
+// row by row. Pattern matrices are written with the sparsity pattern of A, including
+// explicitly stored zeros
+func Write(w io.Writer, A precond.ZeroAwareMatrix, settings *WriteSettings) error {
+	s := settings.withDefaults()
+	if err := s.check(A); err != nil {
+		return err
+	}
+
+	rows, cols := A.Dims()
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%%%%MatrixMarket matrix %s %s %s\n", s.Format, s.Field, s.Symmetry)
+	if s.Comment != "" {
+		for _, line := range strings.Split(s.Comment, "\n") {
+			fmt.Fprintf(bw, "%%%s\n", line)
+		}
+	}
+
+	var buf []byte
+	appendValue := func(buf []byte, v float64) []byte {
+		if s.Field == Integer {
+			return strconv.AppendInt(buf, int64(v), 10)
+		}
+		return strconv.AppendFloat(buf, v, 'g', -1, 64)
+	}
+
+	if s.Format == Array {
+		// The values are written in column major order
+		dense := make([]float64, rows*cols)
+		A.DoNonZero(func(i, j int, v float64) {
+			dense[j*rows+i] = v
+		})
+
+		fmt.Fprintf(bw, "%d %d\n", rows, cols)
+		for j := 0; j < cols; j++ {
+			for i := s.Symmetry.firstRow(j); i < rows; i++ {
+				buf = appendValue(buf[:0], dense[j*rows+i])
+				buf = append(buf, '\n')
+				bw.Write(buf)
+			}
+		}
+		return bw.Flush()
+	}
+
+	entries := 0
+	A.DoNonZero(func(i, j int, v float64) {
+		if s.Symmetry.stored(i, j) {
+			entries++
+		}
+	})
+
+	fmt.Fprintf(bw, "%d %d %d\n", rows, cols, entries)
+	A.DoNonZero(func(i, j int, v float64) {
+		if !s.Symmetry.stored(i, j) {
+			return
+		}
+		buf = strconv.AppendInt(buf[:0], int64(i+1), 10)
+		buf = append(buf, ' ')
+		buf = strconv.AppendInt(buf, int64(j+1), 10)
+		if s.Field != Pattern {
+			buf = append(buf, ' ')
+			buf = appendValue(buf, v)
+		}
+		buf = append(buf, '\n')
+		bw.Write(buf)
+	})
+
+	// Errors from the underlying writer are sticky and returned by Flush
+	return bw.Flush()
+}
+
+// WriteILU writes the factors of an incomplete factorization to lower and upper, such
+// that they can be inspected with external tools. Both factors are written in the
+// coordinate format with real values and general symmetry, including the diagonal
+func WriteILU(lower, upper io.Writer, ilu *precond.ILUPreconditioner) error {
+	if err := Write(lower, ilu.Lower(), &WriteSettings{Comment: "lower triangular factor"}); err != nil {
+		return err
+	}
+	return Write(upper, ilu.Upper(), &WriteSettings{Comment: "upper triangular factor"})
+}
@@ -0,0 +1,206 @@
+package matrixmarket
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/davidkleiven/goprecond/precond"
+	"github.com/davidkleiven/goprecond/precond/precondtest"
+	"github.com/james-bowman/sparse"
+	"gonum.org/v1/gonum/mat"
+	"pgregory.net/rapid"
+)
+
+// sparseMatrix draws a matrix with the requested symmetry, where roughly a third of the
+// entries are non-zero
+func sparseMatrix(t *rapid.T, symmetry Symmetry, field Field) *mat.Dense {
+	rows := rapid.IntRange(1, 8).Draw(t, "rows")
+	cols := rows
+	if symmetry == General {
+		cols = rapid.IntRange(1, 8).Draw(t, "cols")
+	}
+
+	A := mat.NewDense(rows, cols, nil)
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			if !symmetry.stored(i, j) || rapid.IntRange(0, 2).Draw(t, "zero") != 0 {
+				continue
+			}
+
+			v := rapid.Float64Range(-100.0, 100.0).Draw(t, "value")
+			switch field {
+			case Integer:
+				v = math.Round(v)
+			case Pattern:
+				v = 1.0
+			}
+			A.Set(i, j, v)
+			switch symmetry {
+			case Symmetric:
+				A.Set(j, i, v)
+			case SkewSymmetric:
+				A.Set(j, i, -v)
+			}
+		}
+	}
+	return A
+}
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		settings := &WriteSettings{
+			Format:   rapid.SampledFrom([]Format{Coordinate, Array}).Draw(t, "format"),
+			Symmetry: rapid.SampledFrom([]Symmetry{General, Symmetric, SkewSymmetric}).Draw(t, "symmetry"),
+			Comment:  "first line\nsecond line",
+		}
+		fields := []Field{Real, Integer}
+		if settings.Format == Coordinate {
+			fields = append(fields, Pattern)
+		}
+		settings.Field = rapid.SampledFrom(fields).Draw(t, "field")
+
+		A := sparseMatrix(t, settings.Symmetry, settings.Field)
+		var buf bytes.Buffer
+		if err := Write(&buf, &precondtest.DenseNonZeroDoer{Dense: A}, settings); err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		reader, err := NewReader(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if h := reader.Header(); h.Format != settings.Format || h.Field != settings.Field || h.Symmetry != settings.Symmetry || len(h.Comments) != 2 {
+			t.Fatalf("The header does not match the settings %v. Got %v", settings, h)
+		}
+
+		got, err := ReadCSR(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("%v\n%s", err, buf.String())
+		}
+		if !mat.Equal(got, A) {
+			t.Fatalf("Wanted\n%v\ngot\n%v", mat.Formatted(A), mat.Formatted(got))
+		}
+	})
+}
+
+func TestWriteOutput(t *testing.T) {
+	A := sparse.NewCSR(2, 3, []int{0, 2, 3}, []int{0, 2, 1}, []float64{1.5, -2.0, 0.1})
+	var buf bytes.Buffer
+	if err := Write(&buf, A, &WriteSettings{Comment: "test"}); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	want := "%%MatrixMarket matrix coordinate real general\n%test\n2 3 3\n1 1 1.5\n1 3 -2\n2 2 0.1\n"
+	if buf.String() != want {
+		t.Errorf("Wanted\n%s\ngot\n%s", want, buf.String())
+	}
+
+	buf.Reset()
+	if err := Write(&buf, A, &WriteSettings{Format: Array}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	want = "%%MatrixMarket matrix array real general\n2 3\n1.5\n0\n0\n0.1\n-2\n0\n"
+	if buf.String() != want {
+		t.Errorf("Wanted\n%s\ngot\n%s", want, buf.String())
+	}
+	// Only the pattern needs to be symmetric when the values are not written
+	B := sparse.NewCSR(2, 2, []int{0, 2, 4}, []int{0, 1, 0, 1}, []float64{1.0, 2.0, 3.0, 4.0})
+	buf.Reset()
+	if err := Write(&buf, B, &WriteSettings{Field: Pattern, Symmetry: Symmetric}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	want = "%%MatrixMarket matrix coordinate pattern symmetric\n2 2 3\n1 1\n2 1\n2 2\n"
+	if buf.String() != want {
+		t.Errorf("Wanted\n%s\ngot\n%s", want, buf.String())
+	}
+}
+
+func TestWriteErrors(t *testing.T) {
+	nonSymmetric := sparse.NewCSR(2, 2, []int{0, 1, 2}, []int{1, 1}, []float64{1.0, 2.0})
+	for _, test := range []struct {
+		matrix   precond.ZeroAwareMatrix
+		settings *WriteSettings
+		want     error
+		desc     string
+	}{
+		{
+			matrix:   sparse.NewCSR(1, 2, []int{0, 1}, []int{0}, []float64{1.0}),
+			settings: &WriteSettings{Symmetry: Symmetric},
+			want:     precond.ErrNotSquare{Rows: 1, Cols: 2},
+			desc:     "non-square symmetric",
+		},
+		{
+			matrix:   nonSymmetric,
+			settings: &WriteSettings{Symmetry: Symmetric},
+			want:     precond.ErrInvalidArgument,
+			desc:     "not symmetric",
+		},
+		{
+			matrix:   sparse.NewCSR(1, 1, []int{0, 1}, []int{0}, []float64{1.0}),
+			settings: &WriteSettings{Symmetry: SkewSymmetric},
+			want:     precond.ErrInvalidArgument,
+			desc:     "skew-symmetric with diagonal",
+		},
+		{
+			matrix:   nonSymmetric,
+			settings: &WriteSettings{Symmetry: SkewSymmetric},
+			want:     precond.ErrInvalidArgument,
+			desc:     "not skew-symmetric",
+		},
+		{
+			matrix:   sparse.NewCSR(1, 1, []int{0, 1}, []int{0}, []float64{1.5}),
+			settings: &WriteSettings{Field: Integer},
+			want:     precond.ErrInvalidArgument,
+			desc:     "non-integer value",
+		},
+		{
+			matrix:   nonSymmetric,
+			settings: &WriteSettings{Field: Pattern, Symmetry: Symmetric},
+			want:     precond.ErrInvalidArgument,
+			desc:     "pattern not symmetric",
+		},
+		{
+			matrix:   nonSymmetric,
+			settings: &WriteSettings{Format: Array, Field: Pattern},
+			want:     precond.ErrInvalidArgument,
+			desc:     "array pattern",
+		},
+	} {
+		var buf bytes.Buffer
+		if err := Write(&buf, test.matrix, test.settings); !errors.Is(err, test.want) {
+			t.Errorf("%s: wanted %v got %v", test.desc, test.want, err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("%s: expected nothing to be written", test.desc)
+		}
+	}
+}
+
+func TestWriteILU(t *testing.T) {
+	// The incomplete factorization of a dense matrix is the complete LU factorization
+	A := mat.NewDense(3, 3, []float64{4.0, 1.0, 2.0, 1.0, 5.0, 1.0, 2.0, 1.0, 6.0})
+	ilu := precond.ILUZero(&precondtest.DenseNonZeroDoer{Dense: A})
+
+	var lower, upper strings.Builder
+	if err := WriteILU(&lower, &upper, &ilu); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	L, err := ReadCSR(strings.NewReader(lower.String()))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	U, err := ReadCSR(strings.NewReader(upper.String()))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	var product mat.Dense
+	product.Mul(L, U)
+	if !mat.EqualApprox(&product, A, 1e-12) {
+		t.Errorf("Expected L U = A, got\n%v", mat.Formatted(&product))
+	}
+}
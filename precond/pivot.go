@@ -1,8 +1,10 @@
 package precond
 
 import (
+	"fmt"
 	"math"
 
+	"github.com/james-bowman/sparse"
 	"gonum.org/v1/gonum/mat"
 )
 
@@ -39,6 +41,148 @@ func (p *Pivot) T() mat.Matrix {
 	return &mat.Transpose{Matrix: p}
 }
 
+// Validate returns ErrInvalidArgument if the pivots are not a permutation of 0, 1, ..., n-1
+func (p *Pivot) Validate() error {
+	seen := make([]bool, len(p.Pivots))
+	for k, i := range p.Pivots {
+		if i < 0 || i >= len(p.Pivots) {
+			return fmt.Errorf("%w: pivot %d in row %d is out of range", ErrInvalidArgument, i, k)
+		}
+		if seen[i] {
+			return fmt.Errorf("%w: pivot %d in row %d is used more than once", ErrInvalidArgument, i, k)
+		}
+		seen[i] = true
+	}
+	return nil
+}
+
+// Inverse returns the inverse pivot matrix, which is the same as the transpose
+func (p *Pivot) Inverse() Pivot {
+	inv := make([]int, len(p.Pivots))
+	for k, i := range p.Pivots {
+		inv[i] = k
+	}
+	return Pivot{Pivots: inv}
+}
+
+// Compose returns the pivot matrix P Q, where P is the receiver. Applying P Q to a matrix
+// is the same as applying Q first and then P. The method panics if the sizes do not match
+func (p *Pivot) Compose(q *Pivot) Pivot {
+	if len(p.Pivots) != len(q.Pivots) {
+		panic(mat.ErrShape)
+	}
+
+	result := make([]int, len(p.Pivots))
+	for k, i := range p.Pivots {
+		result[k] = q.Pivots[i]
+	}
+	return Pivot{Pivots: result}
+}
+
+// PermuteVec sets dst to P x, or to P^T x if trans is true. Element k of P x is element
+// Pivots[k] of x. The method panics if the lengths do not match
+func (p *Pivot) PermuteVec(dst *mat.VecDense, trans bool, x mat.Vector) {
+	n := len(p.Pivots)
+	if dst.Len() != n || x.Len() != n {
+		panic(mat.ErrShape)
+	}
+
+	if dst == x {
+		// The elements would be overwritten before they are read
+		x = mat.VecDenseCopyOf(x)
+	}
+
+	for k, i := range p.Pivots {
+		if trans {
+			dst.SetVec(i, x.AtVec(k))
+		} else {
+			dst.SetVec(k, x.AtVec(i))
+		}
+	}
+}
+
+// PermuteRows returns P A, where row k is row Pivots[k] of A. The time is proportional
+// to the number of non-zeros, and the column indices of each row are sorted. The method
+// panics if the number of rows of A does not match the size of the pivot matrix
+func (p *Pivot) PermuteRows(A *sparse.CSR) *sparse.CSR {
+	r, c := A.Dims()
+	if r != len(p.Pivots) {
+		panic(mat.ErrShape)
+	}
+
+	identity := make([]int, c)
+	for j := range identity {
+		identity[j] = j
+	}
+	return permuteCSR(A, p.Pivots, identity)
+}
+
+// PermuteSymmetric returns P A P^T, where the element (i, j) is the element
+// (Pivots[i], Pivots[j]) of A. This is the reordering used with fill reducing orderings
+// such as AMD. The time is proportional to the number of non-zeros, and the column indices
+// of each row are sorted. The method panics if A is not square with the same size as the
+// pivot matrix
+func (p *Pivot) PermuteSymmetric(A *sparse.CSR) *sparse.CSR {
+	r, c := A.Dims()
+	if r != len(p.Pivots) || c != len(p.Pivots) {
+		panic(mat.ErrShape)
+	}
+	return permuteCSR(A, p.Pivots, p.Inverse().Pivots)
+}
+
+// permuteCSR returns the matrix where row k is row rows[k] of A, and column j of A is
+// moved to column newCol[j]. The transposed matrix is built first by visiting the rows in
+// order, and transposing it back gives sorted column indices
+func permuteCSR(A *sparse.CSR, rows, newCol []int) *sparse.CSR {
+	raw := A.RawMatrix()
+	n, m := len(rows), raw.J
+	nnz := raw.Indptr[raw.I]
+
+	tIndptr := make([]int, m+1)
+	for _, i := range rows {
+		for q := raw.Indptr[i]; q < raw.Indptr[i+1]; q++ {
+			tIndptr[newCol[raw.Ind[q]]+1]++
+		}
+	}
+	for j := 0; j < m; j++ {
+		tIndptr[j+1] += tIndptr[j]
+	}
+
+	tInd := make([]int, nnz)
+	tData := make([]float64, nnz)
+	next := make([]int, max(n, m))
+	copy(next, tIndptr[:m])
+	for k, i := range rows {
+		for q := raw.Indptr[i]; q < raw.Indptr[i+1]; q++ {
+			j := newCol[raw.Ind[q]]
+			tInd[next[j]] = k
+			tData[next[j]] = raw.Data[q]
+			next[j]++
+		}
+	}
+
+	indptr := make([]int, n+1)
+	for _, k := range tInd {
+		indptr[k+1]++
+	}
+	for k := 0; k < n; k++ {
+		indptr[k+1] += indptr[k]
+	}
+
+	ind := make([]int, nnz)
+	data := make([]float64, nnz)
+	copy(next, indptr[:n])
+	for j := 0; j < m; j++ {
+		for q := tIndptr[j]; q < tIndptr[j+1]; q++ {
+			k := tInd[q]
+			ind[next[k]] = j
+			data[next[k]] = tData[q]
+			next[k]++
+		}
+	}
+	return sparse.NewCSR(n, m, indptr, ind, data)
+}
+
 // PartialPivotMatrix calculates a pivot matrix that re-orders the rows
 // such that the diagonal is larger (in absolute value) than all elements
 // below it
@@ -1,6 +1,7 @@
 package precond
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"slices"
@@ -8,6 +9,7 @@ import (
 
 	"github.com/davidkleiven/goprecond/precond/precondtest"
 	"github.com/davidkleiven/goprecond/precond/property"
+	"github.com/james-bowman/sparse"
 	"gonum.org/v1/gonum/mat"
 	"pgregory.net/rapid"
 )
@@ -149,7 +151,7 @@ func TestPivotingProperties(t *testing.T) {
 	rapid.Check(t, func(t *rapid.T) {
 		matrix := property.DenseSquareMatrix(t, 1, 50)
 		nrows, ncols := matrix.Dims()
-		denseDoer := precondtest.DenseNonZeroDoer{matrix}
+		denseDoer := precondtest.DenseNonZeroDoer{Dense: matrix}
 		pivot := PartialPivotMatrix(&denseDoer, nrows)
 
 		origNorm := mat.Norm(matrix, 2)
@@ -169,3 +171,125 @@ func TestPivotingProperties(t *testing.T) {
 
 	})
 }
+
+func TestPivotInverseAndCompose(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		n := rapid.IntRange(1, 20).Draw(t, "n")
+		p := Pivot{Pivots: rapid.Permutation(NoPivot(n).Pivots).Draw(t, "p")}
+		q := Pivot{Pivots: rapid.Permutation(NoPivot(n).Pivots).Draw(t, "q")}
+
+		inv := p.Inverse()
+		if identity := p.Compose(&inv); !slices.Equal(identity.Pivots, NoPivot(n).Pivots) {
+			t.Fatalf("Expected P P^-1 to be the identity got %v", identity.Pivots)
+		}
+
+		if !equal(&inv, p.T(), 0.0) {
+			t.Fatalf("Expected the inverse to be the transpose")
+		}
+
+		composed := p.Compose(&q)
+		want := mat.NewDense(n, n, nil)
+		want.Mul(&p, &q)
+		if !equal(&composed, want, 0.0) {
+			t.Fatalf("Expected the composition to be the product P Q")
+		}
+	})
+}
+
+func TestPivotValidate(t *testing.T) {
+	for _, test := range []struct {
+		pivots []int
+		valid  bool
+	}{
+		{pivots: []int{2, 0, 1}, valid: true},
+		{pivots: []int{}, valid: true},
+		{pivots: []int{0, 3, 1}, valid: false},
+		{pivots: []int{-1, 0}, valid: false},
+		{pivots: []int{1, 1}, valid: false},
+	} {
+		pivot := Pivot{Pivots: test.pivots}
+		err := pivot.Validate()
+		if test.valid && err != nil {
+			t.Errorf("Pivots %v: unexpected error %v", test.pivots, err)
+		}
+		if !test.valid && !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("Pivots %v: wanted %v got %v", test.pivots, ErrInvalidArgument, err)
+		}
+	}
+}
+
+func TestPermuteVec(t *testing.T) {
+	pivot := Pivot{Pivots: []int{2, 0, 3, 1}}
+	x := mat.NewVecDense(4, []float64{1.0, 2.0, 3.0, 4.0})
+	for _, trans := range []bool{false, true} {
+		want := mat.NewVecDense(4, nil)
+		if trans {
+			want.MulVec(pivot.T(), x)
+		} else {
+			want.MulVec(&pivot, x)
+		}
+
+		got := mat.NewVecDense(4, nil)
+		pivot.PermuteVec(got, trans, x)
+		if !mat.Equal(got, want) {
+			t.Errorf("Trans %v: wanted %v got %v", trans, mat.Formatted(want.T()), mat.Formatted(got.T()))
+		}
+
+		// The result is the same when dst and x are the same vector
+		inPlace := mat.VecDenseCopyOf(x)
+		pivot.PermuteVec(inPlace, trans, inPlace)
+		if !mat.Equal(inPlace, want) {
+			t.Errorf("Trans %v in place: wanted %v got %v", trans, mat.Formatted(want.T()), mat.Formatted(inPlace.T()))
+		}
+	}
+}
+
+func TestPermuteCSR(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		matrix := property.DenseSquareMatrix(t, 1, 30)
+		n, _ := matrix.Dims()
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				if rapid.IntRange(0, 2).Draw(t, "zero") != 0 {
+					matrix.Set(i, j, 0.0)
+				}
+			}
+		}
+		A := denseToCSR(matrix)
+		pivot := Pivot{Pivots: rapid.Permutation(NoPivot(n).Pivots).Draw(t, "pivots")}
+
+		want := mat.NewDense(n, n, nil)
+		want.Mul(&pivot, matrix)
+		rows := pivot.PermuteRows(A)
+		if !equal(rows, want, 0.0) {
+			t.Fatalf("Expected P A\n%v\ngot\n%v\n", mat.Formatted(want), mat.Formatted(rows))
+		}
+
+		want.Product(&pivot, matrix, pivot.T())
+		symmetric := pivot.PermuteSymmetric(A)
+		if !equal(symmetric, want, 0.0) {
+			t.Fatalf("Expected P A P^T\n%v\ngot\n%v\n", mat.Formatted(want), mat.Formatted(symmetric))
+		}
+
+		for _, result := range []*sparse.CSR{rows, symmetric} {
+			raw := result.RawMatrix()
+			if raw.Indptr[n] != A.NNZ() {
+				t.Fatalf("Expected %d non-zeros got %d", A.NNZ(), raw.Indptr[n])
+			}
+			for i := 0; i < n; i++ {
+				if !slices.IsSorted(raw.Ind[raw.Indptr[i]:raw.Indptr[i+1]]) {
+					t.Fatalf("The columns of row %d are not sorted", i)
+				}
+			}
+		}
+	})
+}
+
+func TestPermuteRowsOfRectangularMatrix(t *testing.T) {
+	matrix := linspaceMatrix(3, 2)
+	pivot := Pivot{Pivots: []int{1, 2, 0}}
+	want := mat.NewDense(3, 2, []float64{2.0, 3.0, 4.0, 5.0, 0.0, 1.0})
+	if got := pivot.PermuteRows(denseToCSR(matrix)); !mat.Equal(got, want) {
+		t.Errorf("Wanted\n%v\ngot\n%v\n", mat.Formatted(want), mat.Formatted(got))
+	}
+}
@@ -0,0 +1,112 @@
+package precond
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/james-bowman/sparse"
+	"gonum.org/v1/gonum/mat"
+)
+
+// ReorderedPreconditioner factorizes the symmetrically permuted matrix P A P^T, and solves
+// with the factorization in the numbering of A. The ordering determines the fill of
+// factorizations such as ILUK, and the accuracy of the incomplete factors. It is not safe
+// for concurrent use, see Clone
+type ReorderedPreconditioner struct {
+	pivot  Pivot
+	factor ILUPreconditioner
+
+	// Work vectors
+	permuted *mat.VecDense
+	solution *mat.VecDense
+}
+
+func newReorderedPreconditioner(pivot Pivot, factor ILUPreconditioner) ReorderedPreconditioner {
+	r := ReorderedPreconditioner{pivot: pivot, factor: factor}
+	if n := len(pivot.Pivots); n > 0 {
+		// Vectors of zero length can not be created
+		r.permuted = mat.NewVecDense(n, nil)
+		r.solution = mat.NewVecDense(n, nil)
+	}
+	return r
+}
+
+// Reordered creates a preconditioner from the incomplete factorization of P A P^T, where
+// P = Pivot{Pivots: order}. The element order[k] is the row of A that is placed at row k,
+// as returned by the orderings in the amd package. factorize calculates the factorization
+// of the permuted matrix, for example NewILUZero or NewIChol. If factorize is nil, NewILUZero
+// is used. The method panics if A is not square, order is not a permutation or the
+// factorization fails. Use NewReordered to get an error instead
+func Reordered(A *sparse.CSR, order []int, factorize func(ZeroAwareMatrix) (ILUPreconditioner, error)) ReorderedPreconditioner {
+	reordered, err := NewReordered(A, order, factorize)
+	if err != nil {
+		panic(err)
+	}
+	return reordered
+}
+
+// NewReordered is the same as Reordered, except that it returns an error instead of
+// panicking. ErrNotSquare is returned if A is not square and ErrInvalidArgument if order
+// is not a permutation of the rows. Errors from the factorization are passed on, where the
+// row of an ErrZeroPivot is translated to the numbering of A
+func NewReordered(A *sparse.CSR, order []int, factorize func(ZeroAwareMatrix) (ILUPreconditioner, error)) (ReorderedPreconditioner, error) {
+	if err := checkSquare(A); err != nil {
+		return ReorderedPreconditioner{}, err
+	}
+
+	n, _ := A.Dims()
+	pivot := Pivot{Pivots: order}
+	if len(order) != n {
+		return ReorderedPreconditioner{}, fmt.Errorf("%w: the ordering has length %d, but the matrix has %d rows", ErrInvalidArgument, len(order), n)
+	}
+	if err := pivot.Validate(); err != nil {
+		return ReorderedPreconditioner{}, err
+	}
+
+	if factorize == nil {
+		factorize = NewILUZero
+	}
+
+	factor, err := factorize(pivot.PermuteSymmetric(A))
+	if err != nil {
+		var zeroPivot ErrZeroPivot
+		if errors.As(err, &zeroPivot) {
+			return ReorderedPreconditioner{}, ErrZeroPivot{Row: order[zeroPivot.Row], Value: zeroPivot.Value}
+		}
+		return ReorderedPreconditioner{}, err
+	}
+	return newReorderedPreconditioner(pivot, factor), nil
+}
+
+// Pivot returns the pivot matrix P
+func (r *ReorderedPreconditioner) Pivot() Pivot {
+	return r.pivot
+}
+
+// Clone returns a preconditioner that shares the pivot and the factors with the receiver,
+// but has its own work vectors, such that the clones can solve concurrently. As for
+// ILUPreconditioner.Clone, the transposed factors are created in the receiver
+func (r *ReorderedPreconditioner) Clone() ReorderedPreconditioner {
+	return newReorderedPreconditioner(r.pivot, r.factor.Clone())
+}
+
+// SolveVecTo solves A x = b, or A^T x = b if trans is true, with the factorization of
+// P A P^T. The right hand side is permuted before the solve, and the solution is permuted
+// back, such that dst and rhs are in the numbering of A. The work vectors are stored in
+// the receiver and shared by its copies, thus SolveVecTo must not be called concurrently.
+// Use Clone to get a preconditioner for each goroutine
+func (r *ReorderedPreconditioner) SolveVecTo(dst *mat.VecDense, trans bool, rhs mat.Vector) error {
+	n := len(r.pivot.Pivots)
+	if err := checkVecDims(n, dst, rhs); err != nil {
+		return err
+	}
+
+	// A = P^T M P where M is the permuted matrix, thus x = P^T M^-1 P b. The same
+	// holds for the transpose
+	r.pivot.PermuteVec(r.permuted, false, rhs)
+	if err := r.factor.SolveVecTo(r.solution, trans, r.permuted); err != nil {
+		return err
+	}
+	r.pivot.PermuteVec(dst, true, r.solution)
+	return nil
+}
@@ -0,0 +1,117 @@
+package precond
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/davidkleiven/goprecond/precond/property"
+	"github.com/james-bowman/sparse"
+	"gonum.org/v1/gonum/mat"
+	"pgregory.net/rapid"
+)
+
+func TestReorderedMatchesPermutedFactorization(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		matrix := property.DenseSquareMatrix(t, 1, 20)
+		n, _ := matrix.Dims()
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				if i != j && rapid.IntRange(0, 2).Draw(t, "zero") != 0 {
+					matrix.Set(i, j, 0.0)
+				}
+			}
+			matrix.Set(i, i, float64(n)+10.0)
+		}
+		A := denseToCSR(matrix)
+		pivot := Pivot{Pivots: rapid.Permutation(NoPivot(n).Pivots).Draw(t, "order")}
+		reordered := Reordered(A, pivot.Pivots, nil)
+		ilu := ILUZero(pivot.PermuteSymmetric(A))
+
+		rhs := mat.NewVecDense(n, nil)
+		for i := 0; i < n; i++ {
+			rhs.SetVec(i, float64(i+1))
+		}
+
+		for _, trans := range []bool{false, true} {
+			got := mat.NewVecDense(n, nil)
+			if err := reordered.SolveVecTo(got, trans, rhs); err != nil {
+				t.Fatalf("%v", err)
+			}
+
+			// x = P^T M^-1 P b, where M is the factorization of P A P^T
+			permuted := mat.NewVecDense(n, nil)
+			permuted.MulVec(&pivot, rhs)
+			solution := mat.NewVecDense(n, nil)
+			if err := ilu.SolveVecTo(solution, trans, permuted); err != nil {
+				t.Fatalf("%v", err)
+			}
+			want := mat.NewVecDense(n, nil)
+			want.MulVec(pivot.T(), solution)
+
+			if !mat.EqualApprox(got, want, 1e-12) {
+				t.Fatalf("Trans %v: wanted\n%v\ngot\n%v\n", trans, mat.Formatted(want.T()), mat.Formatted(got.T()))
+			}
+		}
+	})
+}
+
+func TestReorderedIsExactForDenseMatrices(t *testing.T) {
+	// The incomplete factorizations of a dense matrix are complete
+	matrix := mat.NewDense(4, 4, []float64{
+		5.0, 1.0, 2.0, 0.5,
+		1.0, 6.0, 1.0, 1.0,
+		2.0, 1.0, 7.0, 2.0,
+		0.5, 1.0, 2.0, 8.0,
+	})
+	A := denseToCSR(matrix)
+	rhs := mat.NewVecDense(4, []float64{1.0, 2.0, 3.0, 4.0})
+	order := []int{2, 0, 3, 1}
+
+	for _, factorize := range []func(ZeroAwareMatrix) (ILUPreconditioner, error){NewILUZero, NewIChol} {
+		reordered := Reordered(A, order, factorize)
+		if pivot := reordered.Pivot(); pivot.Pivots[0] != 2 {
+			t.Errorf("Expected the ordering to be kept got %v", pivot.Pivots)
+		}
+
+		for _, trans := range []bool{false, true} {
+			x := mat.NewVecDense(4, nil)
+			if err := reordered.SolveVecTo(x, trans, rhs); err != nil {
+				t.Fatalf("%v", err)
+			}
+
+			got := mat.NewVecDense(4, nil)
+			if trans {
+				got.MulVec(matrix.T(), x)
+			} else {
+				got.MulVec(matrix, x)
+			}
+			if !mat.EqualApprox(got, rhs, 1e-10) {
+				t.Errorf("Trans %v: wanted\n%v\ngot\n%v\n", trans, mat.Formatted(rhs.T()), mat.Formatted(got.T()))
+			}
+		}
+	}
+}
+
+func TestReorderedErrors(t *testing.T) {
+	nonSquare := sparse.NewCSR(2, 3, []int{0, 1, 2}, []int{0, 1}, []float64{1.0, 1.0})
+	if _, err := NewReordered(nonSquare, []int{0, 1}, nil); !errors.Is(err, ErrNotSquare{Rows: 2, Cols: 3}) {
+		t.Errorf("Wanted %v got %v", ErrNotSquare{Rows: 2, Cols: 3}, err)
+	}
+
+	A := denseToCSR(mat.NewDense(3, 3, []float64{1.0, 1.0, 0.0, 1.0, 2.0, 1.0, 0.0, 1.0, 0.0}))
+	for _, order := range [][]int{{0, 1}, {0, 1, 1}, {0, 1, 3}} {
+		if _, err := NewReordered(A, order, nil); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("Order %v: wanted %v got %v", order, ErrInvalidArgument, err)
+		}
+	}
+
+	// The zero diagonal of row 2 is the first pivot of the permuted matrix
+	if _, err := NewReordered(A, []int{2, 1, 0}, nil); !errors.Is(err, ErrZeroPivot{Row: 2}) {
+		t.Errorf("Wanted %v got %v", ErrZeroPivot{Row: 2}, err)
+	}
+
+	reordered := Reordered(denseToCSR(mat.NewDense(2, 2, []float64{1.0, 0.0, 0.0, 1.0})), []int{1, 0}, nil)
+	if err := reordered.SolveVecTo(mat.NewVecDense(3, nil), false, mat.NewVecDense(2, nil)); err == nil {
+		t.Errorf("Expected an error when the dimensions do not match")
+	}
+}
@@ -0,0 +1,369 @@
+// Package schwarz implements overlapping Schwarz domain decomposition preconditioners.
+// The unknowns are divided into subdomains, which are optionally extended with a number
+// of layers of overlap. Each subdomain is solved independently with a local solver, and
+// the local solutions are combined into the preconditioned vector
+package schwarz
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/davidkleiven/goprecond/precond"
+	"github.com/davidkleiven/goprecond/precond/amd"
+	"github.com/davidkleiven/goprecond/precond/internal/parallel"
+	"github.com/james-bowman/sparse"
+	"gonum.org/v1/gonum/mat"
+)
+
+// Variant determines how the local solutions are combined
+type Variant int
+
+const (
+	// Additive sums the local solutions on all unknowns in the extended subdomains.
+	// The preconditioner is symmetric if A is symmetric and the local solvers are
+	// symmetric, such that it can be used with the conjugate gradient method
+	Additive Variant = iota
+
+	// Restricted only keeps the local solution on the unknowns in the original
+	// partition (RAS). It usually converges faster than Additive, but it is not
+	// symmetric
+	Restricted
+)
+
+// LocalSolver is the method used to solve the problem on each subdomain
+type LocalSolver int
+
+const (
+	// ILUZero uses the incomplete LU decomposition with zero level of fill
+	ILUZero LocalSolver = iota
+
+	// IChol uses the incomplete Cholesky decomposition
+	IChol
+
+	// LU uses a dense LU decomposition, which solves the local problems exactly
+	LU
+)
+
+// Settings controls the construction of the subdomains and the local solvers
+type Settings struct {
+	// Number of layers of neighbouring unknowns added to each part of the partition.
+	// Defaults to 0, which gives a block Jacobi type preconditioner
+	Overlap int
+
+	Variant     Variant
+	LocalSolver LocalSolver
+}
+
+func (s *Settings) withDefaults() Settings {
+	if s == nil {
+		return Settings{}
+	}
+	return *s
+}
+
+// localSolver is satisfied by both the incomplete factorizations and mat.LU
+type localSolver interface {
+	SolveVecTo(dst *mat.VecDense, trans bool, rhs mat.Vector) error
+}
+
+// subdomain is one of the extended subdomains
+type subdomain struct {
+	// Global indices of the unknowns in increasing order
+	rows []int
+
+	// Whether each unknown is part of the original partition
+	owned []bool
+
+	solver localSolver
+
+	// Work vectors
+	rhs *mat.VecDense
+	sol *mat.VecDense
+}
+
+// Preconditioner is an overlapping Schwarz preconditioner. The subdomains are
+// solved concurrently in each call to SolveVecTo, using at most GOMAXPROCS goroutines.
+// The work vectors of the subdomains are shared by copies of a preconditioner, thus
+// SolveVecTo must not be called concurrently. Use Clone to get a preconditioner for
+// each goroutine
+type Preconditioner struct {
+	n          int
+	subdomains []subdomain
+	variant    Variant
+}
+
+// ContiguousPartition divides the unknowns 0, 1, ..., n-1 into numParts parts of
+// consecutive unknowns with sizes differing by at most one. If numParts is larger
+// than n, n parts are returned
+func ContiguousPartition(n, numParts int) [][]int {
+	numParts = max(min(numParts, n), 1)
+	parts := make([][]int, numParts)
+	start := 0
+	for p := range parts {
+		size := n / numParts
+		if p < n%numParts {
+			size++
+		}
+
+		parts[p] = make([]int, size)
+		for k := range parts[p] {
+			parts[p][k] = start + k
+		}
+		start += size
+	}
+	return parts
+}
+
+// Schwarz creates an overlapping Schwarz preconditioner where parts[k] lists the unknowns
+// in part k. Each unknown must be part of exactly one part. The parts are extended by
+// settings.Overlap layers of neighbours in the graph of A to form the subdomains. If
+// settings is nil, the default settings are used. The method panics if A is not square,
+// the parts are not a partition of the unknowns, the overlap is negative or any of the
+// local factorizations fail. Use NewSchwarz to get an error instead
+func Schwarz(A *sparse.CSR, parts [][]int, settings *Settings) Preconditioner {
+	schwarz, err := NewSchwarz(A, parts, settings)
+	if err != nil {
+		panic(err)
+	}
+	return schwarz
+}
+
+// NewSchwarz is the same as Schwarz, except that it returns an error instead of panicking.
+// precond.ErrNotSquare is returned if A is not square and precond.ErrInvalidArgument if
+// the parts are not a partition of the unknowns, the overlap is negative or the local solver
+// is unknown. Errors from the local factorizations are wrapped with the subdomain number,
+// and a singular subdomain solved with LU gives precond.ErrSingularBlock
+func NewSchwarz(A *sparse.CSR, parts [][]int, settings *Settings) (Preconditioner, error) {
+	n, c := A.Dims()
+	if n != c {
+		return Preconditioner{}, precond.ErrNotSquare{Rows: n, Cols: c}
+	}
+	opts := settings.withDefaults()
+
+	if opts.Overlap < 0 {
+		return Preconditioner{}, fmt.Errorf("%w: overlap must be non-negative, got %d", precond.ErrInvalidArgument, opts.Overlap)
+	}
+
+	if opts.LocalSolver < ILUZero || opts.LocalSolver > LU {
+		return Preconditioner{}, fmt.Errorf("%w: unknown local solver %d", precond.ErrInvalidArgument, opts.LocalSolver)
+	}
+
+	partOf := make([]int, n)
+	for i := range partOf {
+		partOf[i] = -1
+	}
+
+	for p, part := range parts {
+		for _, i := range part {
+			if i < 0 || i >= n {
+				return Preconditioner{}, fmt.Errorf("%w: row %d in part %d is out of range", precond.ErrInvalidArgument, i, p)
+			}
+
+			if partOf[i] != -1 {
+				return Preconditioner{}, fmt.Errorf("%w: row %d is part of part %d and %d", precond.ErrInvalidArgument, i, partOf[i], p)
+			}
+			partOf[i] = p
+		}
+	}
+
+	for i, p := range partOf {
+		if p == -1 {
+			return Preconditioner{}, fmt.Errorf("%w: row %d is not part of any part", precond.ErrInvalidArgument, i)
+		}
+	}
+
+	var adjacency [][]int
+	if opts.Overlap > 0 {
+		adjacency = amd.AdjacencyList(A)
+	}
+
+	schwarz := Preconditioner{
+		n:          n,
+		subdomains: make([]subdomain, 0, len(parts)),
+		variant:    opts.Variant,
+	}
+
+	// Marks the unknowns in the current subdomain
+	mark := make([]int, n)
+	for i := range mark {
+		mark[i] = -1
+	}
+
+	for p, part := range parts {
+		if len(part) == 0 {
+			continue
+		}
+		rows := extend(adjacency, part, opts.Overlap, mark, p)
+		slices.Sort(rows)
+
+		owned := make([]bool, len(rows))
+		for k, i := range rows {
+			owned[k] = partOf[i] == p
+		}
+
+		schwarz.subdomains = append(schwarz.subdomains, subdomain{
+			rows:  rows,
+			owned: owned,
+			rhs:   mat.NewVecDense(len(rows), nil),
+			sol:   mat.NewVecDense(len(rows), nil),
+		})
+	}
+
+	numSub := len(schwarz.subdomains)
+	err := parallel.For(numSub, parallel.Workers(numSub), func(_, s int) error {
+		solver, err := factorize(A, schwarz.subdomains[s].rows, opts.LocalSolver, s)
+		if err != nil {
+			return fmt.Errorf("subdomain %d: %w", s, err)
+		}
+		schwarz.subdomains[s].solver = solver
+		return nil
+	})
+	if err != nil {
+		return Preconditioner{}, err
+	}
+	return schwarz, nil
+}
+
+// extend adds overlap layers of neighbours to part with a breadth first search.
+// mark[i] is set to id for all unknowns in the extended subdomain
+func extend(adjacency [][]int, part []int, overlap int, mark []int, id int) []int {
+	rows := make([]int, 0, len(part))
+	for _, i := range part {
+		mark[i] = id
+		rows = append(rows, i)
+	}
+
+	front := rows
+	for layer := 0; layer < overlap; layer++ {
+		start := len(rows)
+		for _, i := range front {
+			if i >= len(adjacency) {
+				// Unknowns without off-diagonal entries have no neighbours
+				continue
+			}
+
+			for _, j := range adjacency[i] {
+				if mark[j] != id {
+					mark[j] = id
+					rows = append(rows, j)
+				}
+			}
+		}
+		front = rows[start:]
+	}
+	return rows
+}
+
+// factorize extracts the submatrix of A with the given rows and columns and
+// factorizes it with the local solver. The index of the subdomain is used in errors
+func factorize(A *sparse.CSR, rows []int, method LocalSolver, id int) (localSolver, error) {
+	raw := A.RawMatrix()
+	n := len(rows)
+
+	// The rows are sorted, thus the local index is found with a binary search
+	indptr := make([]int, 1, n+1)
+	ind := make([]int, 0)
+	data := make([]float64, 0)
+	for _, i := range rows {
+		for k := raw.Indptr[i]; k < raw.Indptr[i+1]; k++ {
+			if local, ok := slices.BinarySearch(rows, raw.Ind[k]); ok {
+				ind = append(ind, local)
+				data = append(data, raw.Data[k])
+			}
+		}
+		indptr = append(indptr, len(ind))
+	}
+	local := sparse.NewCSR(n, n, indptr, ind, data)
+
+	switch method {
+	case IChol:
+		ichol, err := precond.NewIChol(local)
+		return &ichol, err
+	case LU:
+		var lu mat.LU
+		lu.Factorize(mat.DenseCopyOf(local))
+		if lu.Cond() > mat.ConditionTolerance {
+			return nil, precond.ErrSingularBlock{Block: id}
+		}
+		return &lu, nil
+	default:
+		ilu, err := precond.NewILUZero(local)
+		return &ilu, err
+	}
+}
+
+// NumSubdomains returns the number of non-empty subdomains
+func (s *Preconditioner) NumSubdomains() int {
+	return len(s.subdomains)
+}
+
+// Clone returns a preconditioner that shares the local factorizations with the receiver,
+// but has its own work vectors, such that the clones can solve concurrently. Clone
+// modifies the receiver by creating the transposed local factors, thus it must not be
+// called concurrently with SolveVecTo
+func (s *Preconditioner) Clone() Preconditioner {
+	clone := *s
+	clone.subdomains = make([]subdomain, len(s.subdomains))
+	for d, sub := range s.subdomains {
+		n := len(sub.rows)
+		sub.rhs = mat.NewVecDense(n, nil)
+		sub.sol = mat.NewVecDense(n, nil)
+
+		// mat.LU does not store work vectors, thus it can be shared
+		if ilu, ok := sub.solver.(*precond.ILUPreconditioner); ok {
+			local := ilu.Clone()
+			sub.solver = &local
+		}
+		clone.subdomains[d] = sub
+	}
+	return clone
+}
+
+// SolveVecTo applies the Schwarz preconditioner to b. Each subdomain restricts b to
+// its unknowns and solves the local problem concurrently. The additive variant sums
+// the local solutions, while the restricted variant only keeps the local solution on
+// the unknowns in the original partition. When trans is true, the transpose of the
+// preconditioner is applied
+func (s *Preconditioner) SolveVecTo(dst *mat.VecDense, trans bool, rhs mat.Vector) error {
+	dstDim, _ := dst.Dims()
+	rhsDim, _ := rhs.Dims()
+	if dstDim != s.n || rhsDim != s.n {
+		return fmt.Errorf("expected lengths to be %d, got dst: %d and rhs: %d", s.n, dstDim, rhsDim)
+	}
+
+	// The transpose of the restricted variant restricts to the owned unknowns and
+	// extends the local solution to the full subdomain
+	restrictRhs := s.variant == Restricted && trans
+	restrictSol := s.variant == Restricted && !trans
+
+	numSub := len(s.subdomains)
+	err := parallel.For(numSub, parallel.Workers(numSub), func(_, d int) error {
+		sub := &s.subdomains[d]
+		for k, i := range sub.rows {
+			v := 0.0
+			if !restrictRhs || sub.owned[k] {
+				v = rhs.AtVec(i)
+			}
+			sub.rhs.SetVec(k, v)
+		}
+
+		if err := sub.solver.SolveVecTo(sub.sol, trans, sub.rhs); err != nil {
+			return fmt.Errorf("subdomain %d: %w", d, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	dst.Zero()
+	for d := range s.subdomains {
+		sub := &s.subdomains[d]
+		for k, i := range sub.rows {
+			if !restrictSol || sub.owned[k] {
+				dst.SetVec(i, dst.AtVec(i)+sub.sol.AtVec(k))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+package schwarz
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/davidkleiven/goprecond/precond"
+	"github.com/james-bowman/sparse"
+	"gonum.org/v1/exp/linsolve"
+	"gonum.org/v1/gonum/mat"
+)
+
+// poisson2D returns the 5-point finite difference discretization of the Poisson
+// equation on a n x n grid with Dirichlet boundary conditions. A positive convection
+// term makes the matrix non-symmetric
+func poisson2D(n int, convection float64) *sparse.CSR {
+	dok := sparse.NewDOK(n*n, n*n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			row := i*n + j
+			dok.Set(row, row, 4.0+convection)
+			if i > 0 {
+				dok.Set(row, row-n, -1.0)
+			}
+			if i < n-1 {
+				dok.Set(row, row+n, -1.0)
+			}
+			if j > 0 {
+				dok.Set(row, row-1, -1.0-convection)
+			}
+			if j < n-1 {
+				dok.Set(row, row+1, -1.0)
+			}
+		}
+	}
+	return dok.ToCSR()
+}
+
+func ones(n int) *mat.VecDense {
+	v := mat.NewVecDense(n, nil)
+	for i := 0; i < n; i++ {
+		v.SetVec(i, 1.0)
+	}
+	return v
+}
+
+// operator returns the preconditioner as a dense matrix by applying it to the unit vectors
+func operator(t *testing.T, s *Preconditioner, n int, trans bool) *mat.Dense {
+	result := mat.NewDense(n, n, nil)
+	unit := mat.NewVecDense(n, nil)
+	col := mat.NewVecDense(n, nil)
+	for j := 0; j < n; j++ {
+		unit.Zero()
+		unit.SetVec(j, 1.0)
+		if err := s.SolveVecTo(col, trans, unit); err != nil {
+			t.Fatalf("%v", err)
+		}
+		result.SetCol(j, col.RawVector().Data)
+	}
+	return result
+}
+
+func TestContiguousPartition(t *testing.T) {
+	parts := ContiguousPartition(10, 3)
+	want := [][]int{{0, 1, 2, 3}, {4, 5, 6}, {7, 8, 9}}
+	if len(parts) != len(want) {
+		t.Fatalf("Wanted %v got %v", want, parts)
+	}
+
+	for p := range want {
+		if !slices.Equal(parts[p], want[p]) {
+			t.Errorf("Wanted %v got %v", want, parts)
+		}
+	}
+
+	if parts := ContiguousPartition(2, 5); len(parts) != 2 {
+		t.Errorf("Expected one part per unknown, got %v", parts)
+	}
+}
+
+func TestOverlap(t *testing.T) {
+	A := poisson2D(4, 0.0)
+	for _, test := range []struct {
+		overlap int
+		want    []int
+	}{
+		{overlap: 0, want: []int{0, 1, 4, 5}},
+		{overlap: 1, want: []int{0, 1, 2, 4, 5, 6, 8, 9}},
+		{overlap: 2, want: []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 12, 13}},
+	} {
+		parts := [][]int{{0, 1, 4, 5}, {2, 3, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}}
+		s := Schwarz(A, parts, &Settings{Overlap: test.overlap, LocalSolver: LU})
+		if got := s.subdomains[0].rows; !slices.Equal(got, test.want) {
+			t.Errorf("Overlap %d: wanted %v got %v", test.overlap, test.want, got)
+		}
+
+		for k, i := range s.subdomains[0].rows {
+			if s.subdomains[0].owned[k] != slices.Contains(parts[0], i) {
+				t.Errorf("Overlap %d: wrong ownership of %d", test.overlap, i)
+			}
+		}
+	}
+}
+
+func TestZeroOverlapIsBlockJacobi(t *testing.T) {
+	A := poisson2D(5, 0.5)
+	parts := ContiguousPartition(25, 4)
+	blockJacobi := precond.BlockJacobi(A, parts)
+	want := mat.NewDense(25, 25, nil)
+	unit := mat.NewVecDense(25, nil)
+	col := mat.NewVecDense(25, nil)
+	for j := 0; j < 25; j++ {
+		unit.Zero()
+		unit.SetVec(j, 1.0)
+		if err := blockJacobi.SolveVecTo(col, false, unit); err != nil {
+			t.Fatalf("%v", err)
+		}
+		want.SetCol(j, col.RawVector().Data)
+	}
+
+	for _, variant := range []Variant{Additive, Restricted} {
+		s := Schwarz(A, parts, &Settings{Variant: variant, LocalSolver: LU})
+		if got := operator(t, &s, 25, false); !mat.EqualApprox(got, want, 1e-10) {
+			t.Errorf("Variant %d: wanted\n%v\ngot\n%v\n", variant, mat.Formatted(want), mat.Formatted(got))
+		}
+	}
+}
+
+func TestSingleSubdomainIsExact(t *testing.T) {
+	A := poisson2D(4, 0.5)
+	rhs := ones(16)
+	for _, variant := range []Variant{Additive, Restricted} {
+		s := Schwarz(A, ContiguousPartition(16, 1), &Settings{Variant: variant, LocalSolver: LU})
+		for _, trans := range []bool{false, true} {
+			x := mat.NewVecDense(16, nil)
+			if err := s.SolveVecTo(x, trans, rhs); err != nil {
+				t.Fatalf("%v", err)
+			}
+
+			got := mat.NewVecDense(16, nil)
+			if trans {
+				got.MulVec(A.T(), x)
+			} else {
+				got.MulVec(A, x)
+			}
+
+			if !mat.EqualApprox(got, rhs, 1e-10) {
+				t.Errorf("Variant %d trans %v: wanted\n%v\ngot\n%v\n", variant, trans, mat.Formatted(rhs), mat.Formatted(got))
+			}
+		}
+	}
+}
+
+func TestTranspose(t *testing.T) {
+	A := poisson2D(5, 0.5)
+	parts := ContiguousPartition(25, 3)
+	for _, variant := range []Variant{Additive, Restricted} {
+		for _, local := range []LocalSolver{ILUZero, LU} {
+			s := Schwarz(A, parts, &Settings{Overlap: 1, Variant: variant, LocalSolver: local})
+			m := operator(t, &s, 25, false)
+			mT := operator(t, &s, 25, true)
+			if !mat.EqualApprox(m.T(), mT, 1e-10) {
+				t.Errorf("Variant %d local solver %d: the transposed preconditioner does not match", variant, local)
+			}
+		}
+	}
+}
+
+func TestCloneSolvesConcurrently(t *testing.T) {
+	A := poisson2D(5, 0.5)
+	parts := ContiguousPartition(25, 3)
+	for _, local := range []LocalSolver{ILUZero, LU} {
+		s := Schwarz(A, parts, &Settings{Overlap: 1, Variant: Restricted, LocalSolver: local})
+		want := operator(t, &s, 25, false)
+		wantT := operator(t, &s, 25, true)
+
+		// Each goroutine applies its clone to the unit vectors, alternating between the
+		// preconditioner and its transpose
+		const numWorkers = 4
+		results := make([]*mat.Dense, numWorkers)
+		errs := make([]error, numWorkers)
+		var wg sync.WaitGroup
+		for w := 0; w < numWorkers; w++ {
+			clone := s.Clone()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[w] = mat.NewDense(25, 25, nil)
+				unit := mat.NewVecDense(25, nil)
+				col := mat.NewVecDense(25, nil)
+				for j := 0; j < 25; j++ {
+					unit.Zero()
+					unit.SetVec(j, 1.0)
+					if err := clone.SolveVecTo(col, (j+w)%2 == 1, unit); err != nil {
+						errs[w] = err
+						return
+					}
+					results[w].SetCol(j, col.RawVector().Data)
+				}
+			}()
+		}
+		wg.Wait()
+
+		for w := 0; w < numWorkers; w++ {
+			if errs[w] != nil {
+				t.Fatalf("Local solver %d: %v", local, errs[w])
+			}
+			for j := 0; j < 25; j++ {
+				expect := want.ColView(j)
+				if (j+w)%2 == 1 {
+					expect = wantT.ColView(j)
+				}
+				if !mat.EqualApprox(results[w].ColView(j), expect, 1e-12) {
+					t.Errorf("Local solver %d worker %d: column %d differs from the serial solve", local, w, j)
+				}
+			}
+		}
+	}
+}
+
+func TestAdditiveIsSymmetric(t *testing.T) {
+	A := poisson2D(6, 0.0)
+	parts := ContiguousPartition(36, 4)
+	for _, local := range []LocalSolver{IChol, LU} {
+		s := Schwarz(A, parts, &Settings{Overlap: 2, LocalSolver: local})
+		m := operator(t, &s, 36, false)
+		if !mat.EqualApprox(m, m.T(), 1e-10) {
+			t.Errorf("Local solver %d: expected a symmetric preconditioner", local)
+		}
+	}
+}
+
+func TestOverlapImprovesConvergence(t *testing.T) {
+	n := 24
+	A := poisson2D(n, 0.0)
+	matrix := precond.NewCSRMulVecToer(A)
+	parts := ContiguousPartition(n*n, 8)
+
+	for _, test := range []struct {
+		variant Variant
+		method  linsolve.Method
+		desc    string
+	}{
+		{variant: Additive, method: &linsolve.CG{}, desc: "additive with CG"},
+		{variant: Restricted, method: &linsolve.BiCGStab{}, desc: "restricted with BiCGStab"},
+	} {
+		iterations := make([]int, 0)
+		for _, overlap := range []int{0, 1, 3} {
+			s := Schwarz(A, parts, &Settings{Overlap: overlap, Variant: test.variant, LocalSolver: IChol})
+			settings := &linsolve.Settings{PreconSolve: s.SolveVecTo, MaxIterations: 500}
+			result, err := linsolve.Iterative(&matrix, ones(n*n), test.method, settings)
+			if err != nil {
+				t.Fatalf("%s overlap %d: %v", test.desc, overlap, err)
+			}
+			iterations = append(iterations, result.Stats.Iterations)
+		}
+
+		for i := 1; i < len(iterations); i++ {
+			if iterations[i] >= iterations[i-1] {
+				t.Errorf("%s: expected fewer iterations with more overlap. Got %v", test.desc, iterations)
+			}
+		}
+	}
+}
+
+func TestSchwarzErrors(t *testing.T) {
+	A := poisson2D(3, 0.0)
+	for _, test := range []struct {
+		parts    [][]int
+		settings *Settings
+		desc     string
+	}{
+		{parts: [][]int{{0, 1, 2, 3}, {4, 5, 6, 7}}, desc: "missing row"},
+		{parts: [][]int{{0, 1, 2, 3, 4}, {4, 5, 6, 7, 8}}, desc: "duplicate row"},
+		{parts: [][]int{{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}}, desc: "out of range"},
+		{parts: ContiguousPartition(9, 2), settings: &Settings{Overlap: -1}, desc: "negative overlap"},
+		{parts: ContiguousPartition(9, 2), settings: &Settings{LocalSolver: LU + 1}, desc: "unknown solver"},
+	} {
+		if _, err := NewSchwarz(A, test.parts, test.settings); !errors.Is(err, precond.ErrInvalidArgument) {
+			t.Errorf("%s: wanted %v got %v", test.desc, precond.ErrInvalidArgument, err)
+		}
+	}
+
+	nonSquare := sparse.NewCSR(2, 3, []int{0, 0, 0}, nil, nil)
+	if _, err := NewSchwarz(nonSquare, nil, nil); !errors.Is(err, precond.ErrNotSquare{Rows: 2, Cols: 3}) {
+		t.Errorf("Wanted %v got %v", precond.ErrNotSquare{Rows: 2, Cols: 3}, err)
+	}
+
+	// The second subdomain is singular
+	singular := sparse.NewCSR(3, 3, []int{0, 1, 3, 5}, []int{0, 1, 2, 1, 2}, []float64{1.0, 1.0, 1.0, 1.0, 1.0})
+	parts := [][]int{{0}, {1, 2}}
+	if _, err := NewSchwarz(singular, parts, &Settings{LocalSolver: LU}); !errors.Is(err, precond.ErrSingularBlock{Block: 1}) {
+		t.Errorf("Wanted %v got %v", precond.ErrSingularBlock{Block: 1}, err)
+	}
+
+	zeroDiag := sparse.NewCSR(2, 2, []int{0, 2, 3}, []int{0, 1, 0}, []float64{1.0, 1.0, 1.0})
+	if _, err := NewSchwarz(zeroDiag, ContiguousPartition(2, 1), nil); !errors.Is(err, precond.ErrZeroPivot{Row: 1}) {
+		t.Errorf("Wanted %v got %v", precond.ErrZeroPivot{Row: 1}, err)
+	}
+}
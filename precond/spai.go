@@ -0,0 +1,168 @@
+package precond
+
+import (
+	"slices"
+
+	"github.com/davidkleiven/goprecond/precond/internal/parallel"
+	"github.com/james-bowman/sparse"
+	"gonum.org/v1/gonum/mat"
+)
+
+// SPAIPreconditioner is a sparse approximate inverse M of A. Since M approximates
+// the inverse directly, applying the preconditioner is a sparse matrix-vector
+// product, and no triangular solves are needed. It is not safe for concurrent use, see
+// Clone
+type SPAIPreconditioner struct {
+	m  *sparse.CSR
+	mT *sparse.CSR
+
+	// Work vector used by SolveVecTo. It is nil if A is empty
+	product *mat.VecDense
+}
+
+// spaiWorkspace holds the scratch memory used by one goroutine
+type spaiWorkspace struct {
+	// Local index of each row in the least squares problem, -1 if not present
+	local []int
+	rows  []int
+}
+
+// SPAI calculates a sparse approximate inverse M of A that minimizes the Frobenius
+// norm ||AM - I||. The pattern of M is the pattern of A plus the diagonal. Since the
+// Frobenius norm decouples into one least squares problem per column of M, the
+// columns are calculated concurrently.
+// The method panics if A is not square or if any of the least squares problems are
+// singular. Use NewSPAI to get an error instead
+func SPAI(A *sparse.CSR) SPAIPreconditioner {
+	spai, err := NewSPAI(A)
+	if err != nil {
+		panic(err)
+	}
+	return spai
+}
+
+// NewSPAI is the same as SPAI, except that it returns an error instead of panicking.
+// ErrNotSquare is returned if A is not square and ErrSingularColumn if the least
+// squares problem for a column is singular
+func NewSPAI(A *sparse.CSR) (SPAIPreconditioner, error) {
+	if err := checkSquare(A); err != nil {
+		return SPAIPreconditioner{}, err
+	}
+	n, _ := A.Dims()
+
+	// Row k of the transpose is column k of A
+	columnsOfA := transposeCSR(A).RawMatrix()
+	columns := make([]sparseRow, n)
+
+	workers := parallel.Workers(n)
+	workspaces := make([]spaiWorkspace, workers)
+	for w := range workspaces {
+		workspaces[w].local = make([]int, n)
+		for i := range workspaces[w].local {
+			workspaces[w].local[i] = -1
+		}
+	}
+
+	err := parallel.For(n, workers, func(worker, j int) error {
+		ws := &workspaces[worker]
+
+		// Pattern of column j of M
+		start, end := columnsOfA.Indptr[j], columnsOfA.Indptr[j+1]
+		pattern := slices.Clone(columnsOfA.Ind[start:end])
+		if idx, found := slices.BinarySearch(pattern, j); !found {
+			pattern = slices.Insert(pattern, idx, j)
+		}
+
+		// Rows of A that have non-zero entries in the columns of the pattern
+		ws.rows = ws.rows[:0]
+		for _, c := range pattern {
+			for k := columnsOfA.Indptr[c]; k < columnsOfA.Indptr[c+1]; k++ {
+				if i := columnsOfA.Ind[k]; ws.local[i] == -1 {
+					ws.local[i] = len(ws.rows)
+					ws.rows = append(ws.rows, i)
+				}
+			}
+		}
+		defer func() {
+			for _, i := range ws.rows {
+				ws.local[i] = -1
+			}
+		}()
+
+		if len(ws.rows) < len(pattern) {
+			return ErrSingularColumn{Column: j}
+		}
+
+		sub := mat.NewDense(len(ws.rows), len(pattern), nil)
+		for q, c := range pattern {
+			for k := columnsOfA.Indptr[c]; k < columnsOfA.Indptr[c+1]; k++ {
+				sub.Set(ws.local[columnsOfA.Ind[k]], q, columnsOfA.Data[k])
+			}
+		}
+
+		rhs := mat.NewVecDense(len(ws.rows), nil)
+		if idx := ws.local[j]; idx != -1 {
+			rhs.SetVec(idx, 1.0)
+		}
+
+		var qr mat.QR
+		qr.Factorize(sub)
+		if qr.Cond() > mat.ConditionTolerance {
+			return ErrSingularColumn{Column: j}
+		}
+
+		solution := mat.NewVecDense(len(pattern), nil)
+		if err := qr.SolveVecTo(solution, false, rhs); err != nil {
+			return ErrSingularColumn{Column: j}
+		}
+
+		columns[j] = sparseRow{cols: pattern, values: solution.RawVector().Data}
+		return nil
+	})
+	if err != nil {
+		return SPAIPreconditioner{}, err
+	}
+
+	// Column j of M is row j of the transpose
+	mT := rowsToCSR(columns)
+	spai := SPAIPreconditioner{
+		m:  transposeCSR(mT),
+		mT: mT,
+	}
+	if n > 0 {
+		spai.product = mat.NewVecDense(n, nil)
+	}
+	return spai, nil
+}
+
+// Clone returns a preconditioner that shares the approximate inverse with the receiver,
+// but has its own work vector, such that the clones can solve concurrently
+func (s *SPAIPreconditioner) Clone() SPAIPreconditioner {
+	clone := *s
+	if s.product != nil {
+		clone.product = mat.NewVecDense(s.product.Len(), nil)
+	}
+	return clone
+}
+
+// SolveVecTo calculates x = Mb, where M is the approximate inverse of A. dst and rhs
+// may share storage. The product is stored in a work vector in the preconditioner, so
+// the method must not be called concurrently on the same preconditioner (or copies of it).
+// Use Clone instead
+func (s *SPAIPreconditioner) SolveVecTo(dst *mat.VecDense, trans bool, rhs mat.Vector) error {
+	n, _ := s.m.Dims()
+	if err := checkVecDims(n, dst, rhs); err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+
+	if trans {
+		csrMulVec(s.product, s.mT, rhs)
+	} else {
+		csrMulVec(s.product, s.m, rhs)
+	}
+	dst.CopyVec(s.product)
+	return nil
+}
@@ -0,0 +1,120 @@
+package precond
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/davidkleiven/goprecond/precond/property"
+	"gonum.org/v1/exp/linsolve"
+	"gonum.org/v1/gonum/mat"
+	"pgregory.net/rapid"
+)
+
+func TestSPAIIsExactForDenseMatrices(t *testing.T) {
+	for _, trans := range []bool{true, false} {
+		t.Run(fmt.Sprintf("trans %v", trans), func(t *testing.T) {
+			n := 10
+			tc := randomTestCase(n)
+			spai := SPAI(denseToCSR(tc.matrix))
+
+			result := mat.NewVecDense(n, nil)
+			if err := spai.SolveVecTo(result, trans, tc.rhs); err != nil {
+				t.Errorf("%v", err)
+				return
+			}
+
+			got := mat.NewVecDense(n, nil)
+			if trans {
+				got.MulVec(tc.matrix.T(), result)
+			} else {
+				got.MulVec(tc.matrix, result)
+			}
+
+			if !mat.EqualApprox(got, tc.rhs, 1e-8) {
+				t.Errorf("Wanted\n%v\ngot\n%v\n", mat.Formatted(tc.rhs), mat.Formatted(got))
+			}
+		})
+	}
+}
+
+func TestSPAIBetterThanJacobi(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		matrix := property.SparseSymmetricMatrix(t, 2, 30)
+		n := matrix.SymmetricDim()
+		for i := 0; i < n; i++ {
+			matrix.SetSym(i, i, matrix.At(i, i)+float64(n))
+		}
+
+		spai, err := NewSPAI(denseToCSR(matrix))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		// The diagonal is part of the pattern, so the Frobenius norm of the
+		// residual can not be larger than for the inverse of the diagonal
+		jacobi := mat.NewDense(n, n, nil)
+		for i := 0; i < n; i++ {
+			jacobi.Set(i, i, 1.0/matrix.At(i, i))
+		}
+
+		identity := mat.NewDiagDense(n, nil)
+		for i := 0; i < n; i++ {
+			identity.SetDiag(i, 1.0)
+		}
+
+		var spaiResidual, jacobiResidual mat.Dense
+		spaiResidual.Mul(matrix, spai.m)
+		spaiResidual.Sub(&spaiResidual, identity)
+		jacobiResidual.Mul(matrix, jacobi)
+		jacobiResidual.Sub(&jacobiResidual, identity)
+
+		if mat.Norm(&spaiResidual, 2) > mat.Norm(&jacobiResidual, 2)+1e-10 {
+			t.Fatalf("Residual of SPAI %v larger than for Jacobi %v", mat.Norm(&spaiResidual, 2), mat.Norm(&jacobiResidual, 2))
+		}
+	})
+}
+
+func TestSPAISolveInPlace(t *testing.T) {
+	tc := randomSymmetricTestCase(20)
+	spai := SPAI(denseToCSR(tc.matrix))
+
+	for _, trans := range []bool{false, true} {
+		want := mat.NewVecDense(20, nil)
+		if err := spai.SolveVecTo(want, trans, tc.rhs); err != nil {
+			t.Fatal(err)
+		}
+
+		got := mat.VecDenseCopyOf(tc.rhs)
+		if err := spai.SolveVecTo(got, trans, got); err != nil {
+			t.Fatal(err)
+		}
+
+		if !mat.Equal(got, want) {
+			t.Errorf("trans=%v: wanted\n%v\ngot\n%v", trans, mat.Formatted(want.T()), mat.Formatted(got.T()))
+		}
+	}
+}
+
+func TestSPAIErrors(t *testing.T) {
+	zeroColumn := denseToCSR(mat.NewDense(3, 3, []float64{
+		1.0, 0.0, 0.0,
+		0.0, 0.0, 0.0,
+		0.0, 0.0, 1.0,
+	}))
+
+	if _, err := NewSPAI(zeroColumn); !errors.Is(err, ErrSingularColumn{Column: 1}) {
+		t.Errorf("Wanted %v got %v", ErrSingularColumn{Column: 1}, err)
+	}
+}
+
+func TestSPAIWithLinsolve(t *testing.T) {
+	tc := randomSymmetricTestCase(50)
+	matrix := NewCSRMulVecToer(denseToCSR(tc.matrix))
+	spai := SPAI(matrix.Matrix)
+
+	settings := &linsolve.Settings{PreconSolve: spai.SolveVecTo}
+	if _, err := linsolve.Iterative(&matrix, tc.rhs, &linsolve.GMRES{}, settings); err != nil {
+		t.Errorf("%v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package precond
+
+import (
+	"fmt"
+
+	"github.com/james-bowman/sparse"
+	"gonum.org/v1/gonum/mat"
+)
+
+// SSORPreconditioner is the symmetric successive over-relaxation preconditioner
+//
+// M = omega/(2-omega) * (D/omega + L) * (D/omega)^{-1} * (D/omega + U)
+//
+// where D is the diagonal, L the strictly lower triangular part and U the strictly
+// upper triangular part of A. No factorization is needed, so the preconditioner is
+// cheap to build compared to the incomplete factorizations. It is not safe for concurrent
+// use, see Clone
+type SSORPreconditioner struct {
+	// D/omega + L and D/omega + U
+	lower  *sparse.CSR
+	upper  *sparse.CSR
+	lowerT *sparse.CSR
+	upperT *sparse.CSR
+
+	// Index of the diagonal in each row of the triangular parts and of their transposes
+	lowerDiag  []int
+	upperDiag  []int
+	lowerTDiag []int
+	upperTDiag []int
+
+	// Diagonal of D/omega
+	diag []float64
+
+	// The solution is multiplied by (2-omega)/omega
+	scale float64
+
+	// Work vector used by SolveVecTo
+	work []float64
+}
+
+// SSOR creates the symmetric successive over-relaxation preconditioner of A with
+// relaxation parameter omega, which must be in the open interval (0, 2).
+// The method panics if A is not square, omega is out of range or the diagonal
+// contains zeros. Use NewSSOR to get an error instead
+func SSOR(A *sparse.CSR, omega float64) SSORPreconditioner {
+	ssor, err := NewSSOR(A, omega)
+	if err != nil {
+		panic(err)
+	}
+	return ssor
+}
+
+// NewSSOR is the same as SSOR, except that it returns an error instead of panicking.
+// ErrNotSquare is returned if A is not square, ErrInvalidArgument if omega is not in
+// (0, 2) and ErrZeroPivot if a diagonal entry is smaller than 1e-8 in absolute value
+func NewSSOR(A *sparse.CSR, omega float64) (SSORPreconditioner, error) {
+	if err := checkSquare(A); err != nil {
+		return SSORPreconditioner{}, err
+	}
+
+	if !(omega > 0.0 && omega < 2.0) {
+		return SSORPreconditioner{}, fmt.Errorf("%w: omega must be in (0, 2), got %v", ErrInvalidArgument, omega)
+	}
+
+	n, _ := A.Dims()
+	raw := A.RawMatrix()
+
+	// The diagonal is stored as the last entry in each row of the lower part
+	// and as the first entry in each row of the upper part
+	diag := make([]float64, n)
+	lowerIndptr := make([]int, n+1)
+	upperIndptr := make([]int, n+1)
+	for i := 0; i < n; i++ {
+		lowerIndptr[i+1] = lowerIndptr[i] + 1
+		upperIndptr[i+1] = upperIndptr[i] + 1
+		for k := raw.Indptr[i]; k < raw.Indptr[i+1]; k++ {
+			switch j := raw.Ind[k]; {
+			case j < i:
+				lowerIndptr[i+1]++
+			case j > i:
+				upperIndptr[i+1]++
+			default:
+				diag[i] += raw.Data[k]
+			}
+		}
+	}
+
+	for i, v := range diag {
+		if isZeroPivot(v) {
+			return SSORPreconditioner{}, ErrZeroPivot{Row: i, Value: v}
+		}
+		diag[i] = v / omega
+	}
+
+	lowerInd := make([]int, lowerIndptr[n])
+	lowerData := make([]float64, lowerIndptr[n])
+	upperInd := make([]int, upperIndptr[n])
+	upperData := make([]float64, upperIndptr[n])
+	for i := 0; i < n; i++ {
+		l, u := lowerIndptr[i], upperIndptr[i]
+		upperInd[u] = i
+		upperData[u] = diag[i]
+		u++
+
+		for k := raw.Indptr[i]; k < raw.Indptr[i+1]; k++ {
+			switch j := raw.Ind[k]; {
+			case j < i:
+				lowerInd[l] = j
+				lowerData[l] = raw.Data[k]
+				l++
+			case j > i:
+				upperInd[u] = j
+				upperData[u] = raw.Data[k]
+				u++
+			}
+		}
+		lowerInd[l] = i
+		lowerData[l] = diag[i]
+	}
+
+	lowerDiag := make([]int, n)
+	upperDiag := make([]int, n)
+	for i := 0; i < n; i++ {
+		lowerDiag[i] = lowerIndptr[i+1] - 1
+		upperDiag[i] = upperIndptr[i]
+	}
+
+	return SSORPreconditioner{
+		lower:     sparse.NewCSR(n, n, lowerIndptr, lowerInd, lowerData),
+		upper:     sparse.NewCSR(n, n, upperIndptr, upperInd, upperData),
+		lowerDiag: lowerDiag,
+		upperDiag: upperDiag,
+		diag:      diag,
+		scale:     (2.0 - omega) / omega,
+		work:      make([]float64, n),
+	}, nil
+}
+
+// SymmetricGaussSeidel creates the symmetric Gauss-Seidel preconditioner of A, which
+// is the same as SSOR with omega = 1.
+// The method panics if A is not square or the diagonal contains zeros.
+// Use NewSymmetricGaussSeidel to get an error instead
+func SymmetricGaussSeidel(A *sparse.CSR) SSORPreconditioner {
+	return SSOR(A, 1.0)
+}
+
+// NewSymmetricGaussSeidel is the same as SymmetricGaussSeidel, except that it returns
+// an error instead of panicking
+func NewSymmetricGaussSeidel(A *sparse.CSR) (SSORPreconditioner, error) {
+	return NewSSOR(A, 1.0)
+}
+
+func (s *SSORPreconditioner) initT() {
+	if s.lowerT != nil && s.upperT != nil {
+		// Already initialized
+		return
+	}
+	s.lowerT = transposeCSR(s.lower)
+	s.upperT = transposeCSR(s.upper)
+	s.lowerTDiag = diagonalIndices(s.lowerT)
+	s.upperTDiag = diagonalIndices(s.upperT)
+}
+
+// Clone returns a preconditioner that shares the sweeps with the receiver, but has its
+// own work vector, such that the clones can solve concurrently. Clone modifies the receiver
+// by creating the transposed sweeps, thus it must not be called concurrently with SolveVecTo
+func (s *SSORPreconditioner) Clone() SSORPreconditioner {
+	if s.lower == nil {
+		return SSORPreconditioner{}
+	}
+	s.initT()
+	clone := *s
+	clone.work = make([]float64, len(s.work))
+	return clone
+}
+
+// SolveVecTo solves the linear system of equation given by Mx = b
+// using a forward and a backward triangular sweep. As for ILUPreconditioner, no memory
+// is allocated after the first transposed solve, and SolveVecTo must not be called
+// concurrently. Use Clone to get a preconditioner for each goroutine
+func (s *SSORPreconditioner) SolveVecTo(dst *mat.VecDense, trans bool, rhs mat.Vector) error {
+	n := len(s.diag)
+	if err := checkVecDims(n, dst, rhs); err != nil {
+		return err
+	}
+
+	lower, upper := s.lower, s.upper
+	lowerDiag, upperDiag := s.lowerDiag, s.upperDiag
+	if trans {
+		// Initialize the transposed matrices on request
+		s.initT()
+		lower, upper = s.upperT, s.lowerT
+		lowerDiag, upperDiag = s.upperTDiag, s.lowerTDiag
+	}
+
+	copyFromVec(s.work, rhs)
+	forwardSubstitution(lower, lowerDiag, s.work)
+	for i, d := range s.diag {
+		s.work[i] *= d
+	}
+	backwardSubstitution(upper, upperDiag, s.work)
+	copyToVec(dst, s.work)
+	dst.ScaleVec(s.scale, dst)
+	return nil
+}
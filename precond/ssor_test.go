@@ -0,0 +1,137 @@
+package precond
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/james-bowman/sparse"
+	"gonum.org/v1/exp/linsolve"
+	"gonum.org/v1/gonum/mat"
+)
+
+// denseSSOR builds the SSOR matrix omega/(2-omega) * (D/omega + L) * (D/omega)^{-1} * (D/omega + U)
+func denseSSOR(matrix *mat.Dense, omega float64) *mat.Dense {
+	n, _ := matrix.Dims()
+	lower := mat.NewDense(n, n, nil)
+	upper := mat.NewDense(n, n, nil)
+	invDiag := mat.NewDense(n, n, nil)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			v := matrix.At(i, j)
+			switch {
+			case j < i:
+				lower.Set(i, j, v)
+			case j > i:
+				upper.Set(i, j, v)
+			default:
+				lower.Set(i, i, v/omega)
+				upper.Set(i, i, v/omega)
+				invDiag.Set(i, i, omega/v)
+			}
+		}
+	}
+
+	result := mat.NewDense(n, n, nil)
+	result.Product(lower, invDiag, upper)
+	result.Scale(omega/(2.0-omega), result)
+	return result
+}
+
+func TestSSORSolution(t *testing.T) {
+	for _, trans := range []bool{true, false} {
+		for _, omega := range []float64{0.5, 1.0, 1.5} {
+			t.Run(fmt.Sprintf("trans %v omega %v", trans, omega), func(t *testing.T) {
+				n := 15
+				tc := randomTestCase(n)
+				for i := 0; i < n; i++ {
+					tc.matrix.Set(i, i, tc.matrix.At(i, i)+10.0)
+				}
+
+				ssor := SSOR(denseToCSR(tc.matrix), omega)
+				result := mat.NewVecDense(n, nil)
+				if err := ssor.SolveVecTo(result, trans, tc.rhs); err != nil {
+					t.Errorf("%v", err)
+					return
+				}
+
+				m := denseSSOR(tc.matrix, omega)
+				got := mat.NewVecDense(n, nil)
+				if trans {
+					got.MulVec(m.T(), result)
+				} else {
+					got.MulVec(m, result)
+				}
+
+				if !mat.EqualApprox(got, tc.rhs, 1e-8) {
+					t.Errorf("Wanted\n%v\ngot\n%v\n", mat.Formatted(tc.rhs), mat.Formatted(got))
+				}
+			})
+		}
+	}
+}
+
+func TestSymmetricGaussSeidelIsExactForTriangular(t *testing.T) {
+	// For a lower triangular matrix, U = 0 and M = (D + L) D^{-1} D = A
+	dok := sparse.NewDOK(3, 3)
+	dok.Set(0, 0, 2.0)
+	dok.Set(1, 0, 1.0)
+	dok.Set(1, 1, 4.0)
+	dok.Set(2, 0, -1.0)
+	dok.Set(2, 2, 5.0)
+	matrix := dok.ToCSR()
+
+	sgs := SymmetricGaussSeidel(matrix)
+	rhs := mat.NewVecDense(3, []float64{2.0, 5.0, 4.0})
+	want := mat.NewVecDense(3, []float64{1.0, 1.0, 1.0})
+
+	result := mat.NewVecDense(3, nil)
+	if err := sgs.SolveVecTo(result, false, rhs); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if !mat.EqualApprox(result, want, 1e-12) {
+		t.Errorf("Wanted\n%v\ngot\n%v\n", mat.Formatted(want), mat.Formatted(result))
+	}
+}
+
+func TestSSORErrors(t *testing.T) {
+	zeroDiag := denseToCSR(mat.NewDense(2, 2, []float64{1.0, 1.0, 1.0, 0.0}))
+	if _, err := NewSymmetricGaussSeidel(zeroDiag); !errors.Is(err, ErrZeroPivot{Row: 1}) {
+		t.Errorf("Wanted %v got %v", ErrZeroPivot{Row: 1}, err)
+	}
+
+	tinyDiag := denseToCSR(mat.NewDense(2, 2, []float64{1.0, 1.0, 1.0, 1e-12}))
+	if _, err := NewSymmetricGaussSeidel(tinyDiag); !errors.Is(err, ErrZeroPivot{Row: 1, Value: 1e-12}) {
+		t.Errorf("Wanted %v got %v", ErrZeroPivot{Row: 1, Value: 1e-12}, err)
+	}
+
+	identity := denseToCSR(mat.NewDense(2, 2, []float64{1.0, 0.0, 0.0, 1.0}))
+	for _, omega := range []float64{0.0, 2.0, -1.0} {
+		if _, err := NewSSOR(identity, omega); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("omega %v: wanted %v got %v", omega, ErrInvalidArgument, err)
+		}
+	}
+
+	nonSquare := sparse.NewCSR(2, 3, []int{0, 0, 0}, nil, nil)
+	if _, err := NewSSOR(nonSquare, 1.0); !errors.Is(err, ErrNotSquare{Rows: 2, Cols: 3}) {
+		t.Errorf("Wanted %v got %v", ErrNotSquare{Rows: 2, Cols: 3}, err)
+	}
+}
+
+func TestSSORWithLinsolve(t *testing.T) {
+	tc := randomSymmetricTestCase(50)
+	matrix := NewCSRMulVecToer(denseToCSR(tc.matrix))
+	ssor := SSOR(matrix.Matrix, 1.2)
+	sgs := SymmetricGaussSeidel(matrix.Matrix)
+
+	for _, settings := range []*linsolve.Settings{
+		{PreconSolve: ssor.SolveVecTo},
+		{PreconSolve: sgs.SolveVecTo},
+	} {
+		if _, err := linsolve.Iterative(&matrix, tc.rhs, &linsolve.CG{}, settings); err != nil {
+			t.Errorf("%v", err)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package precond
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/james-bowman/sparse"
+)
+
+// transposedPattern is the sparsity pattern of the transpose of a CSR matrix.
+// The value stored at index k in the original matrix is stored at index idx[k]
+// in the transposed matrix
+type transposedPattern struct {
+	indptr []int
+	ind    []int
+	idx    []int
+}
+
+// transposePattern calculates the pattern of the transpose of a matrix with the given
+// number of rows and columns. The columns in each row of the transpose are sorted
+func transposePattern(rows, cols int, indptr []int, ind []int) transposedPattern {
+	t := transposedPattern{
+		indptr: make([]int, cols+1),
+		ind:    make([]int, len(ind)),
+		idx:    make([]int, len(ind)),
+	}
+
+	for _, j := range ind {
+		t.indptr[j+1]++
+	}
+	for j := 0; j < cols; j++ {
+		t.indptr[j+1] += t.indptr[j]
+	}
+
+	next := slices.Clone(t.indptr[:cols])
+	for i := 0; i < rows; i++ {
+		for k := indptr[i]; k < indptr[i+1]; k++ {
+			j := ind[k]
+			t.ind[next[j]] = i
+			t.idx[k] = next[j]
+			next[j]++
+		}
+	}
+	return t
+}
+
+// scatterTranspose copies the values of a matrix into the values of its transpose
+func (t *transposedPattern) scatterTranspose(dst, src []float64) {
+	for k, v := range src {
+		dst[t.idx[k]] = v
+	}
+}
+
+// Symbolic holds the result of the symbolic analysis of an incomplete factorization.
+// The analysis depends only on the sparsity pattern of the matrix, and can therefore
+// be reused to factorize all matrices sharing the same pattern. This is useful when
+// the same system is refactored many times with different values (e.g. in each step
+// of Newton's method)
+type Symbolic struct {
+	n        int
+	pattern  iluPattern
+	cholesky bool
+
+	// Pattern of the transposed factors
+	lowerT transposedPattern
+	upperT transposedPattern
+}
+
+// AnalyzeILUK performs the symbolic analysis of the ILU(k) factorization of A.
+// The method panics if A is not square or k is negative. Use NewSymbolicILUK to
+// get an error instead
+func AnalyzeILUK(A ZeroAwareMatrix, k int) *Symbolic {
+	symbolic, err := NewSymbolicILUK(A, k)
+	if err != nil {
+		panic(err)
+	}
+	return symbolic
+}
+
+// NewSymbolicILUK is the same as AnalyzeILUK, except that it returns an error
+// instead of panicking
+func NewSymbolicILUK(A ZeroAwareMatrix, k int) (*Symbolic, error) {
+	if err := checkSquare(A); err != nil {
+		return nil, err
+	}
+
+	if err := checkFillLevel(k); err != nil {
+		return nil, err
+	}
+	return analyzeILUKRows(collectRows(A), k), nil
+}
+
+func checkFillLevel(k int) error {
+	if k < 0 {
+		return fmt.Errorf("%w: the level of fill must be non-negative, got %d", ErrInvalidArgument, k)
+	}
+	return nil
+}
+
+// analyzeILUKRows performs the symbolic analysis of the ILU(k) factorization of the
+// matrix with the given rows
+func analyzeILUKRows(rows []sparseRow, k int) *Symbolic {
+	nrows := len(rows)
+	pattern := make([][]int, nrows)
+	for i, row := range rows {
+		pattern[i] = row.cols
+	}
+
+	luPattern := symbolicILUK(pattern, k)
+	return &Symbolic{
+		n:       nrows,
+		pattern: luPattern,
+		lowerT:  transposePattern(nrows, nrows, luPattern.lowerIndptr, luPattern.lowerInd),
+		upperT:  transposePattern(nrows, nrows, luPattern.upperIndptr, luPattern.upperInd),
+	}
+}
+
+// AnalyzeIChol performs the symbolic analysis of the incomplete cholesky factorization
+// of A. Only the lower triangular part of A is used.
+// The method panics if A is not square. Use NewSymbolicIChol to get an error instead
+func AnalyzeIChol(A ZeroAwareMatrix) *Symbolic {
+	symbolic, err := NewSymbolicIChol(A)
+	if err != nil {
+		panic(err)
+	}
+	return symbolic
+}
+
+// NewSymbolicIChol is the same as AnalyzeIChol, except that it returns an error
+// instead of panicking
+func NewSymbolicIChol(A ZeroAwareMatrix) (*Symbolic, error) {
+	if err := checkSquare(A); err != nil {
+		return nil, err
+	}
+	return analyzeICholRows(collectRows(A)), nil
+}
+
+// analyzeICholRows performs the symbolic analysis of the incomplete cholesky
+// factorization of the matrix with the given rows
+func analyzeICholRows(rows []sparseRow) *Symbolic {
+	nrows := len(rows)
+	lowerIndptr := make([]int, 1, nrows+1)
+	lowerInd := make([]int, 0)
+	for i, row := range rows {
+		for _, j := range row.cols {
+			if j < i {
+				lowerInd = append(lowerInd, j)
+			}
+		}
+		lowerInd = append(lowerInd, i)
+		lowerIndptr = append(lowerIndptr, len(lowerInd))
+	}
+
+	// U = L^T, so the pattern of U is the pattern of the transpose of L
+	lowerT := transposePattern(nrows, nrows, lowerIndptr, lowerInd)
+	return &Symbolic{
+		n: nrows,
+		pattern: iluPattern{
+			lowerIndptr: lowerIndptr,
+			lowerInd:    lowerInd,
+			upperIndptr: lowerT.indptr,
+			upperInd:    lowerT.ind,
+		},
+		cholesky: true,
+		lowerT:   lowerT,
+	}
+}
+
+// Factorize calculates the numeric factorization of A using the sparsity pattern
+// from the symbolic analysis. All non-zero entries of A must be part of the pattern
+// used in the analysis, otherwise ErrInvalidArgument is returned. ErrZeroPivot is
+// returned if the factorization breaks down
+func (s *Symbolic) Factorize(A ZeroAwareMatrix) (ILUPreconditioner, error) {
+	if err := s.checkDims(A); err != nil {
+		return ILUPreconditioner{}, err
+	}
+
+	ilu := s.allocate()
+	if err := s.numeric(A, ilu.lower.RawMatrix().Data, ilu.upper.RawMatrix().Data, ilu.pos); err != nil {
+		return ILUPreconditioner{}, err
+	}
+	return ilu, nil
+}
+
+// checkDims returns an error if A does not have the dimensions of the analyzed matrix
+func (s *Symbolic) checkDims(A ZeroAwareMatrix) error {
+	if err := checkSquare(A); err != nil {
+		return err
+	}
+	if n, _ := A.Dims(); n != s.n {
+		return fmt.Errorf("%w: expected a %dx%d matrix, got %dx%d", ErrInvalidArgument, s.n, s.n, n, n)
+	}
+	return nil
+}
+
+// numeric calculates the values of the factors of A and stores them in lower and
+// upper. For cholesky factorizations upper is the transpose of lower
+func (s *Symbolic) numeric(A ZeroAwareMatrix, lower, upper []float64, pos []int) error {
+	clear(lower)
+	clear(upper)
+
+	if err := s.scatterMatrix(A, lower, upper, pos); err != nil {
+		return err
+	}
+
+	if s.cholesky {
+		if err := eliminateCholesky(s.pattern, lower, pos); err != nil {
+			return err
+		}
+		s.lowerT.scatterTranspose(upper, lower)
+		return nil
+	}
+	return eliminateILU(s.pattern, lower, upper, pos)
+}
+
+// allocate creates a factorization with the pattern from the symbolic analysis
+// where all values are zero
+func (s *Symbolic) allocate() ILUPreconditioner {
+	lower := make([]float64, len(s.pattern.lowerInd))
+	upper := make([]float64, len(s.pattern.upperInd))
+	pos := make([]int, s.n)
+	for i := range pos {
+		pos[i] = -1
+	}
+
+	ilu := newILUPreconditioner(
+		sparse.NewCSR(s.n, s.n, s.pattern.lowerIndptr, s.pattern.lowerInd, lower),
+		sparse.NewCSR(s.n, s.n, s.pattern.upperIndptr, s.pattern.upperInd, upper),
+	)
+	ilu.symbolic = s
+	ilu.pos = pos
+
+	if s.cholesky {
+		// The transposed factors share the storage with the factors
+		ilu.lowerT, ilu.lowerTDiag = ilu.upper, ilu.upperDiag
+		ilu.upperT, ilu.upperTDiag = ilu.lower, ilu.lowerDiag
+	}
+	return ilu
+}
+
+// position returns the index of entry (i, j) in the values of the factors. The second
+// return value is true if the entry belongs to L and false if it belongs to U. The
+// last return value is false if the entry is not part of the pattern
+func (s *Symbolic) position(i, j int) (int, bool, bool) {
+	p := &s.pattern
+	if j < i || (s.cholesky && j == i) {
+		start, end := p.lowerIndptr[i], p.lowerIndptr[i+1]
+		if !s.cholesky {
+			// The unit diagonal is not part of A
+			end--
+		}
+		idx, found := slices.BinarySearch(p.lowerInd[start:end], j)
+		return start + idx, true, found
+	}
+
+	if s.cholesky {
+		return 0, false, false
+	}
+
+	start, end := p.upperIndptr[i], p.upperIndptr[i+1]
+	idx, found := slices.BinarySearch(p.upperInd[start:end], j)
+	return start + idx, false, found
+}
+
+// scatter adds the value v of entry (i, j) to the values of the factors
+func (s *Symbolic) scatter(i, j int, v float64, lower, upper []float64) error {
+	if s.cholesky && j > i {
+		// Only the lower triangular part is used
+		return nil
+	}
+
+	idx, isLower, found := s.position(i, j)
+	if !found {
+		return errNotInPattern(i, j)
+	}
+
+	if isLower {
+		lower[idx] += v
+	} else {
+		upper[idx] += v
+	}
+	return nil
+}
+
+// scatterRow adds row i of a matrix, given by its columns and values, to the values of
+// the factors. The positions of the columns in the row of the factors are looked up in
+// pos, which must be filled with -1. It is left in that state on return
+func (s *Symbolic) scatterRow(i int, cols []int, values []float64, lower, upper []float64, pos []int) error {
+	p := &s.pattern
+	if s.cholesky {
+		// The diagonal belongs to L
+		for idx := p.lowerIndptr[i]; idx < p.lowerIndptr[i+1]; idx++ {
+			pos[p.lowerInd[idx]] = idx
+		}
+	} else {
+		p.setPositions(i, pos)
+	}
+
+	var err error
+	for k, j := range cols {
+		if s.cholesky && j > i {
+			// Only the lower triangular part is used
+			continue
+		}
+
+		idx := pos[j]
+		if idx < 0 {
+			err = errNotInPattern(i, j)
+			break
+		}
+
+		if j < i || s.cholesky {
+			lower[idx] += values[k]
+		} else {
+			upper[idx] += values[k]
+		}
+	}
+
+	if s.cholesky {
+		for idx := p.lowerIndptr[i]; idx < p.lowerIndptr[i+1]; idx++ {
+			pos[p.lowerInd[idx]] = -1
+		}
+	} else {
+		p.clearPositions(i, pos)
+	}
+	return err
+}
+
+// scatterMatrix adds all non-zero entries of A to the values of the factors. pos is
+// a work array of length n that must be filled with -1
+func (s *Symbolic) scatterMatrix(A ZeroAwareMatrix, lower, upper []float64, pos []int) error {
+	if csr, ok := A.(*sparse.CSR); ok {
+		raw := csr.RawMatrix()
+		for i := 0; i < s.n; i++ {
+			start, end := raw.Indptr[i], raw.Indptr[i+1]
+			if err := s.scatterRow(i, raw.Ind[start:end], raw.Data[start:end], lower, upper, pos); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var err error
+	A.DoNonZero(func(i, j int, v float64) {
+		if err == nil {
+			err = s.scatter(i, j, v, lower, upper)
+		}
+	})
+	return err
+}
+
+func errNotInPattern(i, j int) error {
+	return fmt.Errorf("%w: entry (%d, %d) is not part of the sparsity pattern", ErrInvalidArgument, i, j)
+}
+
+// Refactor recalculates the numeric values of the factorization in place. A must have
+// the same dimensions as the factorized matrix, and all non-zero entries must be part of
+// the sparsity pattern used in the symbolic analysis, otherwise ErrNotSquare or
+// ErrInvalidArgument is returned. ErrZeroPivot is returned if the factorization breaks
+// down, and ErrNoSymbolic if the preconditioner was not created from a symbolic analysis.
+// The new values are only stored if the factorization succeeds, so on error the
+// preconditioner still holds the previous factorization. The first call allocates
+// storage for the new values, after that no memory is allocated when A is a *sparse.CSR
+func (ilu *ILUPreconditioner) Refactor(A ZeroAwareMatrix) error {
+	s := ilu.symbolic
+	if s == nil {
+		return ErrNoSymbolic
+	}
+
+	if err := s.checkDims(A); err != nil {
+		return err
+	}
+
+	lower := ilu.lower.RawMatrix().Data
+	upper := ilu.upper.RawMatrix().Data
+	if ilu.newLower == nil || ilu.newUpper == nil {
+		ilu.newLower = make([]float64, len(lower))
+		ilu.newUpper = make([]float64, len(upper))
+	}
+
+	if err := s.numeric(A, ilu.newLower, ilu.newUpper, ilu.pos); err != nil {
+		return err
+	}
+	copy(lower, ilu.newLower)
+	copy(upper, ilu.newUpper)
+
+	if !s.cholesky && ilu.lowerT != nil && ilu.upperT != nil {
+		s.lowerT.scatterTranspose(ilu.lowerT.RawMatrix().Data, lower)
+		s.upperT.scatterTranspose(ilu.upperT.RawMatrix().Data, upper)
+	}
+	return nil
+}
@@ -0,0 +1,372 @@
+package precond
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/davidkleiven/goprecond/precond/precondtest"
+	"github.com/davidkleiven/goprecond/precond/property"
+	"github.com/james-bowman/sparse"
+	"gonum.org/v1/gonum/mat"
+	"pgregory.net/rapid"
+)
+
+func denseToCSR(matrix mat.Matrix) *sparse.CSR {
+	r, c := matrix.Dims()
+	dok := sparse.NewDOK(r, c)
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			if v := matrix.At(i, j); v != 0.0 {
+				dok.Set(i, j, v)
+			}
+		}
+	}
+	return dok.ToCSR()
+}
+
+// scaledCopy returns a copy of the matrix where all non-zero values are multiplied
+// by a factor. The diagonal is increased such that the copy remains positive definite
+func scaledCopy(matrix *mat.SymDense, factor float64) *mat.SymDense {
+	n := matrix.SymmetricDim()
+	scaled := mat.NewSymDense(n, nil)
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			v := matrix.At(i, j) * factor
+			if i == j {
+				v += 1.0
+			}
+			scaled.SetSym(i, j, v)
+		}
+	}
+	return scaled
+}
+
+func TestTransposePattern(t *testing.T) {
+	// [1, 2, 0]
+	// [0, 3, 4]
+	// [5, 0, 6]
+	indptr := []int{0, 2, 4, 6}
+	ind := []int{0, 1, 1, 2, 0, 2}
+	data := []float64{1.0, 2.0, 3.0, 4.0, 5.0, 6.0}
+
+	pattern := transposePattern(3, 3, indptr, ind)
+	values := make([]float64, len(data))
+	pattern.scatterTranspose(values, data)
+
+	if want := []int{0, 2, 4, 6}; !slices.Equal(pattern.indptr, want) {
+		t.Errorf("Wanted indptr %v got %v\n", want, pattern.indptr)
+	}
+
+	if want := []int{0, 2, 0, 1, 1, 2}; !slices.Equal(pattern.ind, want) {
+		t.Errorf("Wanted ind %v got %v\n", want, pattern.ind)
+	}
+
+	if want := []float64{1.0, 5.0, 2.0, 3.0, 4.0, 6.0}; !slices.Equal(values, want) {
+		t.Errorf("Wanted values %v got %v\n", want, values)
+	}
+}
+
+func TestTransposeCSR(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		rows := rapid.IntRange(1, 10).Draw(t, "rows")
+		cols := rapid.IntRange(1, 10).Draw(t, "cols")
+		dense := mat.NewDense(rows, cols, nil)
+		for i := 0; i < rows; i++ {
+			for j := 0; j < cols; j++ {
+				if rapid.Bool().Draw(t, "non-zero") {
+					dense.Set(i, j, rapid.Float64Range(-1.0, 1.0).Draw(t, "value"))
+				}
+			}
+		}
+
+		// The columns in the rows of the matrix are not sorted, since it is created from
+		// a map
+		transposed := transposeCSR(denseToCSR(dense))
+		if !mat.Equal(transposed, dense.T()) {
+			t.Fatalf("Wanted\n%v\ngot\n%v", mat.Formatted(dense.T()), mat.Formatted(transposed))
+		}
+
+		raw := transposed.RawMatrix()
+		for i := 0; i < raw.I; i++ {
+			if !slices.IsSorted(raw.Ind[raw.Indptr[i]:raw.Indptr[i+1]]) {
+				t.Fatalf("Columns in row %d are not sorted: %v", i, raw.Ind[raw.Indptr[i]:raw.Indptr[i+1]])
+			}
+		}
+	})
+}
+
+func TestFactorizationsAreDeterministic(t *testing.T) {
+	factorizations := map[string]func(ZeroAwareMatrix) ILUPreconditioner{
+		"ILUZero": ILUZero,
+		"IChol":   IChol,
+		"ILUK":    func(A ZeroAwareMatrix) ILUPreconditioner { return ILUK(A, 2) },
+		"ILUT":    func(A ZeroAwareMatrix) ILUPreconditioner { return ILUT(A, 4, 1e-3) },
+	}
+
+	sameFactor := func(a, b *sparse.CSR) bool {
+		rawA, rawB := a.RawMatrix(), b.RawMatrix()
+		return slices.Equal(rawA.Indptr, rawB.Indptr) && slices.Equal(rawA.Ind, rawB.Ind) && slices.Equal(rawA.Data, rawB.Data)
+	}
+
+	rapid.Check(t, func(t *rapid.T) {
+		name := rapid.SampledFrom([]string{"ILUZero", "IChol", "ILUK", "ILUT"}).Draw(t, "factorization")
+		dense := property.SparseSymmetricMatrix(t, 1, 30)
+		n, _ := dense.Dims()
+		rhs := mat.NewVecDense(n, rapid.SliceOfN(rapid.Float64Range(-1.0, 1.0), n, n).Draw(t, "rhs"))
+
+		// Each conversion to CSR stores the entries of the rows in a random order
+		var want []*mat.VecDense
+		first := factorizations[name](denseToCSR(dense))
+		for run := 0; run < 3; run++ {
+			ilu := factorizations[name](denseToCSR(dense))
+			if !sameFactor(first.Lower(), ilu.Lower()) || !sameFactor(first.Upper(), ilu.Upper()) {
+				t.Fatalf("Run %d gives other factors than the first run", run)
+			}
+
+			for k, trans := range []bool{false, true} {
+				x := mat.NewVecDense(n, nil)
+				if err := ilu.SolveVecTo(x, trans, rhs); err != nil {
+					t.Fatalf("%v", err)
+				}
+				if run == 0 {
+					want = append(want, x)
+				} else if !mat.Equal(x, want[k]) {
+					t.Fatalf("trans=%v: run %d gives another solution than the first run", trans, run)
+				}
+			}
+		}
+	})
+}
+
+func TestRefactorMatchesFactorization(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		orig := property.SparseSymmetricMatrix(t, 2, 30)
+		factor := rapid.Float64Range(0.1, 2.0).Draw(t, "factor")
+		level := rapid.IntRange(0, 2).Draw(t, "level")
+		cholesky := rapid.Bool().Draw(t, "cholesky")
+		updated := scaledCopy(orig, factor)
+
+		origCSR := denseToCSR(orig)
+		updatedCSR := denseToCSR(updated)
+
+		var symbolic *Symbolic
+		var want ILUPreconditioner
+		if cholesky {
+			symbolic = AnalyzeIChol(origCSR)
+			want = IChol(updatedCSR)
+		} else {
+			symbolic = AnalyzeILUK(origCSR, level)
+			want = ILUK(updatedCSR, level)
+		}
+
+		ilu, err := symbolic.Factorize(origCSR)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		// Initialize the transposed factors prior to refactoring
+		ilu.initT()
+
+		if err := ilu.Refactor(updatedCSR); err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		want.initT()
+		for _, pair := range [][2]*sparse.CSR{
+			{ilu.lower, want.lower},
+			{ilu.upper, want.upper},
+			{ilu.lowerT, want.lowerT},
+			{ilu.upperT, want.upperT},
+		} {
+			if !mat.EqualApprox(pair[0], pair[1], 1e-10) {
+				t.Fatalf("Wanted\n%v\ngot\n%v\n", mat.Formatted(pair[1]), mat.Formatted(pair[0]))
+			}
+		}
+	})
+}
+
+func TestRefactorDoesNotAllocate(t *testing.T) {
+	n := 100
+	matrix := sparse.NewDOK(n, n)
+	for i := 0; i < n; i++ {
+		matrix.Set(i, i, 4.0)
+		if i > 0 {
+			matrix.Set(i, i-1, -1.0)
+			matrix.Set(i-1, i, -1.0)
+		}
+	}
+	csr := matrix.ToCSR()
+
+	for _, symbolic := range []*Symbolic{AnalyzeILUK(csr, 1), AnalyzeIChol(csr)} {
+		ilu, err := symbolic.Factorize(csr)
+		if err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+
+		allocs := testing.AllocsPerRun(10, func() {
+			if err := ilu.Refactor(csr); err != nil {
+				t.Errorf("%v", err)
+			}
+		})
+
+		if allocs != 0 {
+			t.Errorf("Refactor should not allocate. Got %f allocations", allocs)
+		}
+	}
+}
+
+func TestRefactorErrors(t *testing.T) {
+	diagonal := precondtest.DenseNonZeroDoer{Dense: mat.NewDense(2, 2, []float64{1.0, 0.0, 0.0, 1.0})}
+	full := precondtest.DenseNonZeroDoer{Dense: mat.NewDense(2, 2, []float64{1.0, 1.0, 1.0, 2.0})}
+	rectangular := precondtest.DenseNonZeroDoer{Dense: mat.NewDense(2, 3, []float64{1.0, 0.0, 0.0, 0.0, 1.0, 0.0})}
+	large := precondtest.DenseNonZeroDoer{Dense: mat.NewDense(3, 3, []float64{1.0, 0.0, 0.0, 0.0, 1.0, 0.0, 0.0, 0.0, 1.0})}
+	lastPivot := precondtest.DenseNonZeroDoer{Dense: mat.NewDense(2, 2, []float64{1.0, 1.0, 1.0, 1.0})}
+
+	ilu := ILUZero(&diagonal)
+	fullILU := ILUZero(&full)
+	ichol := IChol(&diagonal)
+	ilut := ILUT(&diagonal, 1, 0.0)
+
+	for _, test := range []struct {
+		ilu    *ILUPreconditioner
+		matrix ZeroAwareMatrix
+		want   error
+		desc   string
+	}{
+		{
+			ilu:    &ilu,
+			matrix: &full,
+			want:   ErrInvalidArgument,
+			desc:   "Off-diagonal entries are not part of the ILU pattern",
+		},
+		{
+			ilu:    &ichol,
+			matrix: &full,
+			want:   ErrInvalidArgument,
+			desc:   "Off-diagonal entries are not part of the IChol pattern",
+		},
+		{
+			ilu:    &ilu,
+			matrix: &large,
+			want:   ErrInvalidArgument,
+			desc:   "Wrong dimensions",
+		},
+		{
+			ilu:    &ilu,
+			matrix: &rectangular,
+			want:   ErrNotSquare{Rows: 2, Cols: 3},
+			desc:   "Not square",
+		},
+		{
+			ilu:    &ilut,
+			matrix: &diagonal,
+			want:   ErrNoSymbolic,
+			desc:   "ILUT has no symbolic analysis",
+		},
+		{
+			ilu:    &fullILU,
+			matrix: &lastPivot,
+			want:   ErrZeroPivot{Row: 1},
+			desc:   "The last pivot is zero",
+		},
+	} {
+		if err := test.ilu.Refactor(test.matrix); !errors.Is(err, test.want) {
+			t.Errorf("Test %s: wanted %v got %v", test.desc, test.want, err)
+		}
+	}
+}
+
+func TestFailedRefactorKeepsFactorization(t *testing.T) {
+	valid := precondtest.DenseNonZeroDoer{Dense: mat.NewDense(3, 3, []float64{4.0, 1.0, 0.0, 1.0, 4.0, 1.0, 0.0, 1.0, 4.0})}
+	singular := precondtest.DenseNonZeroDoer{Dense: mat.NewDense(3, 3, []float64{1.0, 1.0, 0.0, 1.0, 1.0, 1.0, 0.0, 1.0, 4.0})}
+	indefinite := precondtest.DenseNonZeroDoer{Dense: mat.NewDense(3, 3, []float64{4.0, 1.0, 0.0, 1.0, -4.0, 1.0, 0.0, 1.0, 4.0})}
+	rhs := mat.NewVecDense(3, []float64{1.0, 2.0, 3.0})
+
+	for _, test := range []struct {
+		ilu    ILUPreconditioner
+		matrix ZeroAwareMatrix
+		desc   string
+	}{
+		{
+			ilu:    ILUZero(&valid),
+			matrix: &singular,
+			desc:   "ILUZero",
+		},
+		{
+			ilu:    IChol(&valid),
+			matrix: &indefinite,
+			desc:   "IChol",
+		},
+	} {
+		for _, trans := range []bool{false, true} {
+			want := mat.NewVecDense(3, nil)
+			if err := test.ilu.SolveVecTo(want, trans, rhs); err != nil {
+				t.Fatal(err)
+			}
+
+			var pivotErr ErrZeroPivot
+			if err := test.ilu.Refactor(test.matrix); !errors.As(err, &pivotErr) {
+				t.Fatalf("Test %s: wanted ErrZeroPivot got %v", test.desc, err)
+			}
+
+			got := mat.NewVecDense(3, nil)
+			if err := test.ilu.SolveVecTo(got, trans, rhs); err != nil {
+				t.Fatal(err)
+			}
+			if !mat.Equal(got, want) {
+				t.Errorf("Test %s (trans=%v): solution changed after a failed refactor\nwant %v\ngot  %v", test.desc, trans, mat.Formatted(want.T()), mat.Formatted(got.T()))
+			}
+		}
+	}
+}
+
+// benchmarkGridSize is the size of the grid used in the benchmarks, which gives
+// matrices with 1e6 rows
+const benchmarkGridSize = 1000
+
+// gridLaplacian returns the 5-point Laplacian on a n x n grid. It is assembled directly
+// in CSR format, since the matrices used in the benchmarks are large
+func gridLaplacian(n int) *sparse.CSR {
+	indptr := make([]int, 1, n*n+1)
+	ind := make([]int, 0, 5*n*n)
+	data := make([]float64, 0, 5*n*n)
+	add := func(j int, v float64) {
+		ind = append(ind, j)
+		data = append(data, v)
+	}
+
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			row := i*n + j
+			if i > 0 {
+				add(row-n, -1.0)
+			}
+			if j > 0 {
+				add(row-1, -1.0)
+			}
+			add(row, 4.0)
+			if j < n-1 {
+				add(row+1, -1.0)
+			}
+			if i < n-1 {
+				add(row+n, -1.0)
+			}
+			indptr = append(indptr, len(ind))
+		}
+	}
+	return sparse.NewCSR(n*n, n*n, indptr, ind, data)
+}
+
+func BenchmarkRefactor(b *testing.B) {
+	A := gridLaplacian(benchmarkGridSize)
+	ilu := ILUZero(A)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := ilu.Refactor(A); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
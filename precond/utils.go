@@ -1,14 +1,153 @@
 package precond
 
 import (
+	"fmt"
+	"slices"
+
 	"github.com/james-bowman/sparse"
+	"gonum.org/v1/gonum/mat"
 )
 
+// checkVecDims returns an error if the lengths of dst and rhs are not n
+func checkVecDims(n int, dst *mat.VecDense, rhs mat.Vector) error {
+	dstDim, _ := dst.Dims()
+	rhsDim, _ := rhs.Dims()
+
+	if dstDim != n || rhsDim != n {
+		return fmt.Errorf("expected lengths to be %d, got dst: %d and rhs: %d", n, dstDim, rhsDim)
+	}
+	return nil
+}
+
+// copyFromVec copies the elements of v to dst, which must have the same length as v
+func copyFromVec(dst []float64, v mat.Vector) {
+	if vec, ok := v.(*mat.VecDense); ok {
+		raw := vec.RawVector()
+		for i := range dst {
+			dst[i] = raw.Data[i*raw.Inc]
+		}
+		return
+	}
+
+	for i := range dst {
+		dst[i] = v.AtVec(i)
+	}
+}
+
+// copyToVec copies src to the elements of dst, which must have the same length as src
+func copyToVec(dst *mat.VecDense, src []float64) {
+	raw := dst.RawVector()
+	for i, v := range src {
+		raw.Data[i*raw.Inc] = v
+	}
+}
+
+// transposeCSR returns the transpose of a CSR matrix. The columns in each row of the
+// result are sorted, thus the result does not depend on the order of the entries in
+// the rows of the matrix
 func transposeCSR(matrix *sparse.CSR) *sparse.CSR {
-	r, c := matrix.Dims()
-	dok := sparse.NewDOK(r, c)
-	matrix.DoNonZero(func(i, j int, v float64) {
-		dok.Set(j, i, v)
-	})
-	return dok.ToCSR()
+	raw := matrix.RawMatrix()
+	nnz := raw.Indptr[raw.I]
+	pattern := transposePattern(raw.I, raw.J, raw.Indptr, raw.Ind[:nnz])
+	data := make([]float64, nnz)
+	pattern.scatterTranspose(data, raw.Data[:nnz])
+	return sparse.NewCSR(raw.J, raw.I, pattern.indptr, pattern.ind, data)
+}
+
+// sparseRow is a row of a sparse matrix stored as a list of column indices and values
+type sparseRow struct {
+	cols   []int
+	values []float64
+}
+
+// sortRow returns a copy of the row where the entries are sorted by column
+func sortRow(row sparseRow) sparseRow {
+	order := make([]int, len(row.cols))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int { return row.cols[a] - row.cols[b] })
+
+	sorted := sparseRow{cols: make([]int, len(order)), values: make([]float64, len(order))}
+	for i, idx := range order {
+		sorted.cols[i] = row.cols[idx]
+		sorted.values[i] = row.values[idx]
+	}
+	return sorted
+}
+
+// collectRows extracts the non-zero entries of A row by row. The entries
+// in each row are sorted by column. The rows must not be modified, since they
+// share storage with A when A is a *sparse.CSR
+func collectRows(A ZeroAwareMatrix) []sparseRow {
+	n, _ := A.Dims()
+	var (
+		indptr []int
+		cols   []int
+		values []float64
+	)
+	if csr, ok := A.(*sparse.CSR); ok {
+		raw := csr.RawMatrix()
+		indptr, cols, values = raw.Indptr, raw.Ind, raw.Data
+	} else {
+		// Count the entries in each row first, such that all rows can be stored
+		// in two slices
+		indptr = make([]int, n+1)
+		A.DoNonZero(func(i, _ int, _ float64) {
+			indptr[i+1]++
+		})
+		for i := 0; i < n; i++ {
+			indptr[i+1] += indptr[i]
+		}
+
+		cols, values = make([]int, indptr[n]), make([]float64, indptr[n])
+		next := slices.Clone(indptr[:n])
+		A.DoNonZero(func(i, j int, v float64) {
+			cols[next[i]] = j
+			values[next[i]] = v
+			next[i]++
+		})
+	}
+
+	rows := make([]sparseRow, n)
+	for i := range rows {
+		start, end := indptr[i], indptr[i+1]
+		rows[i] = sparseRow{cols: cols[start:end:end], values: values[start:end:end]}
+	}
+
+	for i, row := range rows {
+		if !slices.IsSorted(row.cols) {
+			rows[i] = sortRow(row)
+		}
+	}
+	return rows
+}
+
+// rowsToCSR assembles a square CSR matrix from its rows
+func rowsToCSR(rows []sparseRow) *sparse.CSR {
+	n := len(rows)
+	indptr := make([]int, n+1)
+	for i, row := range rows {
+		indptr[i+1] = indptr[i] + len(row.cols)
+	}
+
+	ind := make([]int, 0, indptr[n])
+	data := make([]float64, 0, indptr[n])
+	for _, row := range rows {
+		ind = append(ind, row.cols...)
+		data = append(data, row.values...)
+	}
+	return sparse.NewCSR(n, n, indptr, ind, data)
+}
+
+// csrMulVec calculates dst = matrix * x. dst and x must not share storage
+func csrMulVec(dst *mat.VecDense, matrix *sparse.CSR, x mat.Vector) {
+	raw := matrix.RawMatrix()
+	for i := 0; i < raw.I; i++ {
+		sum := 0.0
+		for k := raw.Indptr[i]; k < raw.Indptr[i+1]; k++ {
+			sum += raw.Data[k] * x.AtVec(raw.Ind[k])
+		}
+		dst.SetVec(i, sum)
+	}
 }